@@ -3,7 +3,11 @@ package main
 import (
     "fmt"
     "github.com/locne/analysis-service/internal/usecase"
+    "github.com/locne/analysis-service/internal/entity"
     "github.com/locne/analysis-service/internal/interface/handler"
+    "github.com/locne/analysis-service/internal/interface/repository"
+    "github.com/locne/analysis-service/internal/infrastructure/db"
+    "github.com/locne/analysis-service/internal/infrastructure/messagebroker"
     "github.com/gin-gonic/gin"
     "os"
     "encoding/json"
@@ -50,7 +54,18 @@ func main() {
         AllowCredentials: true,
     }))
 
-    
-    handler.RegisterAnalysisRoutes(router, trie)
+    // Analyze games as they finish, when a broker is configured; analyses are kept in Postgres
+    var analysisRepository repository.AnalysisRepository
+    if os.Getenv("RABBITMQ_URL") != "" {
+        dbConn, err := db.ConnectPostgres()
+        if err != nil {
+            panic(err)
+        }
+        dbConn.AutoMigrate(&entity.FinishedGameAnalysis{})
+        analysisRepository = repository.NewAnalysisRepository(dbConn)
+        messagebroker.ConsumeGameFinished(trie, analysisRepository)
+    }
+
+    handler.RegisterAnalysisRoutes(router, trie, analysisRepository)
     router.Run(":8080")
 }
\ No newline at end of file
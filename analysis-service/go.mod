@@ -3,6 +3,8 @@ module github.com/locne/analysis-service
 go 1.24.5
 
 require (
+	github.com/locne/protocol v0.0.0
+	github.com/rabbitmq/amqp091-go v1.10.0
 	gorm.io/driver/postgres v1.6.0
 	gorm.io/gorm v1.30.1
 )
@@ -45,3 +47,5 @@ require (
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/locne/protocol => ../protocol
@@ -0,0 +1,13 @@
+package entity
+
+import (
+    "time"
+)
+
+// Engine analysis of a game played on the site, made when its game.finished event came in
+type FinishedGameAnalysis struct {
+    GameID     string `gorm:"primaryKey;type:varchar(64)"`
+    Moves      string `gorm:"type:text"`  // SAN as played, space-separated
+    Analysis   string `gorm:"type:jsonb"` // usecase.GameAnalysis
+    AnalyzedAt time.Time
+}
@@ -0,0 +1,139 @@
+package messagebroker
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/locne/analysis-service/internal/interface/repository"
+    "github.com/locne/analysis-service/internal/usecase"
+    "github.com/locne/protocol"
+    "github.com/rabbitmq/amqp091-go"
+)
+
+const gameFinishedQueue = "analysis-service.game.finished"
+
+// Reconnect backoff after the broker closes our connection or channel
+const (
+    reconnectBase = time.Second
+    reconnectMax  = time.Minute
+)
+
+// Analyze finished games as they come in. Analysis is slow, so deliveries are taken one
+// at a time and acked once the result is stored. Runs in the background on its own connection, dialling
+// again (with backoff) whenever that connection drops or can't be made.
+func ConsumeGameFinished(trie *usecase.OpeningTrie, repo repository.AnalysisRepository) {
+    go func() {
+        delay := reconnectBase
+        for {
+            consumed, err := consumeGameFinished(trie, repo)
+            if consumed {
+                delay = reconnectBase
+            }
+            log.Printf("game.finished consumer stopped: %v; reconnecting in %v", err, delay)
+            time.Sleep(delay)
+            if delay *= 2; delay > reconnectMax {
+                delay = reconnectMax
+            }
+        }
+    }()
+}
+
+// Consume until the connection or channel closes; consumed is false if we never got
+// that far
+func consumeGameFinished(trie *usecase.OpeningTrie, repo repository.AnalysisRepository) (consumed bool, err error) {
+    conn, ch, err := ConnectRabbit()
+    if err != nil {
+        return false, err
+    }
+    defer conn.Close()
+
+    if err := ch.ExchangeDeclare(
+        protocol.EventsExchange, // name
+        "topic",                 // kind
+        true,                    // durable
+        false,                   // autoDelete
+        false,                   // internal
+        false,                   // noWait
+        nil,                     // arguments
+    ); err != nil {
+        return false, err
+    }
+    if _, err := ch.QueueDeclare(
+        gameFinishedQueue, // name
+        true,              // durable
+        false,             // autoDelete
+        false,             // exclusive
+        false,             // noWait
+        nil,               // arguments
+    ); err != nil {
+        return false, err
+    }
+    if err := ch.QueueBind(gameFinishedQueue, protocol.GameFinishedRoutingKey, protocol.EventsExchange, false, nil); err != nil {
+        return false, err
+    }
+    if err := ch.Qos(1, 0, false); err != nil {
+        return false, err
+    }
+
+    msgs, err := ch.Consume(
+        gameFinishedQueue, // queue
+        "",                // consumer
+        false,             // auto-ack
+        false,             // exclusive
+        false,             // no-local
+        false,             // no-wait
+        nil,               // args
+    )
+    if err != nil {
+        return false, err
+    }
+
+    connClosed := conn.NotifyClose(make(chan *amqp091.Error, 1))
+    chClosed := ch.NotifyClose(make(chan *amqp091.Error, 1))
+    for {
+        select {
+        case d, ok := <-msgs:
+            if !ok {
+                return true, fmt.Errorf("delivery channel closed")
+            }
+            analyzeDelivery(d, trie, repo)
+        case err := <-connClosed:
+            return true, fmt.Errorf("connection closed: %v", err)
+        case err := <-chClosed:
+            return true, fmt.Errorf("channel closed: %v", err)
+        }
+    }
+}
+
+func analyzeDelivery(d amqp091.Delivery, trie *usecase.OpeningTrie, repo repository.AnalysisRepository) {
+    var event protocol.GameFinishedEvent
+    if err := json.Unmarshal(d.Body, &event); err != nil {
+        log.Printf("Invalid game.finished message: %v", err)
+        d.Nack(false, false)
+        return
+    }
+    if err := event.Validate(); err != nil {
+        log.Printf("Invalid game.finished event: %v", err)
+        d.Nack(false, false)
+        return
+    }
+
+    err := usecase.AnalyzeFinishedGame(repo, event, trie)
+    switch {
+    case err == nil:
+        d.Ack(false)
+    case errors.Is(err, usecase.ErrAnalysisFailed):
+        // Analysis is best effort: a game the engine can't handle is dropped rather than
+        // retried forever
+        log.Printf("Analyze game %s error: %v", event.GameID, err)
+        d.Ack(false)
+    default:
+        // Nothing was stored: try again after a pause
+        log.Printf("Store analysis of game %s error: %v", event.GameID, err)
+        time.Sleep(time.Second)
+        d.Nack(false, true)
+    }
+}
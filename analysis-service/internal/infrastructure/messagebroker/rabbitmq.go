@@ -0,0 +1,28 @@
+package messagebroker
+
+import (
+    "fmt"
+    "os"
+    "github.com/rabbitmq/amqp091-go"
+)
+
+func ConnectRabbit() (*amqp091.Connection, *amqp091.Channel, error) {
+    url := os.Getenv("RABBITMQ_URL")
+    if url == "" {
+        return nil, nil, fmt.Errorf("RABBITMQ_URL env not set")
+    }
+
+    conn, err := amqp091.Dial(url)
+    if err != nil {
+        return nil, nil, fmt.Errorf("Can't connect to RabbitMQ: %v", err)
+    }
+
+    ch, err := conn.Channel()
+    if err != nil {
+        conn.Close()
+        return nil, nil, fmt.Errorf("Can't open channel: %v", err)
+    }
+
+    fmt.Println("RabbitMQ connected")
+    return conn, ch, nil
+}
@@ -2,10 +2,13 @@ package handler
 
 import (
 	"fmt"          
+    "errors"
     "strings"
     "net/http"
     "github.com/gin-gonic/gin"
+    "github.com/locne/analysis-service/internal/interface/repository"
     "github.com/locne/analysis-service/internal/usecase"
+    "gorm.io/gorm"
 )
 
 type APIResponse struct {
@@ -186,11 +189,54 @@ func AnalyzeGame(c *gin.Context, trie *usecase.OpeningTrie) {
     })
 }
 
-func RegisterAnalysisRoutes(router *gin.Engine, trie *usecase.OpeningTrie) {
+// Analysis of a finished game, made when the game ended
+func GetFinishedGameAnalysis(c *gin.Context, repo repository.AnalysisRepository) {
+    game, err := usecase.GetFinishedGameAnalysis(repo, c.Param("gameId"))
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        c.JSON(http.StatusNotFound, APIResponse{
+            Status:  "error",
+            Message: "No analysis for this game",
+        })
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, APIResponse{
+            Status:  "error",
+            Message: "Failed to load analysis",
+            Errors:  []string{err.Error()},
+        })
+        return
+    }
+
+    data, err := ConvertToDetailedAnalysis(game.Analysis, game.Moves)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, APIResponse{
+            Status:  "error",
+            Message: "Failed to convert analysis",
+            Errors:  []string{err.Error()},
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, APIResponse{
+        Status:  "success",
+        Message: "Game analysis found",
+        Data:    data,
+    })
+}
+
+// Finished-game analyses are only served when repo is set (they are made by the
+// game.finished consumer)
+func RegisterAnalysisRoutes(router *gin.Engine, trie *usecase.OpeningTrie, repo repository.AnalysisRepository) {
     api := router.Group("/api/v1/analysis")
     {
         api.POST("/pgn", func(c *gin.Context) {
             AnalyzeGame(c, trie)
         })
+        if repo != nil {
+            api.GET("/games/:gameId", func(c *gin.Context) {
+                GetFinishedGameAnalysis(c, repo)
+            })
+        }
     }
 }
\ No newline at end of file
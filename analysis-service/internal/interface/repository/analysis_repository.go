@@ -0,0 +1,31 @@
+package repository
+
+import (
+    "github.com/locne/analysis-service/internal/entity"
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+)
+
+type AnalysisRepository interface {
+    SaveFinishedGame(analysis entity.FinishedGameAnalysis) error
+    GetFinishedGame(gameID string) (entity.FinishedGameAnalysis, error)
+}
+
+type analysisRepository struct {
+    db *gorm.DB
+}
+
+func NewAnalysisRepository(db *gorm.DB) AnalysisRepository {
+    return &analysisRepository{db: db}
+}
+
+// Store a game's analysis; a redelivered game keeps the analysis it already has
+func (r *analysisRepository) SaveFinishedGame(analysis entity.FinishedGameAnalysis) error {
+    return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&analysis).Error
+}
+
+func (r *analysisRepository) GetFinishedGame(gameID string) (entity.FinishedGameAnalysis, error) {
+    var analysis entity.FinishedGameAnalysis
+    err := r.db.Where("game_id = ?", gameID).First(&analysis).Error
+    return analysis, err
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+    "github.com/locne/analysis-service/internal/entity"
+    "github.com/locne/analysis-service/internal/interface/repository"
+    "github.com/locne/protocol"
+    "gorm.io/gorm"
+)
+
+// The engine couldn't analyze a game; trying again won't help
+var ErrAnalysisFailed = errors.New("analysis failed")
+
+// Analysis of a game played on the site, made when its game.finished event came in
+type FinishedGameAnalysis struct {
+    GameID   string
+    Moves    []string
+    Analysis *GameAnalysis
+}
+
+// Whether a finished game is one we can analyze: the engine and opening book assume
+// standard chess from the initial position
+func IsAnalyzable(event protocol.GameFinishedEvent) bool {
+    return !event.Aborted && len(event.Moves) > 0 && event.IsStandardChess()
+}
+
+// Analyze a finished game and store the result; redeliveries of a stored game are skipped.
+// Any error other than ErrAnalysisFailed means nothing was stored.
+func AnalyzeFinishedGame(repo repository.AnalysisRepository, event protocol.GameFinishedEvent, trie *OpeningTrie) error {
+    if !IsAnalyzable(event) {
+        return nil
+    }
+    if _, err := repo.GetFinishedGame(event.GameID); err == nil {
+        return nil
+    } else if !errors.Is(err, gorm.ErrRecordNotFound) {
+        return err
+    }
+
+    analysis, err := AnalyzeGame(event.Moves, trie)
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrAnalysisFailed, err)
+    }
+    data, err := json.Marshal(analysis)
+    if err != nil {
+        return fmt.Errorf("%w: %v", ErrAnalysisFailed, err)
+    }
+
+    return repo.SaveFinishedGame(entity.FinishedGameAnalysis{
+        GameID:     event.GameID,
+        Moves:      strings.Join(event.Moves, " "),
+        Analysis:   string(data),
+        AnalyzedAt: time.Now(),
+    })
+}
+
+// Stored analysis of a finished game; gorm.ErrRecordNotFound if there is none
+func GetFinishedGameAnalysis(repo repository.AnalysisRepository, gameID string) (*FinishedGameAnalysis, error) {
+    stored, err := repo.GetFinishedGame(gameID)
+    if err != nil {
+        return nil, err
+    }
+
+    var analysis GameAnalysis
+    if err := json.Unmarshal([]byte(stored.Analysis), &analysis); err != nil {
+        return nil, err
+    }
+    return &FinishedGameAnalysis{
+        GameID:   stored.GameID,
+        Moves:    strings.Fields(stored.Moves),
+        Analysis: &analysis,
+    }, nil
+}
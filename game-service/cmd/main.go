@@ -10,11 +10,16 @@ import (
     "os"
     "os/signal"
     "syscall"
+    "strconv"
+    "sync"
+    "time"
     "fmt"
     "github.com/locne/game-service/internal/usecase/game"
     "github.com/locne/game-service/internal/infrastructure/messagebroker"
     "github.com/locne/game-service/internal/infrastructure/db"
     "github.com/locne/game-service/internal/interface/repository"
+    "github.com/locne/game-service/internal/interface/handler"
+    "github.com/locne/game-service/internal/usecase"
 )
 
 func main() {
@@ -51,12 +56,35 @@ func main() {
 
     // Setup repository
     gameRepo := repository.NewGameRepository(mongoDB.Database)
+    indexCtx, cancelIndexes := context.WithTimeout(context.Background(), 30*time.Second)
+    if err := gameRepo.EnsureIndexes(indexCtx); err != nil {
+        log.Printf("Game indexes not in place (archive queries slow, saves not deduplicated): %v", err)
+    }
+    cancelIndexes()
+
+    // Setup game archive API
+    gameUseCase := usecase.NewGameUseCase(gameRepo)
+    gameUseCase.SetSite(frontendEnv)
+    handler.RegisterGameRoutes(router, gameUseCase)
 
-    // Setup context
-    ctx := context.Background()
+    // Cancelled on SIGINT/SIGTERM: stops the stream, ownership and outbox loops
+    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+    defer stop()
 
     // Setup GameManager with MongoDB repository
     gameManager := game.NewGameManager(redisClient, ctx, gameRepo)
+    if window, err := strconv.Atoi(os.Getenv("RECONNECT_WINDOW_SECONDS")); err == nil && window > 0 {
+        gameManager.SetReconnectWindow(time.Duration(window) * time.Second)
+    }
+    if window, err := strconv.Atoi(os.Getenv("FIRST_MOVE_WINDOW_SECONDS")); err == nil && window > 0 {
+        gameManager.SetFirstMoveWindow(time.Duration(window) * time.Second)
+    }
+    if window, err := strconv.Atoi(os.Getenv("CLAIM_WINDOW_SECONDS")); err == nil && window > 0 {
+        gameManager.SetClaimWindow(time.Duration(window) * time.Second)
+    }
+    if n, err := strconv.Atoi(os.Getenv("MAX_PREMOVES")); err == nil && n > 0 {
+        gameManager.SetMaxPremoves(n)
+    }
 
     // Setup RabbitMQ
     mqConn, mqCh, err := messagebroker.ConnectRabbit()
@@ -69,16 +97,50 @@ func main() {
     // Start consuming game creation messages
     messagebroker.ConsumeGameCreate(mqCh, gameManager)
 
-    // Start listening for moves and game actions
-    go gameManager.ListenChannels()
+    // Relay game.finished events from the Redis outbox to the broker
+    eventPublisher, err := messagebroker.NewGameEventPublisher(mqConn)
+    if err != nil {
+        log.Fatalf("RabbitMQ event publisher error: %v", err)
+    }
+    defer eventPublisher.Close()
+    gameManager.SetEventPublisher(eventPublisher)
+
+    var loops sync.WaitGroup
+    run := func(loop func()) {
+        loops.Add(1)
+        go func() {
+            defer loops.Done()
+            loop()
+        }()
+    }
+    run(gameManager.RunOutbox)
+
+    // Pick up live games from Redis (previous run or a dead replica) and keep their locks alive
+    gameManager.RestoreGames()
+    run(gameManager.RunOwnership)
+
+    // Moves, game actions and presence changes all arrive on the games' inbound streams
+    run(gameManager.ListenStreams)
 
     // Setup graceful shutdown
     go func() {
-        sigCh := make(chan os.Signal, 1)
-        signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-        <-sigCh
+        <-ctx.Done()
+        stop()
 
         log.Println(" Shutting down gracefully...")
+
+        // No more requests or relaying, then hand our games to the other instances
+        loops.Wait()
+        releaseCtx, cancelRelease := context.WithTimeout(context.Background(), 10*time.Second)
+        gameManager.ReleaseGames(releaseCtx)
+        cancelRelease()
+
+        // Finished games not saved in time stay journaled in Redis for the next start
+        drainCtx, cancelDrain := context.WithTimeout(context.Background(), 30*time.Second)
+        if err := gameManager.DrainSaves(drainCtx); err != nil {
+            log.Printf("Error draining game saves: %v", err)
+        }
+        cancelDrain()
         
         // Close connections
         if err := mongoDB.Disconnect(); err != nil {
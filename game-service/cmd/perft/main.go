@@ -0,0 +1,81 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "time"
+    "github.com/locne/game-service/internal/usecase/engine"
+)
+
+// Verifies the move generator against the standard perft suite.
+//   go run ./cmd/perft -depth 3
+//   go run ./cmd/perft -depth 4 -bench   (nodes per second per position)
+func main() {
+    depth := flag.Int("depth", 3, "max perft depth per position")
+    fen := flag.String("fen", "", "run divide on a single FEN instead of the suite")
+    bench := flag.Bool("bench", false, "report move generator throughput instead of verifying")
+    flag.Parse()
+
+    chessEngine := &engine.ChessEngine{}
+
+    if *bench {
+        runBenchmark(chessEngine, *depth)
+        return
+    }
+
+    if *fen != "" {
+        game, state, err := chessEngine.FENToGameState(*fen)
+        if err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+        }
+        var total int64
+        for move, nodes := range chessEngine.PerftDivide(game, state, *depth) {
+            fmt.Printf("%s: %d\n", move, nodes)
+            total += nodes
+        }
+        fmt.Println("Total:", total)
+        return
+    }
+
+    failed := false
+    for _, position := range engine.PerftPositions {
+        start := time.Now()
+        if err := chessEngine.VerifyPerft(position, *depth); err != nil {
+            fmt.Println("FAIL", err)
+            failed = true
+            continue
+        }
+        fmt.Printf("ok   %-20s depth %d (%v)\n", position.Name, *depth, time.Since(start))
+    }
+
+    if failed {
+        os.Exit(1)
+    }
+}
+
+func runBenchmark(chessEngine *engine.ChessEngine, depth int) {
+    var totalNodes int64
+    var totalTime time.Duration
+
+    for _, position := range engine.PerftPositions {
+        game, state, err := chessEngine.FENToGameState(position.FEN)
+        if err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+        }
+
+        start := time.Now()
+        nodes := chessEngine.Perft(game, state, depth)
+        elapsed := time.Since(start)
+
+        totalNodes += nodes
+        totalTime += elapsed
+        fmt.Printf("%-20s depth %d %12d nodes %10.0f nodes/s\n",
+            position.Name, depth, nodes, float64(nodes)/elapsed.Seconds())
+    }
+
+    fmt.Printf("%-20s          %12d nodes %10.0f nodes/s\n",
+        "total", totalNodes, float64(totalNodes)/totalTime.Seconds())
+}
@@ -23,8 +23,63 @@ type Game struct {
     TimeControl string    `bson:"timeControl"`
 	GameType 	string	  `bson:"gameType"`
     WinnerID    string    `bson:"winnerId"`
-    WhiteTimeLeft int       `bson:"whiteTimeLeft"` 
-    BlackTimeLeft int       `bson:"blackTimeLeft"`
+    WhiteTimeLeft int       `bson:"whiteTimeLeft"` // Milliseconds
+    BlackTimeLeft int       `bson:"blackTimeLeft"` // Milliseconds
     Reason           string    `bson:"reason"`
     LastFen         string   `bson:"lastFen"`     
-}
\ No newline at end of file
+    Variant         string   `bson:"variant"`
+    InitialFen      string   `bson:"initialFen"`  // Chess960 start position (X-FEN)
+    Rated           bool     `bson:"rated"`
+    MoveRecords     []MoveRecord `bson:"moveRecords,omitempty"` // One per ply, alongside Moves
+    Imported        bool     `bson:"imported,omitempty"` // Uploaded as PGN rather than played here
+}
+
+// One ply as it was played
+type MoveRecord struct {
+    Ply       int    `bson:"ply"` // 1 for the first move of the game
+    SAN       string `bson:"san"`
+    UCI       string `bson:"uci"`
+    FENAfter  string `bson:"fenAfter"`
+    Clock     *int   `bson:"clock,omitempty"` // Mover's time left after the move (ms); nil when unknown
+    ThinkTime int    `bson:"thinkTime"`       // Milliseconds from the mover's turn starting to the move
+}
+
+// Game end reasons stored in Game.Reason
+const (
+    ReasonCheckmate            = "checkmate"
+    ReasonStalemate            = "stalemate"
+    ReasonThreefoldRepetition  = "threefold repetition"
+    ReasonFiftyMoveRule        = "fifty move rule"
+    ReasonFivefoldRepetition   = "fivefold repetition"
+    ReasonSeventyFiveMoveRule  = "seventy-five move rule"
+    ReasonInsufficientMaterial = "insufficient material"
+    ReasonResignation          = "resignation"
+    ReasonDrawAgreement        = "draw by agreement"
+    ReasonTimeout              = "timeout"
+    ReasonAbandonment          = "abandonment"         // Win claimed after the opponent left
+    ReasonAbandonmentDraw      = "draw by abandonment" // Draw claimed after the opponent left
+
+    // Aborted games have no result ("*") and must not change ratings
+    ReasonAborted         = "aborted"               // Abort action before both sides moved
+    ReasonNoFirstMove     = "no first move"         // First-move timer ran out
+    ReasonDisconnectAbort = "aborted on disconnect" // A player left before the game got going
+)
+
+// Whether a game ending for this reason is drawn
+func IsDrawReason(reason string) bool {
+    switch reason {
+    case ReasonStalemate, ReasonThreefoldRepetition, ReasonFiftyMoveRule, ReasonFivefoldRepetition,
+        ReasonSeventyFiveMoveRule, ReasonInsufficientMaterial, ReasonDrawAgreement, ReasonAbandonmentDraw:
+        return true
+    }
+    return false
+}
+
+// Whether a game ending for this reason is aborted (unrated)
+func IsAbortReason(reason string) bool {
+    switch reason {
+    case ReasonAborted, ReasonNoFirstMove, ReasonDisconnectAbort:
+        return true
+    }
+    return false
+}
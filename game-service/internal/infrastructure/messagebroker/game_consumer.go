@@ -23,9 +23,13 @@ type Colors struct {
 }
 
 type TimeControl struct {
-    Type        string `json:"type"`
-    InitialTime int    `json:"initialTime"`
-    Increment   int    `json:"increment"`
+    Type        string           `json:"type"`
+    InitialTime int              `json:"initialTime"` // Seconds
+    Increment   int              `json:"increment"`
+    Mode        string           `json:"mode,omitempty"` // "fischer" (default), "delay", "bronstein"
+    Delay       int              `json:"delay,omitempty"`
+    Moves       int              `json:"moves,omitempty"` // Moves in the first period (multi-stage controls)
+    Stages      []game.TimeStage `json:"stages,omitempty"`
 }
 
 type CreateGameMsg struct {
@@ -33,6 +37,11 @@ type CreateGameMsg struct {
     Player2     PlayerGameInfo `json:"player2"`
     TimeControl TimeControl    `json:"timeControl"`
     Colors      Colors         `json:"colors"`
+    Variant     string         `json:"variant"` // "standard" (default), "chess960", "kingOfTheHill", "threeCheck", "atomic"
+    InitialFen  string         `json:"initialFen,omitempty"` // start from this position instead of the variant's setup
+    Rated       bool           `json:"rated"`
+    Takebacks   *bool          `json:"takebacks,omitempty"` // default: allowed in casual games only
+    SpectatorDelay int         `json:"spectatorDelay,omitempty"` // Seconds moves are held back from spectators
 }
 
 func ConsumeGameCreate(ch *amqp091.Channel, gm *game.GameManager) {
@@ -86,12 +95,31 @@ func createGameFromMessage(msg CreateGameMsg, gm *game.GameManager) error {
     }
     
     chessEngine := &engine.ChessEngine{}
-    initialGameState := chessEngine.CreateServerGameState()
+    var initialGameState *engine.ServerGameState
+    var err error
+    if msg.InitialFen != "" {
+        initialGameState, err = chessEngine.CreateServerGameStateFromFEN(msg.InitialFen, msg.Variant)
+    } else {
+        initialGameState, err = chessEngine.CreateVariantGameState(msg.Variant, time.Now().UnixNano())
+    }
+    if err != nil {
+        return err
+    }
     
     timeControl := &game.TimeControl{
         Type:        msg.TimeControl.Type,
         InitialTime: msg.TimeControl.InitialTime,
         Increment:   msg.TimeControl.Increment,
+        Mode:        msg.TimeControl.Mode,
+        Delay:       msg.TimeControl.Delay,
+        Moves:       msg.TimeControl.Moves,
+        Stages:      msg.TimeControl.Stages,
+    }
+    if err := timeControl.Validate(); err != nil {
+        return err
+    }
+    if msg.SpectatorDelay < 0 || msg.SpectatorDelay > game.MaxSpectatorDelay {
+        return fmt.Errorf("spectator delay must be between 0 and %d seconds", game.MaxSpectatorDelay)
     }
     
     newGame := &game.Game{
@@ -100,18 +128,27 @@ func createGameFromMessage(msg CreateGameMsg, gm *game.GameManager) error {
         Spectators:    make(map[int]*game.Player),
         GameState:     initialGameState,
         TimeControl:   timeControl,
-        WhiteTimeLeft: msg.TimeControl.InitialTime,
-        BlackTimeLeft: msg.TimeControl.InitialTime,
+        WhiteTimeLeft: msg.TimeControl.InitialTime * 1000, // Clocks run in milliseconds
+        BlackTimeLeft: msg.TimeControl.InitialTime * 1000,
         LastMoveTime:  time.Now(),
         CreatedAt:     time.Now(),
         UpdatedAt:     time.Now(),
         DrawOffers:    make(map[string]*game.DrawOffer), // Initialize draw offers map
+        Rated:         msg.Rated,
+        TakebacksAllowed: !msg.Rated,
+        TakebackOffers: make(map[string]*game.TakebackOffer),
+        SpectatorDelay: msg.SpectatorDelay,
+    }
+    if msg.Takebacks != nil {
+        newGame.TakebacksAllowed = *msg.Takebacks
     }
     
-    gm.AddGame(newGame)
+    if err := gm.AddGame(newGame); err != nil {
+        return err
+    }
     
-    log.Printf("✅ Created game %s: %s (%s) vs %s (%s)", 
-        gameID,
+    log.Printf("✅ Created %s game %s: %s (%s) vs %s (%s)", 
+        initialGameState.Variant, gameID,
         msg.Player1.Username, msg.Colors.Player1,
         msg.Player2.Username, msg.Colors.Player2,
     )
@@ -124,7 +161,7 @@ func createGameFromMessage(msg CreateGameMsg, gm *game.GameManager) error {
         Player2: *players[msg.Player2.UserID],
     }
     
-    gm.PublishStateUpdate(matchFoundMsg)
+    gm.PublishMatchFound(matchFoundMsg)
     
     return nil
 }
@@ -0,0 +1,134 @@
+package messagebroker
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+    "github.com/locne/protocol"
+    "github.com/rabbitmq/amqp091-go"
+)
+
+// Publishes game events on the game.events topic exchange with publisher confirms.
+// Consumers declare and bind their own durable queues. Events are published mandatory, so
+// one no queue takes yet comes back as a failure and the outbox retries it.
+type GameEventPublisher struct {
+    conn    *amqp091.Connection
+    mutex   sync.Mutex // One publish in flight per channel, so each confirm and return is ours
+    ch      *amqp091.Channel
+    returns chan amqp091.Return
+}
+
+func NewGameEventPublisher(conn *amqp091.Connection) (*GameEventPublisher, error) {
+    publisher := &GameEventPublisher{conn: conn}
+    if _, err := publisher.channel(); err != nil {
+        return nil, err
+    }
+    return publisher, nil
+}
+
+// Confirm-mode channel with the exchange declared, reopened after the broker closes it.
+// Caller must hold p.mutex.
+func (p *GameEventPublisher) channel() (*amqp091.Channel, error) {
+    if p.ch != nil && !p.ch.IsClosed() {
+        return p.ch, nil
+    }
+
+    ch, err := p.conn.Channel()
+    if err != nil {
+        return nil, fmt.Errorf("Can't open event channel: %v", err)
+    }
+    if err := ch.ExchangeDeclare(
+        protocol.EventsExchange, // name
+        "topic",                 // kind
+        true,                    // durable
+        false,                   // autoDelete
+        false,                   // internal
+        false,                   // noWait
+        nil,                     // arguments
+    ); err != nil {
+        ch.Close()
+        return nil, fmt.Errorf("Can't declare exchange %s: %v", protocol.EventsExchange, err)
+    }
+    if err := ch.Confirm(false); err != nil {
+        ch.Close()
+        return nil, fmt.Errorf("Can't enable publisher confirms: %v", err)
+    }
+
+    // The broker sends a return before the confirm, so one slot is enough
+    p.returns = ch.NotifyReturn(make(chan amqp091.Return, 1))
+    p.ch = ch
+    return ch, nil
+}
+
+func (p *GameEventPublisher) PublishGameFinished(ctx context.Context, event protocol.GameFinishedEvent) error {
+    if err := event.Validate(); err != nil {
+        return err
+    }
+    body, err := json.Marshal(event)
+    if err != nil {
+        return err
+    }
+
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+
+    ch, err := p.channel()
+    if err != nil {
+        return err
+    }
+    p.dropStaleReturn()
+
+    confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+        protocol.EventsExchange,         // exchange
+        protocol.GameFinishedRoutingKey, // routing key
+        true,                            // mandatory
+        false,                           // immediate
+        amqp091.Publishing{
+            ContentType:  "application/json",
+            DeliveryMode: amqp091.Persistent,
+            MessageId:    event.GameID, // Consumers dedupe on this
+            Type:         protocol.GameFinishedRoutingKey,
+            Timestamp:    time.Now(),
+            Body:         body,
+        },
+    )
+    if err != nil {
+        return err
+    }
+
+    acked, err := confirm.WaitContext(ctx)
+    if err != nil {
+        return err
+    }
+    if !acked {
+        return fmt.Errorf("broker nacked game.finished for game %s", event.GameID)
+    }
+    select {
+    case ret, ok := <-p.returns:
+        if ok {
+            return fmt.Errorf("game.finished for game %s reached no queue: %s", event.GameID, ret.ReplyText)
+        }
+    default:
+    }
+    return nil
+}
+
+// Discard a return left over from a publish that gave up waiting for its confirm.
+// Caller must hold p.mutex.
+func (p *GameEventPublisher) dropStaleReturn() {
+    select {
+    case <-p.returns:
+    default:
+    }
+}
+
+func (p *GameEventPublisher) Close() error {
+    p.mutex.Lock()
+    defer p.mutex.Unlock()
+    if p.ch == nil {
+        return nil
+    }
+    return p.ch.Close()
+}
@@ -1,10 +1,17 @@
 package handler
 
 import (
+    "errors"
+    "fmt"
+    "io"
+    "log"
     "net/http"
+    "strconv"
     "strings"
+    "time"
     
     "github.com/gin-gonic/gin"
+    "github.com/locne/game-service/internal/interface/repository"
     "github.com/locne/game-service/internal/usecase"
 )
 
@@ -63,11 +70,224 @@ func (h *GameHandler) GetGameByID(c *gin.Context) {
     })
 }
 
+// GET /games?userId=&color=&result=&gameType=&timeControl=&opening=&from=&to=&cursor=&limit=
+//   opening: leading moves in SAN, e.g. "e4 e5 Nf3" or "e4,e5,Nf3"
+//   from/to: RFC 3339 or YYYY-MM-DD; a bare "to" date includes that whole day
+func (h *GameHandler) ListGames(c *gin.Context) {
+    filter, err := parseGameFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, APIResponse{
+            Status:  "error",
+            Message: "Invalid game query",
+            Error:   err.Error(),
+        })
+        return
+    }
+
+    page, err := h.gameUseCase.ListGames(c.Request.Context(), filter, c.Query("cursor"))
+    if err != nil {
+        if errors.Is(err, usecase.ErrInvalidQuery) {
+            c.JSON(http.StatusBadRequest, APIResponse{
+                Status:  "error",
+                Message: "Invalid game query",
+                Error:   err.Error(),
+            })
+            return
+        }
+
+        c.JSON(http.StatusInternalServerError, APIResponse{
+            Status:  "error",
+            Message: "Failed to list games",
+            Error:   err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusOK, APIResponse{
+        Status:  "success",
+        Message: "Games retrieved successfully",
+        Data:    page,
+    })
+}
+
+func parseGameFilter(c *gin.Context) (repository.GameFilter, error) {
+    filter := repository.GameFilter{
+        Color:       c.Query("color"),
+        Result:      c.Query("result"),
+        GameType:    c.Query("gameType"),
+        TimeControl: c.Query("timeControl"),
+        Opening: strings.FieldsFunc(c.Query("opening"), func(r rune) bool {
+            return r == ' ' || r == ','
+        }),
+    }
+
+    var err error
+    if value := c.Query("userId"); value != "" {
+        if filter.UserID, err = strconv.Atoi(value); err != nil {
+            return filter, fmt.Errorf("userId must be a number")
+        }
+    }
+    if value := c.Query("limit"); value != "" {
+        if filter.Limit, err = strconv.Atoi(value); err != nil {
+            return filter, fmt.Errorf("limit must be a number")
+        }
+    }
+    if filter.From, err = parseQueryTime(c.Query("from"), false); err != nil {
+        return filter, fmt.Errorf("from: %w", err)
+    }
+    if filter.To, err = parseQueryTime(c.Query("to"), true); err != nil {
+        return filter, fmt.Errorf("to: %w", err)
+    }
+    return filter, nil
+}
+
+func parseQueryTime(value string, endOfDay bool) (time.Time, error) {
+    if value == "" {
+        return time.Time{}, nil
+    }
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return t, nil
+    }
+    day, err := time.Parse("2006-01-02", value)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("expected RFC 3339 or YYYY-MM-DD, got %q", value)
+    }
+    if endOfDay {
+        day = day.AddDate(0, 0, 1)
+    }
+    return day, nil
+}
+
+// Largest PGN upload accepted
+const maxPGNUpload = 5 << 20
+
+func (h *GameHandler) GetGamePGN(c *gin.Context) {
+    gameID := c.Param("gameId")
+
+    text, err := h.gameUseCase.GetGamePGN(c.Request.Context(), gameID)
+    if err != nil {
+        if strings.Contains(err.Error(), "game not found") {
+            c.JSON(http.StatusNotFound, APIResponse{
+                Status:  "error",
+                Message: "Game not found",
+                Error:   err.Error(),
+            })
+            return
+        }
+
+        c.JSON(http.StatusInternalServerError, APIResponse{
+            Status:  "error",
+            Message: "Failed to export game",
+            Error:   err.Error(),
+        })
+        return
+    }
+
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pgn"`, gameID))
+    c.Data(http.StatusOK, "application/x-chess-pgn", []byte(text))
+}
+
+// GET /players/:id/games.pgn takes the same filters as ListGames, except paging
+func (h *GameHandler) ExportPlayerGames(c *gin.Context) {
+    userID, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, APIResponse{
+            Status:  "error",
+            Message: "Invalid player ID",
+            Error:   "player id must be a number",
+        })
+        return
+    }
+
+    filter, err := parseGameFilter(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, APIResponse{
+            Status:  "error",
+            Message: "Invalid game query",
+            Error:   err.Error(),
+        })
+        return
+    }
+    filter.UserID = userID
+    if err := usecase.ValidateGameFilter(&filter); err != nil {
+        c.JSON(http.StatusBadRequest, APIResponse{
+            Status:  "error",
+            Message: "Invalid game query",
+            Error:   err.Error(),
+        })
+        return
+    }
+
+    c.Header("Content-Type", "application/x-chess-pgn")
+    c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="player-%d.pgn"`, userID))
+    c.Status(http.StatusOK)
+
+    // Headers are gone by the time a later page fails, so all we can do is stop
+    if err := h.gameUseCase.ExportGamesPGN(c.Request.Context(), c.Writer, filter); err != nil {
+        log.Printf("PGN export for player %d stopped: %v", userID, err)
+    }
+}
+
+// POST /games/import?userId=&color= with PGN text as the body. userId and color are
+// optional and tie the games to the uploader's history.
+func (h *GameHandler) ImportPGN(c *gin.Context) {
+    body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPGNUpload))
+    if err != nil {
+        c.JSON(http.StatusRequestEntityTooLarge, APIResponse{
+            Status:  "error",
+            Message: "PGN upload too large",
+            Error:   err.Error(),
+        })
+        return
+    }
+
+    owner := usecase.ImportOwner{Color: c.Query("color")}
+    if value := c.Query("userId"); value != "" {
+        if owner.UserID, err = strconv.Atoi(value); err != nil {
+            c.JSON(http.StatusBadRequest, APIResponse{
+                Status:  "error",
+                Message: "Invalid import",
+                Error:   "userId must be a number",
+            })
+            return
+        }
+    }
+
+    gameIDs, err := h.gameUseCase.ImportPGN(c.Request.Context(), string(body), owner)
+    if err != nil {
+        if errors.Is(err, usecase.ErrInvalidPGN) || errors.Is(err, usecase.ErrInvalidQuery) {
+            c.JSON(http.StatusBadRequest, APIResponse{
+                Status:  "error",
+                Message: "Invalid import",
+                Error:   err.Error(),
+            })
+            return
+        }
+
+        c.JSON(http.StatusInternalServerError, APIResponse{
+            Status:  "error",
+            Message: "Failed to import games",
+            Error:   err.Error(),
+        })
+        return
+    }
+
+    c.JSON(http.StatusCreated, APIResponse{
+        Status:  "success",
+        Message: "Games imported successfully",
+        Data:    gin.H{"gameIds": gameIDs},
+    })
+}
+
 func RegisterGameRoutes(router *gin.Engine, gameUseCase *usecase.GameUseCase) {
     gameHandler := NewGameHandler(gameUseCase)
     
     api := router.Group("/api/v1")
     {
+        api.GET("/games", gameHandler.ListGames)
+        api.POST("/games/import", gameHandler.ImportPGN)
         api.GET("/games/:gameId", gameHandler.GetGameByID)
+        api.GET("/games/:gameId/pgn", gameHandler.GetGamePGN)
+        api.GET("/players/:id/games.pgn", gameHandler.ExportPlayerGames)
     }
 }
\ No newline at end of file
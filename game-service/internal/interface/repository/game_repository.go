@@ -4,8 +4,11 @@ import (
     "context"
     "fmt"
     "log"
+    "strconv"
+    "time"
     
     "github.com/locne/game-service/internal/entity"
+    "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -13,52 +16,96 @@ import (
 type GameRepository interface {
     SaveGame(ctx context.Context, game entity.Game) error
     SaveGamesBatch(ctx context.Context, games []entity.Game) error
+    SaveDeadLetter(ctx context.Context, game entity.Game, reason string) error
     GetGameByID(ctx context.Context, gameID string) (*entity.Game, error)
+    ListGames(ctx context.Context, filter GameFilter) ([]entity.Game, error)
+    EnsureIndexes(ctx context.Context) error
+}
+
+// Which archived games to list, newest first. Zero values match everything.
+type GameFilter struct {
+    UserID      int
+    Color       string    // "white" or "black": the side UserID played
+    Result      string    // "1-0", "0-1", "1/2-1/2", "*", or "win", "loss", "draw" for UserID
+    GameType    string
+    TimeControl string
+    Opening     []string  // Leading moves in SAN
+    From        time.Time // CreatedAt >= From
+    To          time.Time // CreatedAt < To
+    After       *GameCursor
+    Limit       int
+}
+
+// Position in the newest-first listing: games strictly older than this one come next
+type GameCursor struct {
+    CreatedAt time.Time
+    GameID    string
 }
 
 type mongoGameRepository struct {
-    collection *mongo.Collection
+    collection  *mongo.Collection
+    deadLetters *mongo.Collection
 }
 
 func NewGameRepository(db *mongo.Database) GameRepository {
     return &mongoGameRepository{
-        collection: db.Collection("games"),
+        collection:  db.Collection("games"),
+        deadLetters: db.Collection("games_dead_letter"),
     }
 }
 
+// Upsert on gameId, so saving a game twice (journal replay, retries) leaves one copy
 func (r *mongoGameRepository) SaveGamesBatch(ctx context.Context, games []entity.Game) error {
     if len(games) == 0 {
         return nil
     }
     
-    // Convert to interface slice for MongoDB
-    documents := make([]interface{}, len(games))
+    models := make([]mongo.WriteModel, len(games))
     for i, game := range games {
-        documents[i] = game
+        models[i] = mongo.NewReplaceOneModel().
+            SetFilter(bson.M{"gameId": game.GameID}).
+            SetReplacement(game).
+            SetUpsert(true)
     }
     
-    // Batch insert với ordered=false cho performance
-    opts := options.InsertMany().SetOrdered(false)
+    // Unordered so one bad game doesn't stop the rest
+    opts := options.BulkWrite().SetOrdered(false)
     
-    result, err := r.collection.InsertMany(ctx, documents, opts)
+    result, err := r.collection.BulkWrite(ctx, models, opts)
     if err != nil {
-        return fmt.Errorf("batch insert failed: %w", err)
+        return fmt.Errorf("batch upsert failed: %w", err)
     }
     
-    log.Printf("Batch inserted %d games, IDs: %v", 
-        len(result.InsertedIDs), result.InsertedIDs)
+    log.Printf("Batch saved %d games (%d new, %d replaced)", 
+        len(games), result.UpsertedCount, result.ModifiedCount)
     
     return nil
 }
 
 func (r *mongoGameRepository) SaveGame(ctx context.Context, game entity.Game) error {
-    _, err := r.collection.InsertOne(ctx, game)
+    opts := options.Replace().SetUpsert(true)
+    _, err := r.collection.ReplaceOne(ctx, bson.M{"gameId": game.GameID}, game, opts)
     if err != nil {
         return fmt.Errorf("failed to save game: %w", err)
     }
     return nil
 }
 
+// Park a game that could not be saved, with the reason, for manual recovery
+func (r *mongoGameRepository) SaveDeadLetter(ctx context.Context, game entity.Game, reason string) error {
+    opts := options.Replace().SetUpsert(true)
+    _, err := r.deadLetters.ReplaceOne(ctx, bson.M{"gameId": game.GameID}, bson.M{
+        "gameId":   game.GameID,
+        "game":     game,
+        "error":    reason,
+        "failedAt": time.Now(),
+    }, opts)
+    if err != nil {
+        return fmt.Errorf("failed to dead-letter game: %w", err)
+    }
+    return nil
+}
+
 func (r *mongoGameRepository) GetGameByID(ctx context.Context, gameID string) (*entity.Game, error) {
     var game entity.Game
     
@@ -72,4 +119,114 @@ func (r *mongoGameRepository) GetGameByID(ctx context.Context, gameID string) (*
     }
     
     return &game, nil
-}
\ No newline at end of file
+}
+func (r *mongoGameRepository) ListGames(ctx context.Context, filter GameFilter) ([]entity.Game, error) {
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "gameId", Value: -1}}).
+        SetLimit(int64(filter.Limit))
+
+    cursor, err := r.collection.Find(ctx, gameFilterQuery(filter), opts)
+    if err != nil {
+        return nil, fmt.Errorf("failed to list games: %w", err)
+    }
+    defer cursor.Close(ctx)
+
+    games := []entity.Game{}
+    if err := cursor.All(ctx, &games); err != nil {
+        return nil, fmt.Errorf("failed to decode games: %w", err)
+    }
+    return games, nil
+}
+
+func gameFilterQuery(filter GameFilter) bson.M {
+    var clauses []bson.M
+
+    if filter.UserID != 0 {
+        sides := []string{"white", "black"}
+        if filter.Color != "" {
+            sides = []string{filter.Color}
+        }
+
+        var seats []bson.M
+        for _, side := range sides {
+            seat := bson.M{"players." + side + ".userId": filter.UserID}
+            if result := resultFor(side, filter.Result); result != "" {
+                seat["result"] = result
+            }
+            seats = append(seats, seat)
+        }
+        clauses = append(clauses, bson.M{"$or": seats})
+    }
+
+    switch filter.Result {
+    case "", "win", "loss", "draw":
+    default:
+        clauses = append(clauses, bson.M{"result": filter.Result})
+    }
+    if filter.GameType != "" {
+        clauses = append(clauses, bson.M{"gameType": filter.GameType})
+    }
+    if filter.TimeControl != "" {
+        clauses = append(clauses, bson.M{"timeControl": filter.TimeControl})
+    }
+    for i, move := range filter.Opening {
+        clauses = append(clauses, bson.M{"moves." + strconv.Itoa(i): move})
+    }
+
+    createdAt := bson.M{}
+    if !filter.From.IsZero() {
+        createdAt["$gte"] = filter.From
+    }
+    if !filter.To.IsZero() {
+        createdAt["$lt"] = filter.To
+    }
+    if len(createdAt) > 0 {
+        clauses = append(clauses, bson.M{"createdAt": createdAt})
+    }
+
+    if after := filter.After; after != nil {
+        clauses = append(clauses, bson.M{"$or": []bson.M{
+            {"createdAt": bson.M{"$lt": after.CreatedAt}},
+            {"createdAt": after.CreatedAt, "gameId": bson.M{"$lt": after.GameID}},
+        }})
+    }
+
+    if len(clauses) == 0 {
+        return bson.M{}
+    }
+    return bson.M{"$and": clauses}
+}
+
+// The stored result meaning a win, loss or draw for whoever played side
+func resultFor(side string, outcome string) string {
+    switch outcome {
+    case "draw":
+        return "1/2-1/2"
+    case "win":
+        if side == "white" {
+            return "1-0"
+        }
+        return "0-1"
+    case "loss":
+        if side == "white" {
+            return "0-1"
+        }
+        return "1-0"
+    }
+    return ""
+}
+
+// Unique gameId for idempotent saves, plus the indexes behind ListGames: per-player
+// history and the global newest-first listing
+func (r *mongoGameRepository) EnsureIndexes(ctx context.Context) error {
+    _, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "gameId", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "players.white.userId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "gameId", Value: -1}}},
+        {Keys: bson.D{{Key: "players.black.userId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "gameId", Value: -1}}},
+        {Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "gameId", Value: -1}}},
+    })
+    if err != nil {
+        return fmt.Errorf("failed to create game indexes: %w", err)
+    }
+    return nil
+}
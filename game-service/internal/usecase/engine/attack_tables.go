@@ -0,0 +1,211 @@
+package engine
+
+import (
+    "fmt"
+    "math/bits"
+)
+
+// Precomputed attack tables, indexed by square (row*8 + col, a1 = 0)
+var (
+    knightAttacks [64]uint64
+    kingAttacks   [64]uint64
+    pawnAttacks   [2][64]uint64 // [sideWhite] / [sideBlack]: squares attacked by a pawn on sq
+
+    rookMagics   [64]magicEntry
+    bishopMagics [64]magicEntry
+)
+
+const (
+    sideWhite = 0
+    sideBlack = 1
+)
+
+// Magic bitboard lookup for one square
+type magicEntry struct {
+    mask    uint64
+    magic   uint64
+    shift   uint
+    attacks []uint64
+}
+
+var (
+    rookDirections   = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+    bishopDirections = [4][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+    knightOffsets    = [8][2]int{{2, 1}, {2, -1}, {-2, 1}, {-2, -1}, {1, 2}, {1, -2}, {-1, 2}, {-1, -2}}
+    kingOffsets      = [8][2]int{{1, 1}, {1, 0}, {1, -1}, {0, 1}, {0, -1}, {-1, 1}, {-1, 0}, {-1, -1}}
+)
+
+func init() {
+    initLeaperAttacks()
+    initMagics(&rookMagics, &rookMagicNumbers, rookDirections)
+    initMagics(&bishopMagics, &bishopMagicNumbers, bishopDirections)
+}
+
+// Sliding attacks from sq given board occupancy
+func rookAttacks(sq int, occupied uint64) uint64 {
+    m := &rookMagics[sq]
+    return m.attacks[((occupied&m.mask)*m.magic)>>m.shift]
+}
+
+func bishopAttacks(sq int, occupied uint64) uint64 {
+    m := &bishopMagics[sq]
+    return m.attacks[((occupied&m.mask)*m.magic)>>m.shift]
+}
+
+func queenAttacks(sq int, occupied uint64) uint64 {
+    return rookAttacks(sq, occupied) | bishopAttacks(sq, occupied)
+}
+
+func squareIndex(pos Position) int {
+    return pos.Row*8 + pos.Col
+}
+
+func squarePosition(sq int) Position {
+    return Position{Row: sq / 8, Col: sq % 8}
+}
+
+func sideIndex(color string) int {
+    if color == "white" {
+        return sideWhite
+    }
+    return sideBlack
+}
+
+func initLeaperAttacks() {
+    for sq := 0; sq < 64; sq++ {
+        row, col := sq/8, sq%8
+
+        for _, offset := range knightOffsets {
+            knightAttacks[sq] |= squareBit(row+offset[0], col+offset[1])
+        }
+        for _, offset := range kingOffsets {
+            kingAttacks[sq] |= squareBit(row+offset[0], col+offset[1])
+        }
+
+        pawnAttacks[sideWhite][sq] = squareBit(row+1, col-1) | squareBit(row+1, col+1)
+        pawnAttacks[sideBlack][sq] = squareBit(row-1, col-1) | squareBit(row-1, col+1)
+    }
+}
+
+// Bit for (row, col), 0 when off the board
+func squareBit(row, col int) uint64 {
+    if row < 0 || row > 7 || col < 0 || col > 7 {
+        return 0
+    }
+    return uint64(1) << uint(row*8+col)
+}
+
+// Ray attacks by walking the board, used to fill the magic tables
+func slidingAttacks(sq int, occupied uint64, directions [4][2]int) uint64 {
+    var attacks uint64
+    row, col := sq/8, sq%8
+
+    for _, direction := range directions {
+        r, c := row+direction[0], col+direction[1]
+        for r >= 0 && r < 8 && c >= 0 && c < 8 {
+            bit := uint64(1) << uint(r*8+c)
+            attacks |= bit
+            if occupied&bit != 0 {
+                break
+            }
+            r += direction[0]
+            c += direction[1]
+        }
+    }
+
+    return attacks
+}
+
+// Relevant occupancy: ray squares excluding the board edge in each direction
+func relevantMask(sq int, directions [4][2]int) uint64 {
+    var mask uint64
+    row, col := sq/8, sq%8
+
+    for _, direction := range directions {
+        r, c := row+direction[0], col+direction[1]
+        for {
+            nr, nc := r+direction[0], c+direction[1]
+            if r < 0 || r > 7 || c < 0 || c > 7 || nr < 0 || nr > 7 || nc < 0 || nc > 7 {
+                break
+            }
+            mask |= uint64(1) << uint(r*8+c)
+            r, c = nr, nc
+        }
+    }
+
+    return mask
+}
+
+// Fill the attack table for each square from its magic multiplier
+func initMagics(table *[64]magicEntry, magics *[64]uint64, directions [4][2]int) {
+    for sq := 0; sq < 64; sq++ {
+        mask := relevantMask(sq, directions)
+        relevantBits := bits.OnesCount64(mask)
+
+        entry := magicEntry{
+            mask:    mask,
+            magic:   magics[sq],
+            shift:   uint(64 - relevantBits),
+            attacks: make([]uint64, 1<<relevantBits),
+        }
+
+        // Enumerate all subsets of mask (carry-rippler). Subsets may share a slot only when
+        // their attacks agree; anything else means a bad magic and wrong moves later.
+        filled := make([]bool, len(entry.attacks))
+        subset := uint64(0)
+        for {
+            index := (subset * entry.magic) >> entry.shift
+            attacks := slidingAttacks(sq, subset, directions)
+            if filled[index] && entry.attacks[index] != attacks {
+                panic(fmt.Sprintf("magic for square %d collides on occupancy %#x", sq, subset))
+            }
+            entry.attacks[index] = attacks
+            filled[index] = true
+            subset = (subset - mask) & mask
+            if subset == 0 {
+                break
+            }
+        }
+
+        table[sq] = entry
+    }
+}
+
+// Magic multipliers (found offline by random search, collision-free for every occupancy subset)
+var rookMagicNumbers = [64]uint64{
+    0x2080002080400010, 0x00C0002001401000, 0x2100110008402002, 0x0880080081041000,
+    0x0200020020041008, 0x2300040008010012, 0x0C00283004008201, 0x0180010000407A80,
+    0x0168800080400020, 0x0010400040201000, 0x1001002001001048, 0x1001002408100100,
+    0x0801000408010012, 0x4001000209000400, 0x08A20004C8020001, 0x2002801145002280,
+    0x0080860021004200, 0x001000C009402002, 0x00B0002004002800, 0x100A808010020800,
+    0x8101010008000410, 0x0244008002000480, 0x0000040010810208, 0x2000020000448534,
+    0x4104400480008033, 0x0000810100204000, 0x0440430900200010, 0x4600240900100100,
+    0x0060080080040080, 0x0001000300080400, 0x0004084400011002, 0x0023040200008041,
+    0x0580050043002080, 0x0400804002802008, 0x0001002001004010, 0x1000200901001000,
+    0x4410800801800C00, 0xA012003806001004, 0x0020100104008802, 0x0004808402000041,
+    0x0010400170898000, 0x0080500020004004, 0x1040408012020020, 0x8010040008004040,
+    0x2001080100110004, 0x0000020004008080, 0x0021010810040002, 0x0800008C43020024,
+    0x0000800021005100, 0x0070201040008080, 0x0000D04282006A00, 0x0010014400080240,
+    0x0001080110050100, 0x0012000810240600, 0x0402000801040200, 0x028100108A004100,
+    0x0050800300102045, 0x8208210040120882, 0x8010600101183441, 0x020B000910006045,
+    0x0241001002480005, 0x0081000400880241, 0x0000009008024124, 0x0048122980410402,
+}
+
+var bishopMagicNumbers = [64]uint64{
+    0x8008029802002200, 0x4291040808802804, 0x0008180040800300, 0x00088A0202AA1050,
+    0x000410A800000000, 0x0009100804040009, 0x0801140121080011, 0xA040808400824000,
+    0x000008A004040048, 0x0600200440808114, 0x2020410401204403, 0x000404106200C001,
+    0x0100011040800026, 0x00080088200A0820, 0x0008004804642080, 0x4000004402981800,
+    0x0710002220020088, 0x2010808202020402, 0x8010080844002820, 0x800C000124028000,
+    0x0002000422010040, 0x6438402200422000, 0x0010A1004C0C2000, 0x000A00E109010190,
+    0x08022010400414C0, 0x8428022220240101, 0x0008088004040010, 0x0008080000220020,
+    0x0421010000104000, 0x219102082500A000, 0x0018008042120150, 0x02108020A09C0402,
+    0x301C202000890208, 0xA004022000080100, 0x100C024100881200, 0x8000080800460A00,
+    0x1004010804440040, 0x420C920080041000, 0x05018C0114440100, 0x00040100308A0080,
+    0x0020821042801000, 0x0202026120001C02, 0x0002001044000800, 0x20AA844200800801,
+    0x0000012011001200, 0x0860209008808042, 0x0008100080A80200, 0x0808020050420201,
+    0x00051C0104C00000, 0x0000840108820022, 0x000A461842080004, 0x2400400914880002,
+    0x00040040102481B4, 0x2104A14202020060, 0x0004081041020060, 0x00A0840082005100,
+    0x0000412210101482, 0x0108504208042210, 0x000020044C040405, 0x4140050206051401,
+    0x0122008051820200, 0x0082800428109100, 0x9104042454440401, 0x141E200C00820848,
+}
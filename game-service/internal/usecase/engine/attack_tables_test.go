@@ -0,0 +1,56 @@
+package engine
+
+import (
+    "math/bits"
+    "testing"
+)
+
+// Every occupancy subset must land in a slot holding its own attack set
+func TestMagicsCollisionFree(t *testing.T) {
+    tables := []struct {
+        name       string
+        magics     *[64]uint64
+        directions [4][2]int
+    }{
+        {"rook", &rookMagicNumbers, rookDirections},
+        {"bishop", &bishopMagicNumbers, bishopDirections},
+    }
+
+    for _, table := range tables {
+        for sq := 0; sq < 64; sq++ {
+            mask := relevantMask(sq, table.directions)
+            shift := uint(64 - bits.OnesCount64(mask))
+            slots := make(map[uint64]uint64)
+
+            subset := uint64(0)
+            for {
+                index := (subset * table.magics[sq]) >> shift
+                attacks := slidingAttacks(sq, subset, table.directions)
+                if seen, ok := slots[index]; ok && seen != attacks {
+                    t.Fatalf("%s magic for square %d collides on occupancy %#x", table.name, sq, subset)
+                }
+                slots[index] = attacks
+
+                subset = (subset - mask) & mask
+                if subset == 0 {
+                    break
+                }
+            }
+        }
+    }
+}
+
+// Lookups agree with walking the rays on a handful of occupancies
+func TestSlidingAttackLookup(t *testing.T) {
+    occupancies := []uint64{0, 0xFFFFFFFFFFFFFFFF, 0x0000FF0000FF0000, 0x8142241818244281, 0x00FF00000000FF00}
+    for sq := 0; sq < 64; sq++ {
+        for _, occupied := range occupancies {
+            if got, want := rookAttacks(sq, occupied), slidingAttacks(sq, occupied, rookDirections); got != want {
+                t.Fatalf("rook on %d with %#x: got %#x, want %#x", sq, occupied, got, want)
+            }
+            if got, want := bishopAttacks(sq, occupied), slidingAttacks(sq, occupied, bishopDirections); got != want {
+                t.Fatalf("bishop on %d with %#x: got %#x, want %#x", sq, occupied, got, want)
+            }
+        }
+    }
+}
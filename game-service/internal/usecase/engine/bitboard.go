@@ -1,5 +1,8 @@
 package engine
 
+import (
+    "math/bits"
+)
 
 // Create starting position
 func (ce *ChessEngine) CreateBitboardGame() BitboardGame {
@@ -26,15 +29,7 @@ func (ce *ChessEngine) GetLSBPosition(bitboard uint64) int {
         return -1
     }
     
-    position := 0
-    temp := bitboard
-    
-    for (temp & 1) == 0 {
-        temp >>= 1
-        position++
-    }
-    
-    return position
+    return bits.TrailingZeros64(bitboard)
 }
 
 // Helper: Clear LSB
@@ -44,7 +39,7 @@ func (ce *ChessEngine) ClearLSB(bitboard uint64) uint64 {
 
 // Convert bitboard to coordinates
 func (ce *ChessEngine) ConvertBitboardToCoordinates(bitboard uint64) []Position {
-    coordinates := []Position{}
+    coordinates := make([]Position, 0, bits.OnesCount64(bitboard))
     temp := bitboard
     
     for temp != 0 {
@@ -179,12 +174,7 @@ func (ce *ChessEngine) SetPieceAt(game *BitboardGame, pos Position, piece Piece)
 
 // Count set bits in bitboard
 func (ce *ChessEngine) CountBits(bitboard uint64) int {
-    count := 0
-    for bitboard != 0 {
-        count++
-        bitboard &= bitboard - 1 // Clear least significant bit
-    }
-    return count
+    return bits.OnesCount64(bitboard)
 }
 
 // Helper: absolute value
@@ -37,4 +37,157 @@ func NewBitboardGame() *BitboardGame {
         BlackQueens:  binary.BigEndian.Uint64([]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}),
         BlackKing:    binary.BigEndian.Uint64([]byte{0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}),
     }
-}
\ No newline at end of file
+}
+// State needed to take back a move played with MakeMove
+type MoveUndo struct {
+    Move            Move
+    ActiveColor     string
+    CastlingRights  CastlingRights
+    EnPassantSquare *Position
+    MoveCount       int
+    HalfMoveClock   int
+    ZobristKey      uint64
+}
+
+// Pointer to the bitboard holding a piece type/color
+func (b *BitboardGame) pieceBoard(piece Piece) *uint64 {
+    if piece.Color == "white" {
+        switch piece.Type {
+        case "pawn": return &b.WhitePawns
+        case "knight": return &b.WhiteKnights
+        case "bishop": return &b.WhiteBishops
+        case "rook": return &b.WhiteRooks
+        case "queen": return &b.WhiteQueens
+        case "king": return &b.WhiteKing
+        }
+    } else {
+        switch piece.Type {
+        case "pawn": return &b.BlackPawns
+        case "knight": return &b.BlackKnights
+        case "bishop": return &b.BlackBishops
+        case "rook": return &b.BlackRooks
+        case "queen": return &b.BlackQueens
+        case "king": return &b.BlackKing
+        }
+    }
+    return nil
+}
+
+// Play a move in place, updating side to move, castling, en passant and clocks
+func (b *BitboardGame) MakeMove(state *GameState, move Move) MoveUndo {
+    undo := MoveUndo{
+        Move:            move,
+        ActiveColor:     state.ActiveColor,
+        CastlingRights:  state.CastlingRights,
+        EnPassantSquare: state.EnPassantSquare,
+        MoveCount:       state.MoveCount,
+        HalfMoveClock:   state.HalfMoveClock,
+        ZobristKey:      state.ZobristKey,
+    }
+
+    ce := &ChessEngine{}
+    key := state.ZobristKey ^ ce.zobristEnPassantKey(b, *state) ^ zobristCastlingKey(state.CastlingRights)
+
+    fromBit := uint64(1) << uint(squareIndex(move.From))
+    toBit := uint64(1) << uint(squareIndex(move.To))
+
+    // Remove captured piece (en passant pawn sits beside the target square)
+    if move.Captured != nil {
+        captureBit := toBit
+        if move.Flags&MoveFlagEnPassant != 0 {
+            captureBit = uint64(1) << uint(move.From.Row*8+move.To.Col)
+        }
+        *b.pieceBoard(*move.Captured) &^= captureBit
+        key ^= zobristPieces[zobristPieceIndex(*move.Captured)][ce.GetLSBPosition(captureBit)]
+    }
+
+    if move.Flags&(MoveFlagCastleKingSide|MoveFlagCastleQueenSide) != 0 {
+        // King and rook may swap or stay put in Chess960, so lift both before placing
+        kingTo, rookFrom, rookTo := castlingSquares(state.CastlingRights, move.From, move.Flags&MoveFlagCastleKingSide != 0)
+        rook := Piece{Type: "rook", Color: move.Piece.Color}
+        rooks := b.pieceBoard(rook)
+        *b.pieceBoard(move.Piece) &^= fromBit
+        *rooks &^= uint64(1) << uint(squareIndex(rookFrom))
+        *b.pieceBoard(move.Piece) |= uint64(1) << uint(squareIndex(kingTo))
+        *rooks |= uint64(1) << uint(squareIndex(rookTo))
+        key ^= zobristPieceKey(move.Piece, move.From) ^ zobristPieceKey(move.Piece, kingTo)
+        key ^= zobristPieceKey(rook, rookFrom) ^ zobristPieceKey(rook, rookTo)
+    } else {
+        // Move piece, swapping in the promoted piece on the last rank
+        *b.pieceBoard(move.Piece) &^= fromBit
+        placed := move.Piece
+        if move.Flags&MoveFlagPromotion != 0 {
+            placed.Type = move.Promotion
+            if placed.Type == "" {
+                placed.Type = "queen"
+            }
+        }
+        *b.pieceBoard(placed) |= toBit
+        key ^= zobristPieceKey(move.Piece, move.From) ^ zobristPieceKey(placed, move.To)
+    }
+
+    // State updates
+    ce.UpdateCastlingRights(state, move.From, move.To, move.Piece)
+    ce.UpdateEnPassantSquare(state, move.From, move.To, move.Piece)
+
+    if move.Piece.Type == "pawn" || move.Captured != nil {
+        state.HalfMoveClock = 0
+    } else {
+        state.HalfMoveClock++
+    }
+    if state.ActiveColor == "black" {
+        state.MoveCount++
+    }
+    state.ActiveColor = oppositeColor(state.ActiveColor)
+
+    key ^= zobristSide ^ zobristCastlingKey(state.CastlingRights) ^ ce.zobristEnPassantKey(b, *state)
+    state.ZobristKey = key
+
+    return undo
+}
+
+// Take back a move played with MakeMove
+func (b *BitboardGame) UnmakeMove(state *GameState, undo MoveUndo) {
+    move := undo.Move
+    fromBit := uint64(1) << uint(squareIndex(move.From))
+    toBit := uint64(1) << uint(squareIndex(move.To))
+
+    if move.Flags&(MoveFlagCastleKingSide|MoveFlagCastleQueenSide) != 0 {
+        kingTo, rookFrom, rookTo := castlingSquares(undo.CastlingRights, move.From, move.Flags&MoveFlagCastleKingSide != 0)
+        rooks := b.pieceBoard(Piece{Type: "rook", Color: move.Piece.Color})
+        *b.pieceBoard(move.Piece) &^= uint64(1) << uint(squareIndex(kingTo))
+        *rooks &^= uint64(1) << uint(squareIndex(rookTo))
+        *b.pieceBoard(move.Piece) |= fromBit
+        *rooks |= uint64(1) << uint(squareIndex(rookFrom))
+    } else {
+        placed := move.Piece
+        if move.Flags&MoveFlagPromotion != 0 {
+            placed.Type = move.Promotion
+            if placed.Type == "" {
+                placed.Type = "queen"
+            }
+        }
+        *b.pieceBoard(placed) &^= toBit
+        *b.pieceBoard(move.Piece) |= fromBit
+    }
+
+    if move.Captured != nil {
+        captureBit := toBit
+        if move.Flags&MoveFlagEnPassant != 0 {
+            captureBit = uint64(1) << uint(move.From.Row*8+move.To.Col)
+        }
+        *b.pieceBoard(*move.Captured) |= captureBit
+    }
+
+    undo.restore(state)
+}
+
+// Put back the state fields MakeMove changed
+func (undo MoveUndo) restore(state *GameState) {
+    state.ActiveColor = undo.ActiveColor
+    state.CastlingRights = undo.CastlingRights
+    state.EnPassantSquare = undo.EnPassantSquare
+    state.MoveCount = undo.MoveCount
+    state.HalfMoveClock = undo.HalfMoveClock
+    state.ZobristKey = undo.ZobristKey
+}
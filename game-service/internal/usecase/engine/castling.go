@@ -4,131 +4,165 @@ package engine
 
 // Castling helper methods
 func (ce *ChessEngine) CanCastleKingside(game BitboardGame, state GameState, color string) bool {
-    isWhite := color == "white"
+    // Check castling rights
+    if color == "white" && !state.CastlingRights.WhiteKingSide {
+        return false
+    }
+    if color == "black" && !state.CastlingRights.BlackKingSide {
+        return false
+    }
+
+    return ce.canCastle(game, state, color, true)
+}
 
+func (ce *ChessEngine) CanCastleQueenside(game BitboardGame, state GameState, color string) bool {
     // Check castling rights
-    if isWhite && !state.CastlingRights.WhiteKingSide {
+    if color == "white" && !state.CastlingRights.WhiteQueenSide {
         return false
     }
-    if !isWhite && !state.CastlingRights.BlackKingSide {
+    if color == "black" && !state.CastlingRights.BlackQueenSide {
         return false
     }
-    
+
+    return ce.canCastle(game, state, color, false)
+}
+
+// Chess960 rules, which include standard castling as a special case:
+// every square the king and rook cross or land on is empty (apart from the two of them),
+// and no square the king crosses or lands on is attacked
+func (ce *ChessEngine) canCastle(game BitboardGame, state GameState, color string, kingSide bool) bool {
     kingRow := 0
-    if !isWhite {
+    if color == "black" {
         kingRow = 7
     }
-    
-    // Check if squares between king and rook are empty
-    squaresBetween := []Position{
-        {Row: kingRow, Col: 5}, // f1 or f8
-        {Row: kingRow, Col: 6}, // g1 or g8
+
+    king := *game.pieceBoard(Piece{Type: "king", Color: color})
+    if king == 0 {
+        return false
     }
-    
-    allPieces := ce.GetAllPieces(game)
-    enemyColor := "black"
-    if !isWhite {
-        enemyColor = "white"
+    kingFrom := squarePosition(ce.GetLSBPosition(king))
+    if kingFrom.Row != kingRow {
+        return false
     }
 
-    for _, square := range squaresBetween {
-        // Square must be empty
-        if ce.IsSquareOccupied(allPieces, square) {
+    kingTo, rookFrom, rookTo := castlingSquares(state.CastlingRights, kingFrom, kingSide)
+
+    // Check if rook is in correct position
+    rookPiece := ce.GetPieceAt(game, rookFrom)
+    if rookPiece == nil || rookPiece.Type != "rook" || rookPiece.Color != color {
+        return false
+    }
+
+    // Squares between king and rook, and both destinations, must be empty
+    occupied := ce.GetAllPieces(game) &^ squareBit(kingRow, kingFrom.Col) &^ squareBit(kingRow, rookFrom.Col)
+    minCol := min(kingFrom.Col, kingTo.Col, rookFrom.Col, rookTo.Col)
+    maxCol := max(kingFrom.Col, kingTo.Col, rookFrom.Col, rookTo.Col)
+    for col := minCol; col <= maxCol; col++ {
+        if occupied&squareBit(kingRow, col) != 0 {
             return false
         }
-        
-        // Square must not be attacked by enemy
-        if ce.IsSquareAttackedBy(game, square, enemyColor) {
+    }
+
+    // Squares king moves through must not be attacked
+    enemyColor := oppositeColor(color)
+    step := 1
+    if kingTo.Col < kingFrom.Col {
+        step = -1
+    }
+    for col := kingFrom.Col; ; col += step {
+        if ce.IsSquareAttackedBy(game, Position{Row: kingRow, Col: col}, enemyColor) {
             return false
         }
+        if col == kingTo.Col {
+            break
+        }
     }
-    
-    // Check if rook is in correct position
-    rookPosition := Position{Row: kingRow, Col: 7}
-    rookPiece := ce.GetPieceAt(game, rookPosition)
-    
-    return rookPiece != nil && rookPiece.Type == "rook" && rookPiece.Color == color
+
+    return true
 }
 
-func (ce *ChessEngine) CanCastleQueenside(game BitboardGame, state GameState, color string) bool {
-    isWhite := color == "white"
-    
-    // Check castling rights
-    if isWhite && !state.CastlingRights.WhiteQueenSide {
-        return false
+// Execute castling move (kingTo is the square the move was entered with)
+func (ce *ChessEngine) ExecuteCastling(game *BitboardGame, rights CastlingRights, kingFrom Position, kingTo Position) {
+    kingSide := ce.isKingSideCastling(rights, kingFrom, kingTo)
+    kingTo, rookFrom, rookTo := castlingSquares(rights, kingFrom, kingSide)
+
+    king := ce.GetPieceAt(*game, kingFrom)
+    if king == nil {
+        return
     }
-    if !isWhite && !state.CastlingRights.BlackQueenSide {
-        return false
+    rook := Piece{Type: "rook", Color: king.Color}
+
+    // Lift both pieces first: in Chess960 the destinations may overlap the start squares
+    ce.ClearPieceAt(game, kingFrom, *king)
+    ce.ClearPieceAt(game, rookFrom, rook)
+    ce.SetPieceAt(game, kingTo, *king)
+    ce.SetPieceAt(game, rookTo, rook)
+}
+
+// King destination, rook start and rook destination for a castling move.
+// The king always ends on the g/c file and the rook on the f/d file.
+func castlingSquares(rights CastlingRights, kingFrom Position, kingSide bool) (Position, Position, Position) {
+    row := kingFrom.Row
+    color := backRankColor(row)
+    if kingSide {
+        return Position{Row: row, Col: 6}, Position{Row: row, Col: rights.KingSideRookCol(color)}, Position{Row: row, Col: 5}
     }
-    
-    kingRow := 0
-    if !isWhite {
-        kingRow = 7
+    return Position{Row: row, Col: 2}, Position{Row: row, Col: rights.QueenSideRookCol(color)}, Position{Row: row, Col: 3}
+}
+
+// Color whose pieces start on row (castling only ever happens on the back ranks)
+func backRankColor(row int) string {
+    if row == 7 {
+        return "black"
     }
-    
-    // Check if squares between king and rook are empty
-    squaresBetween := []Position{
-        {Row: kingRow, Col: 1}, // b1 or b8
-        {Row: kingRow, Col: 2}, // c1 or c8
-        {Row: kingRow, Col: 3}, // d1 or d8
-    }
-    
-    // Squares king moves through (must not be attacked)
-    squaresKingMovesThrough := []Position{
-        {Row: kingRow, Col: 2}, // c1 or c8
-        {Row: kingRow, Col: 3}, // d1 or d8
-    }
-    
-    allPieces := ce.GetAllPieces(game)
-    enemyColor := "black"
-    if !isWhite {
-        enemyColor = "white"
-    }
-    
-    // Check all squares between are empty
-    for _, square := range squaresBetween {
-        if ce.IsSquareOccupied(allPieces, square) {
-            return false
-        }
+    return "white"
+}
+
+// Square a castling move is entered with: the king destination in standard chess,
+// the castling rook's square in Chess960 (king "captures" its own rook, as in UCI_Chess960)
+func castlingTarget(rights CastlingRights, kingFrom Position, kingSide bool) Position {
+    kingTo, rookFrom, _ := castlingSquares(rights, kingFrom, kingSide)
+    if rights.Chess960 {
+        return rookFrom
     }
-    
-    // Check squares king moves through are not attacked
-    for _, square := range squaresKingMovesThrough {
-        if ce.IsSquareAttackedBy(game, square, enemyColor) {
-            return false
-        }
+    return kingTo
+}
+
+func (ce *ChessEngine) isKingSideCastling(rights CastlingRights, kingFrom Position, to Position) bool {
+    if rights.Chess960 {
+        return to.Col == rights.KingSideRookCol(backRankColor(kingFrom.Row))
     }
-    
-    // Check if rook is in correct position
-    rookPosition := Position{Row: kingRow, Col: 0}
-    rookPiece := ce.GetPieceAt(game, rookPosition)
-    
-    return rookPiece != nil && rookPiece.Type == "rook" && rookPiece.Color == color
+    return to.Col > kingFrom.Col
 }
 
-// Execute castling move
-func (ce *ChessEngine) ExecuteCastling(game *BitboardGame, kingFrom Position, kingTo Position) {
-    // Move king
-    ce.MakeMove(game, kingFrom, kingTo)
-    
-    // Move rook
-    var rookFrom, rookTo Position
-    if kingTo.Col == 6 { // Kingside castling
-        rookFrom = Position{Row: kingFrom.Row, Col: 7}
-        rookTo = Position{Row: kingFrom.Row, Col: 5}
-    } else { 
-        rookFrom = Position{Row: kingFrom.Row, Col: 0}
-        rookTo = Position{Row: kingFrom.Row, Col: 3}
-    }
-    
-    ce.MakeMove(game, rookFrom, rookTo)
+// Rook file for kingside castling (h-file unless Chess960)
+func (c CastlingRights) KingSideRookCol(color string) int {
+    if !c.Chess960 {
+        return 7
+    }
+    if color == "black" {
+        return c.BlackKingSideRookFile
+    }
+    return c.WhiteKingSideRookFile
 }
 
+// Rook file for queenside castling (a-file unless Chess960)
+func (c CastlingRights) QueenSideRookCol(color string) int {
+    if !c.Chess960 {
+        return 0
+    }
+    if color == "black" {
+        return c.BlackQueenSideRookFile
+    }
+    return c.WhiteQueenSideRookFile
+}
+
+
 // Execute en passant capture
 func (ce *ChessEngine) ExecuteEnPassant(game *BitboardGame, from Position, to Position, color string) {
     // Move pawn
     ce.MakeMove(game, from, to)
-    
+
     // Remove captured pawn
     capturedPawnRow := from.Row
     capturedPawnPos := Position{Row: capturedPawnRow, Col: to.Col}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+    "testing"
+)
+
+func castlingMoves(t *testing.T, fen string) []Move {
+    t.Helper()
+    ce := &ChessEngine{}
+    game, state, err := ce.FENToGameState(fen)
+    if err != nil {
+        t.Fatalf("%s: %v", fen, err)
+    }
+
+    var castles []Move
+    for _, move := range ce.GenerateLegalMovesFor(game, state) {
+        if move.Flags&(MoveFlagCastleKingSide|MoveFlagCastleQueenSide) != 0 {
+            castles = append(castles, move)
+        }
+    }
+    return castles
+}
+
+// Each color castles with its own rook files
+func TestCastlingRookFilesPerColor(t *testing.T) {
+    tests := []struct {
+        fen    string
+        rookTo Position // Where the castling rook of the side to move ends up
+    }{
+        // White castles with the h-rook, black with the b-rook
+        {"1r2k3/8/8/8/8/8/8/4K2R w Hb - 0 1", Position{Row: 0, Col: 5}},
+        {"1r2k3/8/8/8/8/8/8/4K2R b Hb - 0 1", Position{Row: 7, Col: 3}},
+        // White castles with the g-rook, black with the h-rook
+        {"4k2r/6p1/8/8/8/8/8/4K1R1 w Gh - 0 1", Position{Row: 0, Col: 5}},
+        {"4k2r/6p1/8/8/8/8/8/4K1R1 b Gh - 0 1", Position{Row: 7, Col: 5}},
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        castles := castlingMoves(t, test.fen)
+        if len(castles) != 1 {
+            t.Fatalf("%s: %d castling moves, want 1", test.fen, len(castles))
+        }
+
+        game, state, _ := ce.FENToGameState(test.fen)
+        game, _ = ce.ApplyMove(game, state, castles[0])
+        rook := ce.GetPieceAt(game, test.rookTo)
+        if rook == nil || rook.Type != "rook" {
+            t.Fatalf("%s: no rook on %v after castling", test.fen, test.rookTo)
+        }
+    }
+}
+
+func TestShredderFENRoundTripPerColor(t *testing.T) {
+    ce := &ChessEngine{}
+    for _, fen := range []string{"1r2k3/8/8/8/8/8/8/4K2R w Hb - 0 1", "4k2r/6p1/8/8/8/8/8/4K1R1 w Gh - 0 1"} {
+        game, state, err := ce.FENToGameState(fen)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := ce.GameStateToShredderFEN(game, state); got != fen {
+            t.Fatalf("round trip of %s gave %s", fen, got)
+        }
+    }
+}
+
+// Shredder-FEN castling letters alone don't make a standard setup Chess960
+func TestShredderFENStandardPosition(t *testing.T) {
+    ce := &ChessEngine{}
+    state, err := ce.CreateServerGameStateFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1", VariantStandard)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if state.CastlingRights.Chess960 {
+        t.Fatal("standard start position with HAha parsed as Chess960")
+    }
+    if castles := castlingMoves(t, "r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1"); len(castles) != 2 {
+        t.Fatalf("%d castling moves, want 2", len(castles))
+    }
+}
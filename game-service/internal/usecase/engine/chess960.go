@@ -0,0 +1,118 @@
+package engine
+
+import (
+    "fmt"
+    "math/rand"
+)
+
+// Chess960 start positions are numbered 0-959 (Scharnagl); 518 is the standard setup
+const (
+    Chess960PositionCount    = 960
+    Chess960StandardPosition = 518
+)
+
+// Knight placements over the 5 squares left after bishops and queen
+var chess960KnightTable = [10][2]int{
+    {0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2},
+    {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+// Pick a start position id from a seed, so both sides of a rematch/replay get the same setup
+func Chess960PositionID(seed int64) int {
+    return rand.New(rand.NewSource(seed)).Intn(Chess960PositionCount)
+}
+
+// Back rank piece types (a-file first) for a Chess960 position id
+func Chess960BackRank(id int) ([8]string, error) {
+    var rank [8]string
+    if id < 0 || id >= Chess960PositionCount {
+        return rank, fmt.Errorf("invalid Chess960 position id: %d", id)
+    }
+
+    n := id
+    rank[(n%4)*2+1] = "bishop" // light-squared bishop: b, d, f, h
+    n /= 4
+    rank[(n%4)*2] = "bishop" // dark-squared bishop: a, c, e, g
+    n /= 4
+    placeOnEmpty(&rank, n%6, "queen")
+    n /= 6
+
+    knights := chess960KnightTable[n]
+    // Place the second knight first so the first index still counts the same empty squares
+    placeOnEmpty(&rank, knights[1], "knight")
+    placeOnEmpty(&rank, knights[0], "knight")
+
+    // Remaining three squares are always rook, king, rook
+    placeOnEmpty(&rank, 0, "rook")
+    placeOnEmpty(&rank, 0, "king")
+    placeOnEmpty(&rank, 0, "rook")
+
+    return rank, nil
+}
+
+// Put piece on the n-th empty square of the rank
+func placeOnEmpty(rank *[8]string, n int, pieceType string) {
+    for col := 0; col < 8; col++ {
+        if rank[col] != "" {
+            continue
+        }
+        if n == 0 {
+            rank[col] = pieceType
+            return
+        }
+        n--
+    }
+}
+
+// Bitboards and castling rights for a Chess960 start position
+func (ce *ChessEngine) CreateChess960BitboardGame(id int) (BitboardGame, CastlingRights, error) {
+    backRank, err := Chess960BackRank(id)
+    if err != nil {
+        return BitboardGame{}, CastlingRights{}, err
+    }
+
+    game := BitboardGame{
+        WhitePawns: 0x000000000000FF00,
+        BlackPawns: 0x00FF000000000000,
+    }
+    rights := CastlingRights{
+        WhiteKingSide:  true,
+        WhiteQueenSide: true,
+        BlackKingSide:  true,
+        BlackQueenSide: true,
+        Chess960:       true,
+    }
+
+    seenKing := false
+    for col, pieceType := range backRank {
+        ce.SetPieceAt(&game, Position{Row: 0, Col: col}, Piece{Type: pieceType, Color: "white"})
+        ce.SetPieceAt(&game, Position{Row: 7, Col: col}, Piece{Type: pieceType, Color: "black"})
+
+        switch pieceType {
+        case "king":
+            seenKing = true
+        case "rook":
+            if seenKing {
+                rights.WhiteKingSideRookFile, rights.BlackKingSideRookFile = col, col
+            } else {
+                rights.WhiteQueenSideRookFile, rights.BlackQueenSideRookFile = col, col
+            }
+        }
+    }
+
+    return game, rights, nil
+}
+
+// Create initial server game state for a Chess960 position id
+func (ce *ChessEngine) CreateChess960GameState(id int) (*ServerGameState, error) {
+    game, rights, err := ce.CreateChess960BitboardGame(id)
+    if err != nil {
+        return nil, err
+    }
+    return ce.newServerGameState(game, GameState{
+        ActiveColor:    "white",
+        CastlingRights: rights,
+        MoveCount:      1,
+        Variant:        VariantChess960,
+    }), nil
+}
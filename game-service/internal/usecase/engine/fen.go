@@ -2,10 +2,18 @@ package engine
 
 import (
     "fmt"
+    "strconv"
     "strings"
 )
 
 func (ce *ChessEngine) BitboardToFEN(game BitboardGame, activeColor, castling, enPassant string, halfmove, fullmove int) string {
+    return fmt.Sprintf("%s %s %s %s %d %d",
+        ce.BitboardsToFENBoard(game),
+        string(activeColor[0]), castling, enPassant, halfmove, fullmove)
+}
+
+// Piece placement field of FEN (rank 8 first)
+func (ce *ChessEngine) BitboardsToFENBoard(game BitboardGame) string {
     pieceMap := map[string]rune{
         "white_pawn":   'P',
         "white_knight": 'N',
@@ -21,36 +29,22 @@ func (ce *ChessEngine) BitboardToFEN(game BitboardGame, activeColor, castling, e
         "black_king":   'k',
     }
 
-    // Tạo mảng 8x8 lưu ký hiệu quân cờ
-    board := [8][8]rune{}
-    for row := 0; row < 8; row++ {
-        for col := 0; col < 8; col++ {
-            pos := Position{Row: row, Col: col}
-            piece := ce.GetPieceAt(game, pos)
-            if piece != nil {
-                key := piece.Color + "_" + piece.Type
-                board[row][col] = pieceMap[key]
-            } else {
-                board[row][col] = '.'
-            }
-        }
-    }
-
-    // Chuyển sang FEN
     fenRows := make([]string, 8)
     for row := 0; row < 8; row++ {
         fenRow := ""
         empty := 0
         for col := 0; col < 8; col++ {
-            if board[7-row][col] == '.' { // <-- dùng board[7-row] thay vì board[row]
+            // FEN starts from rank 8, row 0 is rank 1
+            piece := ce.GetPieceAt(game, Position{Row: 7 - row, Col: col})
+            if piece == nil {
                 empty++
-            } else {
-                if empty > 0 {
-                    fenRow += fmt.Sprintf("%d", empty)
-                    empty = 0
-                }
-                fenRow += string(board[7-row][col])
+                continue
             }
+            if empty > 0 {
+                fenRow += fmt.Sprintf("%d", empty)
+                empty = 0
+            }
+            fenRow += string(pieceMap[piece.Color+"_"+piece.Type])
         }
         if empty > 0 {
             fenRow += fmt.Sprintf("%d", empty)
@@ -58,10 +52,7 @@ func (ce *ChessEngine) BitboardToFEN(game BitboardGame, activeColor, castling, e
         fenRows[row] = fenRow
     }
 
-
-    return fmt.Sprintf("%s %s %s %s %d %d",
-        strings.Join(fenRows, "/"),
-        string(activeColor[0]), castling, enPassant, halfmove, fullmove)
+    return strings.Join(fenRows, "/")
 }
 
 func (ce *ChessEngine) FENToBitboard(fen string) BitboardGame {
@@ -83,9 +74,11 @@ func (ce *ChessEngine) FENToBitboard(fen string) BitboardGame {
 
     parts := strings.Split(fen, " ")
     rows := strings.Split(parts[0], "/")
-    for row := 0; row < 8; row++ {
+    for i := 0; i < 8 && i < len(rows); i++ {
+        // FEN lists rank 8 first, row 0 is rank 1
+        row := 7 - i
         col := 0
-        for _, ch := range rows[row] {
+        for _, ch := range rows[i] {
             if ch >= '1' && ch <= '8' {
                 col += int(ch - '0')
             } else {
@@ -99,6 +92,212 @@ func (ce *ChessEngine) FENToBitboard(fen string) BitboardGame {
     return game
 }
 
+// Strict piece placement parser: 8 ranks of 8 squares, known piece letters only
+func (ce *ChessEngine) ParseFENPlacement(placement string) (BitboardGame, error) {
+    var game BitboardGame
+    pieceMap := map[rune]Piece{
+        'P': {Type: "pawn", Color: "white"},
+        'N': {Type: "knight", Color: "white"},
+        'B': {Type: "bishop", Color: "white"},
+        'R': {Type: "rook", Color: "white"},
+        'Q': {Type: "queen", Color: "white"},
+        'K': {Type: "king", Color: "white"},
+        'p': {Type: "pawn", Color: "black"},
+        'n': {Type: "knight", Color: "black"},
+        'b': {Type: "bishop", Color: "black"},
+        'r': {Type: "rook", Color: "black"},
+        'q': {Type: "queen", Color: "black"},
+        'k': {Type: "king", Color: "black"},
+    }
+
+    rows := strings.Split(placement, "/")
+    if len(rows) != 8 {
+        return game, fmt.Errorf("invalid FEN placement: expected 8 ranks, got %d", len(rows))
+    }
+
+    for i, rank := range rows {
+        // FEN lists rank 8 first, row 0 is rank 1
+        row := 7 - i
+        col := 0
+        for _, ch := range rank {
+            if ch >= '1' && ch <= '8' {
+                col += int(ch - '0')
+                continue
+            }
+            piece, ok := pieceMap[ch]
+            if !ok {
+                return game, fmt.Errorf("invalid FEN placement: unknown piece %q", ch)
+            }
+            if col > 7 {
+                return game, fmt.Errorf("invalid FEN placement: rank %d has more than 8 squares", row+1)
+            }
+            ce.SetPieceAt(&game, Position{Row: row, Col: col}, piece)
+            col++
+        }
+        if col != 8 {
+            return game, fmt.Errorf("invalid FEN placement: rank %d has %d squares", row+1, col)
+        }
+    }
+
+    return game, nil
+}
+
+// Parse FEN into bitboards and the side/castling/en passant/clock fields
+func (ce *ChessEngine) FENToGameState(fen string) (BitboardGame, GameState, error) {
+    fields := strings.Fields(fen)
+    if len(fields) != 4 && len(fields) != 6 {
+        return BitboardGame{}, GameState{}, fmt.Errorf("invalid FEN: expected 4 or 6 fields, got %d", len(fields))
+    }
+
+    game, err := ce.ParseFENPlacement(fields[0])
+    if err != nil {
+        return BitboardGame{}, GameState{}, err
+    }
+    state := GameState{MoveCount: 1}
+
+    switch fields[1] {
+    case "w":
+        state.ActiveColor = "white"
+    case "b":
+        state.ActiveColor = "black"
+    default:
+        return BitboardGame{}, GameState{}, fmt.Errorf("invalid FEN side to move: %s", fields[1])
+    }
+
+    rights, err := ce.ParseCastlingRights(game, fields[2])
+    if err != nil {
+        return BitboardGame{}, GameState{}, err
+    }
+    state.CastlingRights = rights
+
+    if fields[3] != "-" {
+        pos := ce.AlgebraicToPosition(fields[3])
+        if pos.Row < 0 {
+            return BitboardGame{}, GameState{}, fmt.Errorf("invalid FEN en passant square: %s", fields[3])
+        }
+        state.EnPassantSquare = &pos
+    }
+
+    if len(fields) >= 6 {
+        halfmove, err := strconv.Atoi(fields[4])
+        if err != nil || halfmove < 0 {
+            return BitboardGame{}, GameState{}, fmt.Errorf("invalid FEN halfmove clock: %s", fields[4])
+        }
+        fullmove, err := strconv.Atoi(fields[5])
+        if err != nil || fullmove < 1 {
+            return BitboardGame{}, GameState{}, fmt.Errorf("invalid FEN fullmove number: %s", fields[5])
+        }
+        state.HalfMoveClock = halfmove
+        state.MoveCount = fullmove
+    }
+
+    state.ZobristKey = ce.ComputeZobristKey(game, state)
+    return game, state, nil
+}
+
+// Parse a FEN castling field: standard KQkq, X-FEN or Shredder-FEN rook file letters.
+// Rook files are only recorded (Chess960) when the setup is not the standard one:
+// Shredder-FEN "HAha" for an e-file king and corner rooks is plain standard castling.
+func (ce *ChessEngine) ParseCastlingRights(game BitboardGame, field string) (CastlingRights, error) {
+    rights := CastlingRights{}
+    if field == "-" {
+        return rights, nil
+    }
+
+    // Rook file per [color][side], -1 until a right names it
+    files := [2][2]int{{-1, -1}, {-1, -1}}
+    nonStandard := false
+
+    for _, ch := range field {
+        color := "white"
+        if ch >= 'a' && ch <= 'z' {
+            color = "black"
+        }
+        row := 0
+        if color == "black" {
+            row = 7
+        }
+
+        king := *game.pieceBoard(Piece{Type: "king", Color: color})
+        kingPos := squarePosition(ce.GetLSBPosition(king))
+        if king == 0 || kingPos.Row != row {
+            return rights, fmt.Errorf("invalid FEN castling rights: %s (%s king not on back rank)", field, color)
+        }
+
+        var kingSide bool
+        var rookCol int
+        switch ch {
+        case 'K', 'k', 'Q', 'q':
+            // Outermost rook on that side of the king
+            kingSide = ch == 'K' || ch == 'k'
+            rookCol = -1
+            for col := 0; col < 8; col++ {
+                piece := ce.GetPieceAt(game, Position{Row: row, Col: col})
+                if piece == nil || piece.Type != "rook" || piece.Color != color {
+                    continue
+                }
+                if kingSide && col > kingPos.Col {
+                    rookCol = col
+                }
+                if !kingSide && col < kingPos.Col && rookCol < 0 {
+                    rookCol = col
+                }
+            }
+            if rookCol < 0 {
+                return rights, fmt.Errorf("invalid FEN castling rights: %s (no rook for %c)", field, ch)
+            }
+        default:
+            lower := ch | 0x20
+            if lower < 'a' || lower > 'h' {
+                return rights, fmt.Errorf("invalid FEN castling rights: %s", field)
+            }
+            rookCol = int(lower - 'a')
+            piece := ce.GetPieceAt(game, Position{Row: row, Col: rookCol})
+            if piece == nil || piece.Type != "rook" || piece.Color != color || rookCol == kingPos.Col {
+                return rights, fmt.Errorf("invalid FEN castling rights: %s (no rook on %c file)", field, lower)
+            }
+            kingSide = rookCol > kingPos.Col
+        }
+
+        side := 1
+        if kingSide {
+            side = 0
+        }
+        file := &files[sideIndex(color)][side]
+        if *file >= 0 && *file != rookCol {
+            return rights, fmt.Errorf("invalid FEN castling rights: %s (two rooks for one side)", field)
+        }
+        *file = rookCol
+
+        switch {
+        case color == "white" && kingSide: rights.WhiteKingSide = true
+        case color == "white": rights.WhiteQueenSide = true
+        case kingSide: rights.BlackKingSide = true
+        default: rights.BlackQueenSide = true
+        }
+
+        if kingPos.Col != 4 {
+            nonStandard = true
+        }
+    }
+
+    for _, sides := range files {
+        if (sides[0] >= 0 && sides[0] != 7) || (sides[1] >= 0 && sides[1] != 0) {
+            nonStandard = true
+        }
+    }
+
+    if nonStandard {
+        rights.Chess960 = true
+        rights.WhiteKingSideRookFile = files[sideWhite][0]
+        rights.WhiteQueenSideRookFile = files[sideWhite][1]
+        rights.BlackKingSideRookFile = files[sideBlack][0]
+        rights.BlackQueenSideRookFile = files[sideBlack][1]
+    }
+
+    return rights, nil
+}
+
 func (c CastlingRights) ToFEN() string {
     s := ""
     if c.WhiteKingSide { s += "K" }
@@ -113,7 +312,7 @@ func (p *Position) ToFEN() string {
     if p == nil {
         return "-"
     }
-    file := string('a' + p.Col)
-    rank := fmt.Sprintf("%d", 8 - p.Row)
+    file := string(rune('a' + p.Col))
+    rank := fmt.Sprintf("%d", p.Row+1)
     return file + rank
 }
@@ -0,0 +1,94 @@
+package engine
+
+import (
+    "testing"
+)
+
+func TestCreateServerGameStateFromFEN(t *testing.T) {
+    ce := &ChessEngine{}
+    fen := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+    state, err := ce.CreateServerGameStateFromFEN(fen, VariantStandard)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if state.CurrentFen != fen || state.InitialFen != fen {
+        t.Fatalf("FEN changed on the way through: %s", state.CurrentFen)
+    }
+}
+
+// Each class of illegal position is rejected
+func TestCreateServerGameStateFromFENRejects(t *testing.T) {
+    tests := []struct {
+        name string
+        fen  string
+    }{
+        {"too few fields", "4k3/8/8/8/8/8/8/4K3 w -"},
+        {"bad placement", "4k3/8/8/8/8/8/8/4K4 w - - 0 1"},
+        {"bad piece", "4k3/8/8/8/8/8/8/4X3 w - - 0 1"},
+        {"bad side to move", "4k3/8/8/8/8/8/8/4K3 x - - 0 1"},
+        {"bad halfmove clock", "4k3/8/8/8/8/8/8/4K3 w - - -1 1"},
+        {"bad fullmove number", "4k3/8/8/8/8/8/8/4K3 w - - 0 0"},
+        {"missing white king", "4k3/8/8/8/8/8/8/8 w - - 0 1"},
+        {"missing black king", "8/8/8/8/8/8/8/4K3 w - - 0 1"},
+        {"two kings", "4k3/8/8/8/8/8/8/3KK3 w - - 0 1"},
+        {"side to move gives check", "4k3/8/8/8/8/8/8/4RK2 w - - 0 1"},
+        {"pawn on first rank", "4k3/8/8/8/8/8/8/P3K3 w - - 0 1"},
+        {"pawn on last rank", "p3k3/8/8/8/8/8/8/4K3 w - - 0 1"},
+        {"too many pawns", "4k3/8/8/8/8/P7/PPPPPPPP/4K3 w - - 0 1"},
+        {"castling without a rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1"},
+        {"castling with the king off the back rank", "4k3/8/8/8/8/8/4K3/7R w K - 0 1"},
+        {"castling with a missing rook file", "4k3/8/8/8/8/8/8/4K2R w G - 0 1"},
+        {"two rooks for one side", "4k3/8/8/8/8/8/8/4KRR1 w FG - 0 1"},
+        {"bad castling letter", "4k3/8/8/8/8/8/8/4K2R w X - 0 1"},
+        {"Chess960 castling in a standard game", "1r2k3/8/8/8/8/8/8/4K2R w Hb - 0 1"},
+        {"bad en passant square", "4k3/8/8/8/8/8/8/4K3 w - z9 0 1"},
+        {"en passant without a double push", "4k3/8/8/8/8/8/8/4K3 w - d6 0 1"},
+        {"en passant on the wrong rank", "4k3/8/8/3pP3/8/8/8/4K3 w - d3 0 1"},
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            if _, err := ce.CreateServerGameStateFromFEN(test.fen, VariantStandard); err == nil {
+                t.Fatalf("%s accepted", test.fen)
+            }
+        })
+    }
+}
+
+func TestParseCastlingRights(t *testing.T) {
+    tests := []struct {
+        placement string
+        field     string
+        want      CastlingRights
+    }{
+        {"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", "KQkq", CastlingRights{WhiteKingSide: true, WhiteQueenSide: true, BlackKingSide: true, BlackQueenSide: true}},
+        {"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", "-", CastlingRights{}},
+        // Shredder-FEN on the standard setup is standard castling
+        {"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR", "HAha", CastlingRights{WhiteKingSide: true, WhiteQueenSide: true, BlackKingSide: true, BlackQueenSide: true}},
+        {"r3k3/8/8/8/8/8/8/4K2R", "Kq", CastlingRights{WhiteKingSide: true, BlackQueenSide: true}},
+        // X-FEN and Shredder-FEN name the same Chess960 rooks
+        {"1r4kr/8/8/8/8/8/8/1R4KR", "KQkq", CastlingRights{WhiteKingSide: true, WhiteQueenSide: true, BlackKingSide: true, BlackQueenSide: true,
+            Chess960: true, WhiteKingSideRookFile: 7, WhiteQueenSideRookFile: 1, BlackKingSideRookFile: 7, BlackQueenSideRookFile: 1}},
+        {"1r4kr/8/8/8/8/8/8/1R4KR", "HBhb", CastlingRights{WhiteKingSide: true, WhiteQueenSide: true, BlackKingSide: true, BlackQueenSide: true,
+            Chess960: true, WhiteKingSideRookFile: 7, WhiteQueenSideRookFile: 1, BlackKingSideRookFile: 7, BlackQueenSideRookFile: 1}},
+        // X-FEN picks the outermost rook; an inner one needs its file letter
+        {"4k3/8/8/8/8/8/8/4KR1R", "K", CastlingRights{WhiteKingSide: true}},
+        {"4k3/8/8/8/8/8/8/4KR1R", "F", CastlingRights{WhiteKingSide: true, Chess960: true, WhiteKingSideRookFile: 5, WhiteQueenSideRookFile: -1, BlackKingSideRookFile: -1, BlackQueenSideRookFile: -1}},
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        game, err := ce.ParseFENPlacement(test.placement)
+        if err != nil {
+            t.Fatal(err)
+        }
+        got, err := ce.ParseCastlingRights(game, test.field)
+        if err != nil {
+            t.Fatalf("%s %s: %v", test.placement, test.field, err)
+        }
+        if got != test.want {
+            t.Fatalf("%s %s: got %+v, want %+v", test.placement, test.field, got, test.want)
+        }
+    }
+}
@@ -1,6 +1,9 @@
 package engine
 
-//  import ("fmt")
+import (
+    "strings"
+)
+
 // Make move (basic implementation)
 func (ce *ChessEngine) MakeMove(game *BitboardGame, from Position, to Position) {
     piece := ce.GetPieceAt(*game, from)
@@ -48,37 +51,42 @@ func (ce *ChessEngine) UpdateCastlingRights(state *GameState, from Position, to
         }
     }
     
-    // Rook moves or rook captured - lose castling rights for that side
-    if piece.Type == "rook" || (from.Row == 0 || from.Row == 7) {
+    whiteKingSideCol := state.CastlingRights.KingSideRookCol("white")
+    whiteQueenSideCol := state.CastlingRights.QueenSideRookCol("white")
+    blackKingSideCol := state.CastlingRights.KingSideRookCol("black")
+    blackQueenSideCol := state.CastlingRights.QueenSideRookCol("black")
+
+    // Rook moves from its home square - lose castling rights for that side
+    if piece.Type == "rook" {
         // White rooks
-        if from.Row == 7 && from.Col == 0 {
+        if from.Row == 0 && from.Col == whiteQueenSideCol {
             state.CastlingRights.WhiteQueenSide = false
         }
-        if from.Row == 7 && from.Col == 7 {
+        if from.Row == 0 && from.Col == whiteKingSideCol {
             state.CastlingRights.WhiteKingSide = false
         }
         // Black rooks
-        if from.Row == 0 && from.Col == 0 {
+        if from.Row == 7 && from.Col == blackQueenSideCol {
             state.CastlingRights.BlackQueenSide = false
         }
-        if from.Row == 0 && from.Col == 7 {
+        if from.Row == 7 && from.Col == blackKingSideCol {
             state.CastlingRights.BlackKingSide = false
         }
     }
     
     // Rook captured - check destination square
-    if to.Row == 0 && to.Col == 0 {
-        state.CastlingRights.BlackQueenSide = false
-    }
-    if to.Row == 0 && to.Col == 7 {
-        state.CastlingRights.BlackKingSide = false
-    }
-    if to.Row == 7 && to.Col == 0 {
+    if to.Row == 0 && to.Col == whiteQueenSideCol {
         state.CastlingRights.WhiteQueenSide = false
     }
-    if to.Row == 7 && to.Col == 7 {
+    if to.Row == 0 && to.Col == whiteKingSideCol {
         state.CastlingRights.WhiteKingSide = false
     }
+    if to.Row == 7 && to.Col == blackQueenSideCol {
+        state.CastlingRights.BlackQueenSide = false
+    }
+    if to.Row == 7 && to.Col == blackKingSideCol {
+        state.CastlingRights.BlackKingSide = false
+    }
 }
 
 // Update en passant square
@@ -94,21 +102,66 @@ func (ce *ChessEngine) UpdateEnPassantSquare(state *GameState, from Position, to
     } 
 }
 
+// Check if pawn move reaches the last rank
+func (ce *ChessEngine) IsPromotionMove(piece *Piece, to Position) bool {
+    if piece == nil || piece.Type != "pawn" {
+        return false
+    }
+    return (piece.Color == "white" && to.Row == 7) || (piece.Color == "black" && to.Row == 0)
+}
+
+// Parse promotion piece ("q", "Q", "queen" -> "queen")
+func (ce *ChessEngine) ParsePromotionPiece(promotion string) (string, bool) {
+    switch strings.ToLower(strings.TrimSpace(promotion)) {
+    case "q", "queen":
+        return "queen", true
+    case "r", "rook":
+        return "rook", true
+    case "b", "bishop":
+        return "bishop", true
+    case "n", "knight":
+        return "knight", true
+    }
+    return "", false
+}
+
+// Replace promoted pawn with chosen piece
+func (ce *ChessEngine) ExecutePromotion(game *BitboardGame, to Position, color string, pieceType string) {
+    ce.ClearPieceAt(game, to, Piece{Type: "pawn", Color: color})
+    ce.SetPieceAt(game, to, Piece{Type: pieceType, Color: color})
+}
+
 // Complete move execution with state update
-func (ce *ChessEngine) ExecuteMove(game *BitboardGame, state *GameState, from Position, to Position) bool {
+func (ce *ChessEngine) ExecuteMove(game *BitboardGame, state *GameState, from Position, to Position, promotion string) bool {
     // Handle special moves
     piece := ce.GetPieceAt(*game, from)
-    if piece != nil {
-        // Handle castling
-        if piece.Type == "king" && abs(to.Col - from.Col) == 2 {
-            ce.ExecuteCastling(game, from, to)
-        } else if piece.Type == "pawn" && state.EnPassantSquare != nil && 
-                 to.Row == state.EnPassantSquare.Row && to.Col == state.EnPassantSquare.Col {
-            ce.ExecuteEnPassant(game, from, to, piece.Color)
-        } else {
-            // Regular move
-            ce.MakeMove(game, from, to)
+    if piece == nil {
+        return false
+    }
+
+    // Promotion requires a valid piece choice
+    promotionType := ""
+    if ce.IsPromotionMove(piece, to) {
+        pieceType, ok := ce.ParsePromotionPiece(promotion)
+        if !ok {
+            return false
         }
+        promotionType = pieceType
+    }
+
+    // Handle castling
+    if ce.IsCastlingMove(*game, *state, piece, from, to) {
+        ce.ExecuteCastling(game, state.CastlingRights, from, to)
+    } else if piece.Type == "pawn" && state.EnPassantSquare != nil && 
+             to.Row == state.EnPassantSquare.Row && to.Col == state.EnPassantSquare.Col {
+        ce.ExecuteEnPassant(game, from, to, piece.Color)
+    } else {
+        // Regular move
+        ce.MakeMove(game, from, to)
+    }
+
+    if promotionType != "" {
+        ce.ExecutePromotion(game, to, piece.Color, promotionType)
     }
     // Update game state
     ce.UpdateGameState(game, state, from, to, piece)
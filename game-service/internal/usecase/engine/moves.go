@@ -1,5 +1,9 @@
 package engine
 
+import (
+    "strings"
+)
+
 // Generate moves for piece (simplified version for server validation)
 func (ce *ChessEngine) GenerateMovesForPiece(game BitboardGame, state GameState, fromPos Position) []Position {
     piece := ce.GetPieceAt(game, fromPos)
@@ -7,370 +11,260 @@ func (ce *ChessEngine) GenerateMovesForPiece(game BitboardGame, state GameState,
         return []Position{}
     }
     
-    var moves []Position
+    targets := ce.pseudoLegalTargets(&game, state, squareIndex(fromPos), *piece)
+    return ce.FilterLegalMoves(game, state, fromPos, ce.ConvertBitboardToCoordinates(targets))
+}
+
+// Filter legal moves (remove moves that leave king in check)
+func (ce *ChessEngine) FilterLegalMoves(game BitboardGame, state GameState, fromPos Position, moves []Position) []Position {
+    legalMoves := []Position{}
+    
+    for _, to := range moves {
+        move := ce.NewMove(game, state, fromPos, to, "")
+        if ce.isLegalMove(&game, state, move) {
+            legalMoves = append(legalMoves, to)
+        }
+    }
+    
+    return legalMoves
+}
+
+// Variant legality check (standard: make/unmake and check the mover's king is safe)
+func (ce *ChessEngine) isLegalMove(game *BitboardGame, state GameState, move Move) bool {
+    return ce.variantOf(state).IsLegalMove(game, state, move)
+}
+
+// Pseudo-legal destination squares for the piece on sq
+func (ce *ChessEngine) pseudoLegalTargets(game *BitboardGame, state GameState, sq int, piece Piece) uint64 {
+    occupied := ce.GetAllPieces(*game)
+    own := ce.GetAllPiecesOfColor(*game, piece.Color)
     
     switch piece.Type {
-    case "pawn":
-        moves = ce.GeneratePawnMoves(game, state, fromPos)
     case "knight":
-        moves = ce.GenerateKnightMoves(game, fromPos)
+        return knightAttacks[sq] &^ own
     case "bishop":
-        moves = ce.GenerateBishopMoves(game, fromPos)
+        return bishopAttacks(sq, occupied) &^ own
     case "rook":
-        moves = ce.GenerateRookMoves(game, fromPos)
+        return rookAttacks(sq, occupied) &^ own
     case "queen":
-        moves = ce.GenerateQueenMoves(game, fromPos)
+        return queenAttacks(sq, occupied) &^ own
     case "king":
-        moves = ce.GenerateKingMoves(game, state, fromPos)
+        targets := kingAttacks[sq] &^ own
+        if piece.Color == state.ActiveColor && !ce.isSquareAttacked(game, sq, oppositeColor(piece.Color)) {
+            kingFrom := squarePosition(sq)
+            if ce.CanCastleKingside(*game, state, piece.Color) {
+                targets |= uint64(1) << uint(squareIndex(castlingTarget(state.CastlingRights, kingFrom, true)))
+            }
+            if ce.CanCastleQueenside(*game, state, piece.Color) {
+                targets |= uint64(1) << uint(squareIndex(castlingTarget(state.CastlingRights, kingFrom, false)))
+            }
+        }
+        return targets
+    case "pawn":
+        return ce.pawnTargets(game, state, sq, piece.Color, occupied)
     }
     
-    return ce.FilterLegalMoves(game, state, fromPos, moves)
+    return 0
 }
 
-// Filter legal moves (remove moves that leave king in check)
-func (ce *ChessEngine) FilterLegalMoves(game BitboardGame, state GameState, fromPos Position, moves []Position) []Position {
-    legalMoves := []Position{}
+// Pawn pushes, captures and en passant
+func (ce *ChessEngine) pawnTargets(game *BitboardGame, state GameState, sq int, color string, occupied uint64) uint64 {
+    var targets uint64
+    side := sideIndex(color)
+    enemy := ce.GetAllPiecesOfColor(*game, oppositeColor(color))
+    
+    step, startRow := 8, 1
+    if side == sideBlack {
+        step, startRow = -8, 6
+    }
     
-    for _, move := range moves {
-        newGame := ce.CloneBitboards(game)
-        ce.MakeMove(&newGame, fromPos, move)
-        
-        if !ce.IsInCheck(newGame, state.ActiveColor) {
-            legalMoves = append(legalMoves, move)
+    // Forward moves
+    one := sq + step
+    if one >= 0 && one < 64 && occupied&(uint64(1)<<uint(one)) == 0 {
+        targets |= uint64(1) << uint(one)
+        two := one + step
+        if sq/8 == startRow && occupied&(uint64(1)<<uint(two)) == 0 {
+            targets |= uint64(1) << uint(two)
         }
     }
     
-    return legalMoves
+    // Diagonal captures (only if enemy piece present)
+    targets |= pawnAttacks[side][sq] & enemy
+    
+    // En passant
+    if state.EnPassantSquare != nil {
+        targets |= pawnAttacks[side][sq] & (uint64(1) << uint(squareIndex(*state.EnPassantSquare)))
+    }
+    
+    return targets
 }
 
 // Generate knight moves
 func (ce *ChessEngine) GenerateKnightMoves(game BitboardGame, from Position) []Position {
-    moves := []Position{}
     piece := ce.GetPieceAt(game, from)
     if piece == nil || piece.Type != "knight" {
-        return moves
-    }
-    
-    ownPieces := ce.GetAllPiecesOfColor(game, piece.Color)
-    knightMoves := []Position{
-        {Row: from.Row - 2, Col: from.Col - 1}, {Row: from.Row - 2, Col: from.Col + 1},
-        {Row: from.Row - 1, Col: from.Col - 2}, {Row: from.Row - 1, Col: from.Col + 2},
-        {Row: from.Row + 1, Col: from.Col - 2}, {Row: from.Row + 1, Col: from.Col + 2},
-        {Row: from.Row + 2, Col: from.Col - 1}, {Row: from.Row + 2, Col: from.Col + 1},
-    }
-    
-    for _, move := range knightMoves {
-        if ce.IsValidSquare(move) && !ce.IsSquareOccupied(ownPieces, move) {
-            moves = append(moves, move)
-        }
+        return []Position{}
     }
     
-    return moves
+    own := ce.GetAllPiecesOfColor(game, piece.Color)
+    return ce.ConvertBitboardToCoordinates(knightAttacks[squareIndex(from)] &^ own)
 }
 
 // Generate bishop moves
 func (ce *ChessEngine) GenerateBishopMoves(game BitboardGame, from Position) []Position {
-    validMoves := []Position{}
     piece := ce.GetPieceAt(game, from)
-    
     if piece == nil || piece.Type != "bishop" {
-        return validMoves
-    }
-    
-    allPieces := ce.GetAllPieces(game)
-    ownPieces := ce.GetAllPiecesOfColor(game, piece.Color)
-    
-    // 4 diagonal directions
-    diagonalDirections := []Position{
-        {Row: -1, Col: -1}, // Up-left
-        {Row: -1, Col: 1},  // Up-right
-        {Row: 1, Col: -1},  // Down-left
-        {Row: 1, Col: 1},   // Down-right
-    }
-    
-    for _, direction := range diagonalDirections {
-        step := 1
-        
-        for {
-            newRow := from.Row + step*direction.Row
-            newCol := from.Col + step*direction.Col
-            newPosition := Position{Row: newRow, Col: newCol}
-            
-            // Check bounds
-            if !ce.IsValidSquare(newPosition) {
-                break
-            }
-            
-            bit := uint64(1) << uint(newRow*8 + newCol)
-            
-            // Check if square is occupied
-            if (allPieces & bit) != 0 {
-                // Found a piece
-                if !ce.IsSquareOccupied(ownPieces, newPosition) {
-                    // Enemy piece - can capture
-                    validMoves = append(validMoves, newPosition)
-                }
-                // Stop ray (can't jump over pieces)
-                break
-            } else {
-                // Empty square - can move here
-                validMoves = append(validMoves, newPosition)
-            }
-            
-            step++
-        }
+        return []Position{}
     }
     
-    return validMoves
+    own := ce.GetAllPiecesOfColor(game, piece.Color)
+    return ce.ConvertBitboardToCoordinates(bishopAttacks(squareIndex(from), ce.GetAllPieces(game)) &^ own)
 }
 
 // Generate rook moves
 func (ce *ChessEngine) GenerateRookMoves(game BitboardGame, from Position) []Position {
-    validMoves := []Position{}
     piece := ce.GetPieceAt(game, from)
-    
     if piece == nil || piece.Type != "rook" {
-        return validMoves
-    }
-    
-    allPieces := ce.GetAllPieces(game)
-    ownPieces := ce.GetAllPiecesOfColor(game, piece.Color)
-    
-    // 4 straight directions
-    straightDirections := []Position{
-        {Row: -1, Col: 0}, // Up
-        {Row: 1, Col: 0},  // Down
-        {Row: 0, Col: -1}, // Left
-        {Row: 0, Col: 1},  // Right
-    }
-    
-    for _, direction := range straightDirections {
-        step := 1
-        
-        for {
-            newRow := from.Row + step*direction.Row
-            newCol := from.Col + step*direction.Col
-            newPosition := Position{Row: newRow, Col: newCol}
-            
-            // Check bounds
-            if !ce.IsValidSquare(newPosition) {
-                break
-            }
-            
-            bit := uint64(1) << uint(newRow*8 + newCol)
-            
-            // Check if square is occupied
-            if (allPieces & bit) != 0 {
-                // Found a piece
-                if !ce.IsSquareOccupied(ownPieces, newPosition) {
-                    // Enemy piece - can capture
-                    validMoves = append(validMoves, newPosition)
-                }
-                // Stop ray (can't jump over pieces)
-                break
-            } else {
-                // Empty square - can move here
-                validMoves = append(validMoves, newPosition)
-            }
-            
-            step++
-        }
+        return []Position{}
     }
     
-    return validMoves
+    own := ce.GetAllPiecesOfColor(game, piece.Color)
+    return ce.ConvertBitboardToCoordinates(rookAttacks(squareIndex(from), ce.GetAllPieces(game)) &^ own)
 }
 
 // Generate queen moves (rook + bishop)
 func (ce *ChessEngine) GenerateQueenMoves(game BitboardGame, from Position) []Position {
-    validMoves := []Position{}
     piece := ce.GetPieceAt(game, from)
-    
     if piece == nil || piece.Type != "queen" {
-        return validMoves
-    }
-    
-    allPieces := ce.GetAllPieces(game)
-    ownPieces := ce.GetAllPiecesOfColor(game, piece.Color)
-    
-    // Queen = Rook + Bishop directions (8 total)
-    allDirections := []Position{
-        // Rook directions (straight)
-        {Row: -1, Col: 0}, // Up
-        {Row: 1, Col: 0},  // Down
-        {Row: 0, Col: -1}, // Left
-        {Row: 0, Col: 1},  // Right
-        
-        // Bishop directions (diagonal)
-        {Row: -1, Col: -1}, // Up-left
-        {Row: -1, Col: 1},  // Up-right
-        {Row: 1, Col: -1},  // Down-left
-        {Row: 1, Col: 1},   // Down-right
-    }
-    
-    for _, direction := range allDirections {
-        step := 1
-        
-        for {
-            newRow := from.Row + step*direction.Row
-            newCol := from.Col + step*direction.Col
-            newPosition := Position{Row: newRow, Col: newCol}
-            
-            // Check bounds
-            if !ce.IsValidSquare(newPosition) {
-                break
-            }
-            
-            bit := uint64(1) << uint(newRow*8 + newCol)
-            
-            // Check if square is occupied
-            if (allPieces & bit) != 0 {
-                // Found a piece
-                if !ce.IsSquareOccupied(ownPieces, newPosition) {
-                    // Enemy piece - can capture
-                    validMoves = append(validMoves, newPosition)
-                }
-                // Stop ray (can't jump over pieces)
-                break
-            } else {
-                // Empty square - can move here
-                validMoves = append(validMoves, newPosition)
-            }
-            
-            step++
-        }
+        return []Position{}
     }
     
-    return validMoves
+    own := ce.GetAllPiecesOfColor(game, piece.Color)
+    return ce.ConvertBitboardToCoordinates(queenAttacks(squareIndex(from), ce.GetAllPieces(game)) &^ own)
 }
 
 // Generate king moves
 func (ce *ChessEngine) GenerateKingMoves(game BitboardGame, state GameState, from Position) []Position {
-    validMoves := []Position{}
     piece := ce.GetPieceAt(game, from)
-    
     if piece == nil || piece.Type != "king" {
-        return validMoves
-    }
-    
-    ownPieces := ce.GetAllPiecesOfColor(game, piece.Color)
-    enemyColor := "black"
-    if piece.Color == "black" {
-        enemyColor = "white"
-    }
-    
-    // 8 directions (king can move 1 square in any direction)
-    kingMoves := []Position{
-        {Row: -1, Col: -1}, {Row: -1, Col: 0}, {Row: -1, Col: 1},
-        {Row: 0, Col: -1}, {Row: 0, Col: 1},
-        {Row: 1, Col: -1}, {Row: 1, Col: 0}, {Row: 1, Col: 1},
-    }
-    
-    // Regular king moves (1 square in any direction)
-    for _, move := range kingMoves {
-        newRow := from.Row + move.Row
-        newCol := from.Col + move.Col
-        newPosition := Position{Row: newRow, Col: newCol}
-        
-        // Check bounds
-        if !ce.IsValidSquare(newPosition) {
-            continue
-        }
-        
-        // Can't move to square occupied by own pieces
-        if ce.IsSquareOccupied(ownPieces, newPosition) {
-            continue
-        }
-        
-        // Important: Can't move to square attacked by enemy
-        if ce.IsSquareAttackedBy(game, newPosition, enemyColor) {
-            continue
-        }
-        
-        validMoves = append(validMoves, newPosition)
-    }
-    
-    // Castling moves (if not in check and castling rights available)
-    isInCheck := ce.IsInCheck(game, state.ActiveColor)
-    if !isInCheck {
-        // Kingside castling
-        if ce.CanCastleKingside(game, state, piece.Color) {
-            kingsideCastlePosition := Position{
-                Row: from.Row,
-                Col: from.Col + 2,
-            }
-            validMoves = append(validMoves, kingsideCastlePosition)
-        }
-        
-        // Queenside castling
-        if ce.CanCastleQueenside(game, state, piece.Color) {
-            queensideCastlePosition := Position{
-                Row: from.Row,
-                Col: from.Col - 2,
-            }
-            validMoves = append(validMoves, queensideCastlePosition)
-        }
+        return []Position{}
     }
     
-    return validMoves
+    return ce.ConvertBitboardToCoordinates(ce.pseudoLegalTargets(&game, state, squareIndex(from), *piece))
 }
 
 // Improved pawn moves with all rules
 func (ce *ChessEngine) GeneratePawnMoves(game BitboardGame, state GameState, from Position) []Position {
-    validMoves := []Position{}
     piece := ce.GetPieceAt(game, from)
-    
     if piece == nil || piece.Type != "pawn" {
-        return validMoves
+        return []Position{}
     }
     
-    isWhite := piece.Color == "white"
-    direction := 1
-    if !isWhite {
-        direction = -1 // Black moves down (+1)
-    }
+    targets := ce.pawnTargets(&game, state, squareIndex(from), piece.Color, ce.GetAllPieces(game))
+    return ce.ConvertBitboardToCoordinates(targets)
+}
+
+// Generate all legal moves for the side to move
+func (ce *ChessEngine) GenerateLegalMoves(state *ServerGameState) []Move {
+    return ce.GenerateLegalMovesFor(state.Bitboards, state.GameState())
+}
+
+// Generate all legal moves for the side to move from raw bitboards and state
+func (ce *ChessEngine) GenerateLegalMovesFor(game BitboardGame, state GameState) []Move {
+    moves := make([]Move, 0, 48)
     
-    startingRow := 1
-    if !isWhite {
-        startingRow = 6
+    for _, pieceType := range []string{"pawn", "knight", "bishop", "rook", "queen", "king"} {
+        piece := Piece{Type: pieceType, Color: state.ActiveColor}
+        
+        for pieces := *game.pieceBoard(piece); pieces != 0; pieces &= pieces - 1 {
+            fromSq := ce.GetLSBPosition(pieces)
+            from := squarePosition(fromSq)
+            
+            for targets := ce.pseudoLegalTargets(&game, state, fromSq, piece); targets != 0; targets &= targets - 1 {
+                move := ce.NewMove(game, state, from, squarePosition(ce.GetLSBPosition(targets)), "")
+                if !ce.isLegalMove(&game, state, move) {
+                    continue
+                }
+                
+                if move.Flags&MoveFlagPromotion != 0 {
+                    for _, promotion := range []string{"queen", "rook", "bishop", "knight"} {
+                        move.Promotion = promotion
+                        moves = append(moves, move)
+                    }
+                    continue
+                }
+                
+                moves = append(moves, move)
+            }
+        }
     }
     
-    allPieces := ce.GetAllPieces(game)
-    enemyPieces := ce.GetAllPiecesOfColor(game, map[bool]string{true: "black", false: "white"}[isWhite])
+    return moves
+}
+
+// Describe a move from the position before it is played
+func (ce *ChessEngine) NewMove(game BitboardGame, state GameState, from, to Position, promotion string) Move {
+    move := Move{From: from, To: to}
     
-    // Forward move (1 square)
-    oneForward := Position{Row: from.Row + direction, Col: from.Col}
-    if ce.IsValidSquare(oneForward) && !ce.IsSquareOccupied(allPieces, oneForward) {
-        validMoves = append(validMoves, oneForward)
-        
-        // Two squares forward from starting position
-        if from.Row == startingRow {
-            twoForward := Position{Row: from.Row + 2*direction, Col: from.Col}
-            if ce.IsValidSquare(twoForward) && !ce.IsSquareOccupied(allPieces, twoForward) {
-                validMoves = append(validMoves, twoForward)
-            }
+    piece := ce.GetPieceAt(game, from)
+    if piece == nil {
+        return move
+    }
+    move.Piece = *piece
+    
+    if ce.IsCastlingMove(game, state, piece, from, to) {
+        // Chess960 castling lands on the own rook, which is not a capture
+        if ce.isKingSideCastling(state.CastlingRights, from, to) {
+            move.Flags |= MoveFlagCastleKingSide
+        } else {
+            move.Flags |= MoveFlagCastleQueenSide
         }
+        return move
     }
     
-    // Diagonal captures (only if enemy piece present)
-    captureLeft := Position{Row: from.Row + direction, Col: from.Col - 1}
-    captureRight := Position{Row: from.Row + direction, Col: from.Col + 1}
+    if captured := ce.GetPieceAt(game, to); captured != nil {
+        move.Captured = captured
+        move.Flags |= MoveFlagCapture
+    }
     
-    if ce.IsValidSquare(captureLeft) && ce.IsSquareOccupied(enemyPieces, captureLeft) {
-        validMoves = append(validMoves, captureLeft)
+    if ce.IsEnPassantMove(piece, state, to) {
+        move.Captured = &Piece{Type: "pawn", Color: oppositeColor(piece.Color)}
+        move.Flags |= MoveFlagCapture | MoveFlagEnPassant
     }
     
-    if ce.IsValidSquare(captureRight) && ce.IsSquareOccupied(enemyPieces, captureRight) {
-        validMoves = append(validMoves, captureRight)
+    if piece.Type == "pawn" && abs(to.Row-from.Row) == 2 {
+        move.Flags |= MoveFlagDoublePush
     }
     
-    // En passant
-    if state.EnPassantSquare != nil {
-        enPassantTargetRow := from.Row + direction
-        if state.EnPassantSquare.Row == enPassantTargetRow &&
-            abs(state.EnPassantSquare.Col - from.Col) == 1 {
-            validMoves = append(validMoves, *state.EnPassantSquare)
+    if ce.IsPromotionMove(piece, to) {
+        move.Flags |= MoveFlagPromotion
+        if pieceType, ok := ce.ParsePromotionPiece(promotion); ok {
+            move.Promotion = pieceType
         }
     }
     
-    return validMoves
+    return move
+}
+
+// Play a move on copies of the board and state, switching the side to move
+func (ce *ChessEngine) ApplyMove(game BitboardGame, state GameState, move Move) (BitboardGame, GameState) {
+    game.MakeMove(&state, move)
+    return game, state
+}
+
+// UCI long algebraic form ("e2e4", "e7e8q")
+func (m Move) UCI() string {
+    ce := &ChessEngine{}
+    uci := ce.PositionToAlgebraic(m.From) + ce.PositionToAlgebraic(m.To)
+    if m.Promotion != "" {
+        uci += strings.ToLower(pieceSymbols[m.Promotion])
+    }
+    return uci
+}
+
+func oppositeColor(color string) string {
+    if color == "white" {
+        return "black"
+    }
+    return "white"
 }
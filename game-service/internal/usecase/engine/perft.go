@@ -0,0 +1,142 @@
+package engine
+
+import (
+    "fmt"
+    "sort"
+)
+
+// Reference position with known perft node counts (index = depth - 1)
+type PerftPosition struct {
+    Name     string
+    FEN      string
+    Expected []int64
+}
+
+// Standard perft suite (https://www.chessprogramming.org/Perft_Results)
+var PerftPositions = []PerftPosition{
+    {
+        Name:     "start position",
+        FEN:      "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+        Expected: []int64{20, 400, 8902, 197281, 4865609},
+    },
+    {
+        Name:     "kiwipete",
+        FEN:      "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+        Expected: []int64{48, 2039, 97862, 4085603},
+    },
+    {
+        Name:     "position 3",
+        FEN:      "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+        Expected: []int64{14, 191, 2812, 43238, 674624},
+    },
+    {
+        Name:     "position 4",
+        FEN:      "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+        Expected: []int64{6, 264, 9467, 422333},
+    },
+    {
+        Name:     "position 4 mirrored",
+        FEN:      "r2q1rk1/pP1p2pp/Q4n2/bbp1p3/Np6/1B3NBn/pPPP1PPP/R3K2R b KQ - 0 1",
+        Expected: []int64{6, 264, 9467, 422333},
+    },
+    {
+        Name:     "position 5",
+        FEN:      "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+        Expected: []int64{44, 1486, 62379, 2103487},
+    },
+    {
+        Name:     "position 6",
+        FEN:      "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+        Expected: []int64{46, 2079, 89890, 3894594},
+    },
+    // Chess960 (Shredder-FEN castling fields)
+    {
+        Name:     "chess960 1",
+        FEN:      "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+        Expected: []int64{21, 528, 12189, 326672},
+    },
+    {
+        Name:     "chess960 2",
+        FEN:      "2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9",
+        Expected: []int64{21, 807, 18002, 667366},
+    },
+    {
+        Name:     "chess960 3",
+        FEN:      "b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9",
+        Expected: []int64{20, 479, 10471, 273318},
+    },
+}
+
+// Count leaf nodes of the legal move tree to the given depth
+func (ce *ChessEngine) Perft(game BitboardGame, state GameState, depth int) int64 {
+    return ce.perft(&game, &state, depth)
+}
+
+// Make/unmake in place, no board copies per node
+func (ce *ChessEngine) perft(game *BitboardGame, state *GameState, depth int) int64 {
+    if depth == 0 {
+        return 1
+    }
+
+    moves := ce.GenerateLegalMovesFor(*game, *state)
+    if depth == 1 {
+        return int64(len(moves))
+    }
+
+    var nodes int64
+    for _, move := range moves {
+        undo := game.MakeMove(state, move)
+        nodes += ce.perft(game, state, depth-1)
+        game.UnmakeMove(state, undo)
+    }
+
+    return nodes
+}
+
+// Perft split by root move (UCI), for tracking down generator bugs
+func (ce *ChessEngine) PerftDivide(game BitboardGame, state GameState, depth int) map[string]int64 {
+    result := make(map[string]int64)
+    if depth < 1 {
+        return result
+    }
+
+    for _, move := range ce.GenerateLegalMovesFor(game, state) {
+        nextGame, nextState := ce.ApplyMove(game, state, move)
+        result[move.UCI()] = ce.Perft(nextGame, nextState, depth-1)
+    }
+
+    return result
+}
+
+// Run perft on a reference position up to maxDepth and compare with expected counts
+func (ce *ChessEngine) VerifyPerft(position PerftPosition, maxDepth int) error {
+    game, state, err := ce.FENToGameState(position.FEN)
+    if err != nil {
+        return err
+    }
+
+    for depth := 1; depth <= maxDepth && depth <= len(position.Expected); depth++ {
+        nodes := ce.Perft(game, state, depth)
+        if nodes != position.Expected[depth-1] {
+            return fmt.Errorf("%s: perft(%d) = %d, expected %d\n%s",
+                position.Name, depth, nodes, position.Expected[depth-1],
+                formatDivide(ce.PerftDivide(game, state, depth)))
+        }
+    }
+
+    return nil
+}
+
+func formatDivide(divide map[string]int64) string {
+    keys := make([]string, 0, len(divide))
+    for key := range divide {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    out := ""
+    for _, key := range keys {
+        out += fmt.Sprintf("%s: %d\n", key, divide[key])
+    }
+    return out
+}
@@ -0,0 +1,40 @@
+package engine
+
+import (
+    "testing"
+)
+
+// go test -run '^$' -bench . ./internal/usecase/engine/
+func BenchmarkPerft(b *testing.B) {
+    chessEngine := &ChessEngine{}
+    for _, position := range PerftPositions[:2] {
+        game, state, err := chessEngine.FENToGameState(position.FEN)
+        if err != nil {
+            b.Fatal(err)
+        }
+        b.Run(position.Name, func(b *testing.B) {
+            var nodes int64
+            for i := 0; i < b.N; i++ {
+                nodes += chessEngine.Perft(game, state, 3)
+            }
+            b.ReportMetric(float64(nodes)/b.Elapsed().Seconds(), "nodes/s")
+        })
+    }
+}
+
+func BenchmarkGenerateLegalMoves(b *testing.B) {
+    chessEngine := &ChessEngine{}
+    for _, position := range PerftPositions[:2] {
+        game, state, err := chessEngine.FENToGameState(position.FEN)
+        if err != nil {
+            b.Fatal(err)
+        }
+        b.Run(position.Name, func(b *testing.B) {
+            moves := 0
+            for i := 0; i < b.N; i++ {
+                moves += len(chessEngine.GenerateLegalMovesFor(game, state))
+            }
+            b.ReportMetric(float64(moves)/b.Elapsed().Seconds(), "moves/s")
+        })
+    }
+}
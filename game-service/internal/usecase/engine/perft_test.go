@@ -0,0 +1,23 @@
+package engine
+
+import (
+    "testing"
+)
+
+// Runs the reference suite; depth 4 (millions of nodes) only without -short
+func TestPerft(t *testing.T) {
+    depth := 4
+    if testing.Short() {
+        depth = 3
+    }
+
+    chessEngine := &ChessEngine{}
+    for _, position := range PerftPositions {
+        position := position
+        t.Run(position.Name, func(t *testing.T) {
+            if err := chessEngine.VerifyPerft(position, depth); err != nil {
+                t.Fatal(err)
+            }
+        })
+    }
+}
@@ -0,0 +1,245 @@
+package engine
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// SAN move pattern: piece, from file, from rank, capture, target, promotion
+var sanPattern = regexp.MustCompile(`^([NBRQK])?([a-h])?([1-8])?(x)?([a-h][1-8])(?:=?([NBRQ]))?$`)
+
+// SAN piece letters (pawns have none)
+var pieceSymbols = map[string]string{
+    "king":   "K",
+    "queen":  "Q",
+    "rook":   "R",
+    "bishop": "B",
+    "knight": "N",
+    "pawn":   "",
+}
+
+// SAN letter -> piece type
+var sanPieceTypes = map[string]string{
+    "":  "pawn",
+    "N": "knight",
+    "B": "bishop",
+    "R": "rook",
+    "Q": "queen",
+    "K": "king",
+}
+
+// Encode move as Standard Algebraic Notation from the position before the move
+func (ce *ChessEngine) MoveToSAN(game BitboardGame, state GameState, from, to Position, promotion string) string {
+    piece := ce.GetPieceAt(game, from)
+    if piece == nil {
+        return ""
+    }
+
+    notation := ""
+    if ce.IsCastlingMove(game, state, piece, from, to) {
+        if ce.isKingSideCastling(state.CastlingRights, from, to) {
+            notation = "O-O"
+        } else {
+            notation = "O-O-O"
+        }
+    } else {
+        isCapture := ce.GetPieceAt(game, to) != nil || ce.IsEnPassantMove(piece, state, to)
+
+        if piece.Type == "pawn" {
+            if isCapture {
+                notation += string(rune('a' + from.Col)) + "x"
+            }
+            notation += ce.PositionToAlgebraic(to)
+
+            if ce.IsPromotionMove(piece, to) {
+                if pieceType, ok := ce.ParsePromotionPiece(promotion); ok {
+                    notation += "=" + pieceSymbols[pieceType]
+                }
+            }
+        } else {
+            notation += pieceSymbols[piece.Type]
+            notation += ce.disambiguation(game, state, *piece, from, to)
+            if isCapture {
+                notation += "x"
+            }
+            notation += ce.PositionToAlgebraic(to)
+        }
+    }
+
+    return notation + ce.checkSuffix(game, state, from, to, promotion, piece.Color)
+}
+
+// Resolve SAN string to a legal move for the side to move
+func (ce *ChessEngine) ParseSAN(game BitboardGame, state GameState, san string) (Position, Position, string, error) {
+    normalized := normalizeSAN(san)
+    if normalized == "" {
+        return Position{}, Position{}, "", fmt.Errorf("empty SAN")
+    }
+
+    // Castling
+    if normalized == "O-O" || normalized == "O-O-O" {
+        kings := ce.ConvertBitboardToCoordinates(ce.getPieceBitboard(game, Piece{Type: "king", Color: state.ActiveColor}))
+        if len(kings) != 1 {
+            return Position{}, Position{}, "", fmt.Errorf("no king for %s", state.ActiveColor)
+        }
+        from := kings[0]
+        to := castlingTarget(state.CastlingRights, from, normalized == "O-O")
+        if !ce.ValidateMove(game, state, from, to, state.ActiveColor) {
+            return Position{}, Position{}, "", fmt.Errorf("illegal castling: %s", san)
+        }
+        return from, to, "", nil
+    }
+
+    parts := sanPattern.FindStringSubmatch(normalized)
+    if parts == nil {
+        return Position{}, Position{}, "", fmt.Errorf("invalid SAN: %s", san)
+    }
+
+    pieceType := sanPieceTypes[parts[1]]
+    fromFile, fromRank := parts[2], parts[3]
+    to := ce.AlgebraicToPosition(parts[5])
+    promotion := ""
+    if parts[6] != "" {
+        promotion = sanPieceTypes[parts[6]]
+    }
+
+    candidates := ce.ConvertBitboardToCoordinates(ce.getPieceBitboard(game, Piece{Type: pieceType, Color: state.ActiveColor}))
+    var matches []Position
+    for _, from := range candidates {
+        if fromFile != "" && int(fromFile[0]-'a') != from.Col {
+            continue
+        }
+        if fromRank != "" && int(fromRank[0]-'1') != from.Row {
+            continue
+        }
+        if !ce.ValidateMove(game, state, from, to, state.ActiveColor) {
+            continue
+        }
+        matches = append(matches, from)
+    }
+
+    if len(matches) == 0 {
+        return Position{}, Position{}, "", fmt.Errorf("illegal move: %s", san)
+    }
+    if len(matches) > 1 {
+        return Position{}, Position{}, "", fmt.Errorf("ambiguous move: %s", san)
+    }
+
+    piece := &Piece{Type: pieceType, Color: state.ActiveColor}
+    if ce.IsPromotionMove(piece, to) {
+        if promotion == "" {
+            return Position{}, Position{}, "", fmt.Errorf("promotion piece required: %s", san)
+        }
+    } else if promotion != "" {
+        return Position{}, Position{}, "", fmt.Errorf("unexpected promotion: %s", san)
+    }
+
+    return matches[0], to, promotion, nil
+}
+
+// Check if king move is castling (two squares sideways, or onto an own rook in Chess960)
+func (ce *ChessEngine) IsCastlingMove(game BitboardGame, state GameState, piece *Piece, from, to Position) bool {
+    if piece == nil || piece.Type != "king" || from.Row != to.Row {
+        return false
+    }
+    if state.CastlingRights.Chess960 {
+        target := ce.GetPieceAt(game, to)
+        return target != nil && target.Type == "rook" && target.Color == piece.Color
+    }
+    return abs(to.Col-from.Col) == 2
+}
+
+// Check if pawn move captures en passant
+func (ce *ChessEngine) IsEnPassantMove(piece *Piece, state GameState, to Position) bool {
+    return piece != nil && piece.Type == "pawn" && state.EnPassantSquare != nil &&
+        to.Row == state.EnPassantSquare.Row && to.Col == state.EnPassantSquare.Col
+}
+
+// File/rank/square prefix needed when several pieces of the same type reach the target
+func (ce *ChessEngine) disambiguation(game BitboardGame, state GameState, piece Piece, from, to Position) string {
+    others := ce.ConvertBitboardToCoordinates(ce.getPieceBitboard(game, piece))
+
+    ambiguous, sameFile, sameRank := false, false, false
+    for _, other := range others {
+        if other == from {
+            continue
+        }
+        if !ce.ValidateMove(game, state, other, to, piece.Color) {
+            continue
+        }
+        ambiguous = true
+        if other.Col == from.Col {
+            sameFile = true
+        }
+        if other.Row == from.Row {
+            sameRank = true
+        }
+    }
+
+    if !ambiguous {
+        return ""
+    }
+    if !sameFile {
+        return string(rune('a' + from.Col))
+    }
+    if !sameRank {
+        return fmt.Sprintf("%d", from.Row+1)
+    }
+    return ce.PositionToAlgebraic(from)
+}
+
+// "+" for check, "#" for checkmate
+func (ce *ChessEngine) checkSuffix(game BitboardGame, state GameState, from, to Position, promotion string, color string) string {
+    move := ce.NewMove(game, state, from, to, promotion)
+    if move.Flags&MoveFlagPromotion != 0 && move.Promotion == "" {
+        return ""
+    }
+
+    gameAfter := ce.CloneBitboards(game)
+    stateAfter := state
+    ce.PlayMove(&gameAfter, &stateAfter, move)
+
+    opponentColor := oppositeColor(color)
+    if !ce.variantOf(stateAfter).IsInCheck(gameAfter, opponentColor) {
+        return ""
+    }
+    if ce.IsCheckmate(gameAfter, stateAfter) {
+        return "#"
+    }
+    return "+"
+}
+
+// Get bitboard for piece type and color
+func (ce *ChessEngine) getPieceBitboard(game BitboardGame, piece Piece) uint64 {
+    if piece.Color == "white" {
+        switch piece.Type {
+        case "pawn": return game.WhitePawns
+        case "knight": return game.WhiteKnights
+        case "bishop": return game.WhiteBishops
+        case "rook": return game.WhiteRooks
+        case "queen": return game.WhiteQueens
+        case "king": return game.WhiteKing
+        }
+    } else {
+        switch piece.Type {
+        case "pawn": return game.BlackPawns
+        case "knight": return game.BlackKnights
+        case "bishop": return game.BlackBishops
+        case "rook": return game.BlackRooks
+        case "queen": return game.BlackQueens
+        case "king": return game.BlackKing
+        }
+    }
+    return 0
+}
+
+// Strip annotations and normalize castling zeros
+func normalizeSAN(san string) string {
+    s := strings.TrimSpace(san)
+    s = strings.TrimRight(s, "+#!?")
+    s = strings.TrimSuffix(s, "e.p.")
+    s = strings.TrimSpace(s)
+    s = strings.ReplaceAll(s, "0", "O")
+    return s
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+    "testing"
+)
+
+// MoveToSAN and ParseSAN agree on each move
+func TestSAN(t *testing.T) {
+    tests := []struct {
+        name      string
+        fen       string
+        from, to  string
+        promotion string
+        want      string
+    }{
+        {"no disambiguation", "4k3/8/8/8/8/8/8/1N2K3 w - - 0 1", "b1", "c3", "", "Nc3"},
+        {"file disambiguation", "4k3/8/8/8/8/8/8/1N3N1K w - - 0 1", "b1", "d2", "", "Nbd2"},
+        {"rank disambiguation", "4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a1", "a3", "", "R1a3"},
+        {"square disambiguation", "2k5/8/8/8/4Q2Q/8/8/K6Q w - - 0 1", "h4", "e1", "", "Qh4e1"},
+        {"capture", "4k3/8/8/3p4/8/8/8/3RK3 w - - 0 1", "d1", "d5", "", "Rxd5"},
+        {"check", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1", "a8", "", "Ra8+"},
+        {"mate", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", "a1", "a8", "", "Ra8#"},
+        {"promotion", "4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7", "a8", "queen", "a8=Q+"},
+        {"under-promotion", "4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7", "a8", "knight", "a8=N"},
+        {"capture promotion", "1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7", "b8", "queen", "axb8=Q+"},
+        {"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5", "d6", "", "exd6"},
+        {"castling", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1", "g1", "", "O-O"},
+        {"castling queenside", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8", "c8", "", "O-O-O"},
+        // Chess960: the king moves onto its own rook
+        {"960 castling", "1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1", "g1", "h1", "", "O-O"},
+        {"960 castling queenside", "1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1", "g1", "b1", "", "O-O-O"},
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            game, state, err := ce.FENToGameState(test.fen)
+            if err != nil {
+                t.Fatal(err)
+            }
+            from, to := ce.AlgebraicToPosition(test.from), ce.AlgebraicToPosition(test.to)
+
+            if got := ce.MoveToSAN(game, state, from, to, test.promotion); got != test.want {
+                t.Fatalf("MoveToSAN: got %s, want %s", got, test.want)
+            }
+
+            parsedFrom, parsedTo, promotion, err := ce.ParseSAN(game, state, test.want)
+            if err != nil {
+                t.Fatalf("ParseSAN(%s): %v", test.want, err)
+            }
+            if parsedFrom != from || parsedTo != to || promotion != test.promotion {
+                t.Fatalf("ParseSAN(%s): got %v-%v %q", test.want, parsedFrom, parsedTo, promotion)
+            }
+        })
+    }
+}
+
+func TestParseSANRejects(t *testing.T) {
+    tests := []struct {
+        fen string
+        san string
+    }{
+        {"4k3/8/8/8/8/8/8/1N3N1K w - - 0 1", "Nd2"},   // Ambiguous
+        {"4k3/8/8/8/8/8/8/4K3 w - - 0 1", "Nf3"},      // No such piece
+        {"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a8"},      // Promotion piece missing
+        {"4k3/8/8/8/8/8/P7/4K3 w - - 0 1", "a3=Q"},    // Not a promotion
+        {"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "O-O"},     // No castling rights
+        {"4k3/8/8/8/8/8/8/4K3 w - - 0 1", "Kz9"},      // Not SAN
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        game, state, err := ce.FENToGameState(test.fen)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if _, _, _, err := ce.ParseSAN(game, state, test.san); err == nil {
+            t.Fatalf("%s accepted in %s", test.san, test.fen)
+        }
+    }
+}
@@ -1,14 +1,13 @@
 package engine
 
-import ("fmt")
+import (
+    "fmt"
+    "strings"
+)
 
 // Create initial server game state
 func (ce *ChessEngine) CreateServerGameState() *ServerGameState {
-    initialFen := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
-    
-    return &ServerGameState{
-        CurrentFen:  initialFen,
-        Bitboards:   ce.CreateBitboardGame(),
+    return ce.newServerGameState(ce.CreateBitboardGame(), GameState{
         ActiveColor: "white",
         CastlingRights: CastlingRights{
             WhiteKingSide:  true,
@@ -16,60 +15,137 @@ func (ce *ChessEngine) CreateServerGameState() *ServerGameState {
             BlackKingSide:  true,
             BlackQueenSide: true,
         },
-        EnPassantSquare: nil,
+        MoveCount: 1,
+        Variant:   VariantStandard,
+    })
+}
+
+// Create initial server game state for a variant; seed picks randomized setups (Chess960)
+func (ce *ChessEngine) CreateVariantGameState(variant string, seed int64) (*ServerGameState, error) {
+    rules, err := GetVariant(variant)
+    if err != nil {
+        return nil, err
+    }
+    game, rights, err := rules.InitialPosition(seed)
+    if err != nil {
+        return nil, err
+    }
+    return ce.newServerGameState(game, GameState{
+        ActiveColor:    "white",
+        CastlingRights: rights,
+        MoveCount:      1,
+        Variant:        rules.Name(),
+    }), nil
+}
+
+// Create server game state from a FEN (X-FEN/Shredder-FEN castling accepted), rejecting illegal positions
+func (ce *ChessEngine) CreateServerGameStateFromFEN(fen string, variant string) (*ServerGameState, error) {
+    rules, err := GetVariant(variant)
+    if err != nil {
+        return nil, err
+    }
+
+    game, gameState, err := ce.FENToGameState(fen)
+    if err != nil {
+        return nil, err
+    }
+    gameState.Variant = rules.Name()
+
+    // Chess960 games castle with king-onto-rook moves even from the standard setup
+    if gameState.Variant == VariantChess960 && !gameState.CastlingRights.Chess960 {
+        gameState.CastlingRights.Chess960 = true
+        gameState.CastlingRights.WhiteKingSideRookFile = 7
+        gameState.CastlingRights.BlackKingSideRookFile = 7
+        gameState.CastlingRights.WhiteQueenSideRookFile = 0
+        gameState.CastlingRights.BlackQueenSideRookFile = 0
+    }
+
+    if err := ce.ValidatePosition(game, gameState); err != nil {
+        return nil, err
+    }
+
+    return ce.newServerGameState(game, gameState), nil
+}
+
+// Server state for a starting position
+func (ce *ChessEngine) newServerGameState(game BitboardGame, gameState GameState) *ServerGameState {
+    gameState.ZobristKey = ce.ComputeZobristKey(game, gameState)
+    initialFen := ce.GameStateToFEN(game, gameState)
+    
+    state := &ServerGameState{
+        Variant:     gameState.Variant,
+        InitialFen:  initialFen,
+        CurrentFen:  initialFen,
+        Bitboards:   game,
+        ActiveColor: gameState.ActiveColor,
+        CastlingRights: gameState.CastlingRights,
+        EnPassantSquare: gameState.EnPassantSquare,
         //MoveHistory:     []MoveNotation,
-        FullMoveNumber:  1,
-        HalfMoveClock:   0,
-        PositionCounts: map[string]int{
-            initialFen: 1,
+        FullMoveNumber:  gameState.MoveCount,
+        HalfMoveClock:   gameState.HalfMoveClock,
+        ZobristKey:      gameState.ZobristKey,
+        PositionCounts: map[uint64]int{
+            gameState.ZobristKey: 1,
         },
         MaterialCount: map[string]MaterialCount{
-            "white": {
-                Pawns:   8,
-                Knights: 2,
-                Bishops: 2,
-                Rooks:   2,
-                Queens:  1,
-            },
-            "black": {
-                Pawns:   8,
-                Knights: 2,
-                Bishops: 2,
-                Rooks:   2,
-                Queens:  1,
-            },
+            "white": ce.CountMaterial(game, "white"),
+            "black": ce.CountMaterial(game, "black"),
         },
     }
+
+    return state
+}
+
+// Material on the board for one color
+func (ce *ChessEngine) CountMaterial(game BitboardGame, color string) MaterialCount {
+    count := ce.CountPieces(game, color)
+    return MaterialCount{
+        Pawns:   count.Pawns,
+        Knights: count.Knights,
+        Bishops: count.Bishops,
+        Rooks:   count.Rooks,
+        Queens:  count.Queens,
+    }
 }
 
 // Update material count after move
-func (ce *ChessEngine) UpdateMaterialCount(state *ServerGameState, capturedPiece *Piece) {
-    if capturedPiece == nil {
-        return
+func (ce *ChessEngine) UpdateMaterialCount(state *ServerGameState, capturedPiece *Piece, promotedPiece *Piece) {
+    if capturedPiece != nil {
+        ce.adjustMaterial(state, *capturedPiece, -1)
     }
     
-    materialCount := state.MaterialCount[capturedPiece.Color]
+    // Promotion: pawn leaves the board, promoted piece joins it
+    if promotedPiece != nil {
+        ce.adjustMaterial(state, Piece{Type: "pawn", Color: promotedPiece.Color}, -1)
+        ce.adjustMaterial(state, *promotedPiece, 1)
+    }
+}
+
+func (ce *ChessEngine) adjustMaterial(state *ServerGameState, piece Piece, delta int) {
+    materialCount := state.MaterialCount[piece.Color]
     
-    switch capturedPiece.Type {
+    switch piece.Type {
     case "pawn":
-        materialCount.Pawns--
+        materialCount.Pawns += delta
     case "knight":
-        materialCount.Knights--
+        materialCount.Knights += delta
     case "bishop":
-        materialCount.Bishops--
+        materialCount.Bishops += delta
     case "rook":
-        materialCount.Rooks--
+        materialCount.Rooks += delta
     case "queen":
-        materialCount.Queens--
+        materialCount.Queens += delta
     }
     
-    state.MaterialCount[capturedPiece.Color] = materialCount
+    state.MaterialCount[piece.Color] = materialCount
 }
 
-// Update position counts for threefold repetition
-func (ce *ChessEngine) UpdatePositionCounts(state *ServerGameState, newFen string) {
-    state.PositionCounts[newFen]++
-    state.CurrentFen = newFen
+// Update position counts for repetition detection
+func (ce *ChessEngine) UpdatePositionCounts(state *ServerGameState, key uint64) {
+    if state.PositionCounts == nil {
+        state.PositionCounts = make(map[uint64]int)
+    }
+    state.PositionCounts[key]++
 }
 
 // Add move to history
@@ -107,168 +183,138 @@ func (ce *ChessEngine) UpdatePositionCounts(state *ServerGameState, newFen strin
 //     return pieceSymbol + fromSquare + toSquare
 // }
 
-// Complete server move execution
-func (ce *ChessEngine) BuildNotation(gameBefore BitboardGame, stateBefore GameState, gameAfter BitboardGame, stateAfter GameState, from, to Position) string {
-    piece := ce.GetPieceAt(gameBefore, from)
-    if piece == nil {
-        return ""
-    }
-
-    // Ký hiệu quân cờ
-    pieceSymbol := ""
-    switch piece.Type {
-    case "king":
-        pieceSymbol = "K"
-    case "queen":
-        pieceSymbol = "Q"
-    case "rook":
-        pieceSymbol = "R"
-    case "bishop":
-        pieceSymbol = "B"
-    case "knight":
-        pieceSymbol = "N"
-    case "pawn":
-        pieceSymbol = ""
-    }
+// Build SAN for a move from the position before it was played
+func (ce *ChessEngine) BuildNotation(gameBefore BitboardGame, stateBefore GameState, from, to Position, promotion string) string {
+    return ce.MoveToSAN(gameBefore, stateBefore, from, to, promotion)
+}
 
-    // Nhập thành
-    if piece.Type == "king" && abs(to.Col-from.Col) == 2 {
-        if to.Col == 6 {
-            return "O-O"
-        }
-        if to.Col == 2 {
-            return "O-O-O"
-        }
-    }
+// What a server move changed beyond MoveUndo, so UndoServerMove can take it back.
+// Material, FEN and move history are derived or trimmed instead of stored.
+type ServerMoveUndo struct {
+    MoveUndo
+    Board        *BitboardGame `json:"board,omitempty"` // Before an Atomic blast, which UnmakeMove can't put back
+    VariantState VariantState  `json:"variantState"`
+}
 
-    // Phong cấp
-    promotion := ""
-    if piece.Type == "pawn" && (to.Row == 0 || to.Row == 7) {
-        // Giả sử luôn phong thành hậu
-        promotion = "=Q"
-    }
+func (ce *ChessEngine) ExecuteServerMove(state *ServerGameState, from Position, to Position, promotion string) bool {
+    _, ok := ce.PlayServerMove(state, from, to, promotion)
+    return ok
+}
 
-    // Ăn quân
-    captured := ce.GetPieceAt(gameBefore, to)
-    isCapture := captured != nil
-    // Ăn en passant
-    if piece.Type == "pawn" && stateBefore.EnPassantSquare != nil &&
-        to.Row == stateBefore.EnPassantSquare.Row && to.Col == stateBefore.EnPassantSquare.Col {
-        isCapture = true
+// Validate and play a move, returning what UndoServerMove needs to take it back
+func (ce *ChessEngine) PlayServerMove(state *ServerGameState, from Position, to Position, promotion string) (ServerMoveUndo, bool) {
+    // Validate move
+    gameState := state.GameState()
+    
+    if !ce.ValidateMove(state.Bitboards, gameState, from, to, state.ActiveColor) {
+        return ServerMoveUndo{}, false
     }
+    
+    move := ce.NewMove(state.Bitboards, gameState, from, to, promotion)
 
-    // Tên cột from (dùng cho tốt ăn)
-    fromFile := string(rune('a' + from.Col))
-
-    // Xây dựng notation
-    notation := ""
-    if piece.Type == "pawn" {
-        if isCapture {
-            notation += fromFile + "x" + ce.PositionToAlgebraic(to)
-        } else {
-            notation += ce.PositionToAlgebraic(to)
-        }
-    } else {
-        notation += pieceSymbol
-        // Nếu có nhiều quân cùng loại có thể đi đến ô đó, cần thêm disambiguation (bạn có thể bổ sung sau)
-        if isCapture {
-            notation += "x"
+    // Pawn reaching the last rank must name its promotion piece
+    var promotedPiece *Piece
+    if move.Flags&MoveFlagPromotion != 0 {
+        if move.Promotion == "" {
+            return ServerMoveUndo{}, false
         }
-        notation += ce.PositionToAlgebraic(to)
+        promotedPiece = &Piece{Type: move.Promotion, Color: move.Piece.Color}
     }
 
-    notation += promotion
-
-    // Kiểm tra chiếu/chiếu hết
-    opponentColor := "black"
-    if piece.Color == "black" {
-        opponentColor = "white"
+    undo := ServerMoveUndo{VariantState: state.VariantState.clone()}
+    board := state.Bitboards
+    
+    // Execute the move (switches side to move, updates clocks and Zobrist key)
+    undo.MoveUndo = state.Bitboards.MakeMove(&gameState, move)
+    exploded := ce.variantOf(gameState).ApplyMoveEffects(&state.Bitboards, &gameState, move)
+    if len(exploded) > 0 {
+        undo.Board = &board
     }
-    if ce.IsInCheck(gameAfter, opponentColor) {
-        if ce.IsCheckmate(gameAfter, stateAfter) {
-            notation += "#"
-        } else {
-            notation += "+"
+    
+    // Update server state from game state
+    state.ActiveColor = gameState.ActiveColor
+    state.CastlingRights = gameState.CastlingRights
+    state.EnPassantSquare = gameState.EnPassantSquare
+    state.HalfMoveClock = gameState.HalfMoveClock
+    state.FullMoveNumber = gameState.MoveCount
+    state.ZobristKey = gameState.ZobristKey
+    
+    // Update material count (promoted piece may already be gone in an Atomic blast)
+    ce.UpdateMaterialCount(state, move.Captured, promotedPiece)
+    for _, piece := range exploded {
+        if piece.Type != "king" {
+            ce.adjustMaterial(state, piece, -1)
         }
     }
 
-    return notation
+    ce.variantOf(gameState).AfterMove(state, move)
+    
+    // Update FEN and position counts
+    state.CurrentFen = ce.GameStateToFEN(state.Bitboards, gameState)
+    ce.UpdatePositionCounts(state, state.ZobristKey)
+    
+    return undo, true
 }
 
-func (ce *ChessEngine) ExecuteServerMove(state *ServerGameState, from Position, to Position) bool {
-    // Validate move
-    gameState := GameState{
-    ActiveColor:     state.ActiveColor,
-    CastlingRights:  state.CastlingRights,
-    EnPassantSquare: state.EnPassantSquare,
-    MoveCount:       state.FullMoveNumber,
-    HalfMoveClock:   state.HalfMoveClock,
-    }
-    
-    if !ce.ValidateMove(state.Bitboards, gameState, from, to, state.ActiveColor) {
-        return false
+// Take back a move played with PlayServerMove. The caller trims MoveHistory.
+func (ce *ChessEngine) UndoServerMove(state *ServerGameState, undo ServerMoveUndo) {
+    // The position the move led to no longer happened
+    if state.PositionCounts[state.ZobristKey] > 1 {
+        state.PositionCounts[state.ZobristKey]--
+    } else {
+        delete(state.PositionCounts, state.ZobristKey)
     }
-    
-    // Get piece and check for capture
-    piece := ce.GetPieceAt(state.Bitboards, from)
-    capturedPiece := ce.GetPieceAt(state.Bitboards, to)
 
-    // Update half-move clock
-    if piece.Type == "pawn" || capturedPiece != nil {
-        state.HalfMoveClock = 0
+    gameState := state.GameState()
+    if undo.Board != nil {
+        state.Bitboards = *undo.Board
+        undo.MoveUndo.restore(&gameState)
     } else {
-        state.HalfMoveClock++
+        state.Bitboards.UnmakeMove(&gameState, undo.MoveUndo)
     }
-    
-    // Execute the move
-    ce.ExecuteMove(&state.Bitboards, &gameState, from, to)
-    
-    // Update server state from game state
+
     state.ActiveColor = gameState.ActiveColor
     state.CastlingRights = gameState.CastlingRights
     state.EnPassantSquare = gameState.EnPassantSquare
+    state.HalfMoveClock = gameState.HalfMoveClock
+    state.FullMoveNumber = gameState.MoveCount
+    state.ZobristKey = gameState.ZobristKey
+    state.VariantState = undo.VariantState
+    state.MaterialCount = map[string]MaterialCount{
+        "white": ce.CountMaterial(state.Bitboards, "white"),
+        "black": ce.CountMaterial(state.Bitboards, "black"),
+    }
+    state.CurrentFen = ce.GameStateToFEN(state.Bitboards, gameState)
+}
 
-    // Update full move number
-    if state.ActiveColor == "white" {
-        state.FullMoveNumber++
+// Engine view of the server state
+func (state *ServerGameState) GameState() GameState {
+    return GameState{
+        ActiveColor:     state.ActiveColor,
+        CastlingRights:  state.CastlingRights,
+        EnPassantSquare: state.EnPassantSquare,
+        MoveCount:       state.FullMoveNumber,
+        HalfMoveClock:   state.HalfMoveClock,
+        ZobristKey:      state.ZobristKey,
+        Variant:         state.Variant,
     }
-    
-    // Update material count
-    ce.UpdateMaterialCount(state, capturedPiece)
-    
-    // Add move to history
-    // moveNotation := ce.MoveToAlgebraic(state.Bitboards, from, to)
-    // ce.AddMoveToHistory(state, moveNotation)
-    
-    // Update FEN and position counts
-    newFen := ce.GameStateToFEN(state.Bitboards, gameState)
-    ce.UpdatePositionCounts(state, newFen)
-    
-    return true
 }
 
-// Convert game state to FEN notation (simplified)
+// Convert game state to FEN notation
 func (ce *ChessEngine) GameStateToFEN(game BitboardGame, state GameState) string {
-    // This is a simplified version - you may want to implement full FEN conversion
-    board := ce.BitboardsToFENBoard(game)
-    activeColor := "w"
-    if state.ActiveColor == "black" {
-        activeColor = "b"
-    }
-    
     castling := ce.CastlingRightsToFEN(state.CastlingRights)
-    enPassant := "-"
-    if state.EnPassantSquare != nil {
-        enPassant = ce.PositionToAlgebraic(*state.EnPassantSquare)
+    if state.CastlingRights.Chess960 {
+        castling = ce.CastlingRightsToXFEN(game, state.CastlingRights)
     }
-    
-    return fmt.Sprintf("%s %s %s %s 0 %d", board, activeColor, castling, enPassant, state.MoveCount)
-}
 
-// Helper: Convert bitboards to FEN board representation
-func (ce *ChessEngine) BitboardsToFENBoard(game BitboardGame) string {
-    // This is a placeholder - implement full FEN board conversion
-    return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR"
+    return ce.BitboardToFEN(
+        game,
+        state.ActiveColor,
+        castling,
+        state.EnPassantSquare.ToFEN(),
+        state.HalfMoveClock,
+        state.MoveCount,
+    )
 }
 
 // Helper: Convert castling rights to FEN format
@@ -293,6 +339,91 @@ func (ce *ChessEngine) CastlingRightsToFEN(rights CastlingRights) string {
     return result
 }
 
+// Convert game state to Shredder-FEN (castling rights as rook files, e.g. "HAha")
+func (ce *ChessEngine) GameStateToShredderFEN(game BitboardGame, state GameState) string {
+    return ce.BitboardToFEN(
+        game,
+        state.ActiveColor,
+        ce.CastlingRightsToShredderFEN(state.CastlingRights),
+        state.EnPassantSquare.ToFEN(),
+        state.HalfMoveClock,
+        state.MoveCount,
+    )
+}
+
+// Shredder-FEN castling field: rook file letters, uppercase for white
+func (ce *ChessEngine) CastlingRightsToShredderFEN(rights CastlingRights) string {
+    file := func(col int) string { return string(rune('a' + col)) }
+
+    result := ""
+    if rights.WhiteKingSide {
+        result += strings.ToUpper(file(rights.KingSideRookCol("white")))
+    }
+    if rights.WhiteQueenSide {
+        result += strings.ToUpper(file(rights.QueenSideRookCol("white")))
+    }
+    if rights.BlackKingSide {
+        result += file(rights.KingSideRookCol("black"))
+    }
+    if rights.BlackQueenSide {
+        result += file(rights.QueenSideRookCol("black"))
+    }
+
+    if result == "" {
+        return "-"
+    }
+    return result
+}
+
+// X-FEN castling field: KQkq when the castling rook is the outermost one on its side,
+// the rook file letter otherwise
+func (ce *ChessEngine) CastlingRightsToXFEN(game BitboardGame, rights CastlingRights) string {
+    symbol := func(color string, kingSide bool) string {
+        row, letter := 0, "K"
+        if color == "black" {
+            row = 7
+        }
+        rookCol := rights.KingSideRookCol(color)
+        edge, step := 7, 1
+        if !kingSide {
+            rookCol, edge, step, letter = rights.QueenSideRookCol(color), 0, -1, "Q"
+        }
+
+        // Another rook further out on the same side would be the one KQkq refers to
+        for col := rookCol + step; col != edge+step; col += step {
+            piece := ce.GetPieceAt(game, Position{Row: row, Col: col})
+            if piece != nil && piece.Type == "rook" && piece.Color == color {
+                letter = strings.ToUpper(string(rune('a' + rookCol)))
+                break
+            }
+        }
+
+        if color == "black" {
+            return strings.ToLower(letter)
+        }
+        return letter
+    }
+
+    result := ""
+    if rights.WhiteKingSide {
+        result += symbol("white", true)
+    }
+    if rights.WhiteQueenSide {
+        result += symbol("white", false)
+    }
+    if rights.BlackKingSide {
+        result += symbol("black", true)
+    }
+    if rights.BlackQueenSide {
+        result += symbol("black", false)
+    }
+
+    if result == "" {
+        return "-"
+    }
+    return result
+}
+
 // Convert position to algebraic notation (for logging/debugging)
 func (ce *ChessEngine) PositionToAlgebraic(pos Position) string {
     file := string(rune('a' + pos.Col))
@@ -307,7 +438,11 @@ func (ce *ChessEngine) AlgebraicToPosition(algebraic string) Position {
     }
     
     col := int(algebraic[0] - 'a')
-    row := 8 - int(algebraic[1] - '0')
+    row := int(algebraic[1] - '1')
     
-    return Position{Row: row, Col: col}
+    pos := Position{Row: row, Col: col}
+    if !ce.IsValidSquare(pos) {
+        return Position{Row: -1, Col: -1}
+    }
+    return pos
 }
@@ -0,0 +1,81 @@
+package engine
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+// Undoing server moves one by one gives back every earlier state exactly
+func TestUndoServerMove(t *testing.T) {
+    tests := []struct {
+        variant string
+        fen     string
+    }{
+        {VariantStandard, "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"},
+        {VariantStandard, "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1"},
+        {VariantStandard, "n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1"},
+        {VariantChess960, "1r4kr/pppppppp/8/8/8/8/PPPPPPPP/1R4KR w HBhb - 0 1"},
+        {VariantThreeCheck, "r1bqkbnr/pppp1ppp/2n5/4p3/2B1P3/5Q2/PPPP1PPP/RNB1K1NR w KQkq - 0 1"},
+        {VariantAtomic, "r1bqkbnr/pppp1ppp/2n5/4p3/2B1P3/5Q2/PPPP1PPP/RNB1K1NR w KQkq - 0 1"},
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        t.Run(test.variant, func(t *testing.T) {
+            state, err := ce.CreateServerGameStateFromFEN(test.fen, test.variant)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            var before []string
+            var undos []ServerMoveUndo
+            for ply := 0; ply < 40; ply++ {
+                moves := ce.GenerateLegalMovesFor(state.Bitboards, state.GameState())
+                if len(moves) == 0 || state.Bitboards.WhiteKing == 0 || state.Bitboards.BlackKing == 0 {
+                    break
+                }
+                // Captures first, to get promotions, en passant and Atomic blasts in
+                move := moves[(ply*7)%len(moves)]
+                for _, candidate := range moves {
+                    if candidate.Captured != nil {
+                        move = candidate
+                        break
+                    }
+                }
+
+                before = append(before, stateJSON(t, state))
+                undo, ok := ce.PlayServerMove(state, move.From, move.To, move.Promotion)
+                if !ok {
+                    t.Fatalf("ply %d: legal move %s rejected", ply, move.UCI())
+                }
+
+                // Through JSON, as the undo stack is kept in the live-game snapshot
+                data, err := json.Marshal(undo)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                var decoded ServerMoveUndo
+                if err := json.Unmarshal(data, &decoded); err != nil {
+                    t.Fatal(err)
+                }
+                undos = append(undos, decoded)
+            }
+
+            for ply := len(undos) - 1; ply >= 0; ply-- {
+                ce.UndoServerMove(state, undos[ply])
+                if got := stateJSON(t, state); got != before[ply] {
+                    t.Fatalf("after undoing ply %d:\ngot  %s\nwant %s", ply, got, before[ply])
+                }
+            }
+        })
+    }
+}
+
+func stateJSON(t *testing.T, state *ServerGameState) string {
+    t.Helper()
+    data, err := json.Marshal(state)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return string(data)
+}
@@ -19,6 +19,26 @@ type MaterialCount struct {
     Queens  int `json:"queens"`
 }
 
+// Move flags
+const (
+    MoveFlagCapture = 1 << iota
+    MoveFlagDoublePush
+    MoveFlagEnPassant
+    MoveFlagCastleKingSide
+    MoveFlagCastleQueenSide
+    MoveFlagPromotion
+)
+
+// Fully described move
+type Move struct {
+    From      Position `json:"from"`
+    To        Position `json:"to"`
+    Piece     Piece    `json:"piece"`
+    Captured  *Piece   `json:"captured,omitempty"`
+    Promotion string   `json:"promotion,omitempty"` // "queen", "rook", "bishop", "knight"
+    Flags     int      `json:"flags"`
+}
+
 type MoveNotation struct {
     MoveNumber int    `json:"moveNumber"`
     White      string `json:"white"`
@@ -30,8 +50,25 @@ type CastlingRights struct {
     WhiteQueenSide bool `json:"whiteQueenSide"`
     BlackKingSide  bool `json:"blackKingSide"`
     BlackQueenSide bool `json:"blackQueenSide"`
+
+    // Chess960: castling rook files are fixed by the start position, per color
+    // (a FEN-set position may castle with different rooks on each side)
+    Chess960               bool `json:"chess960,omitempty"`
+    WhiteKingSideRookFile  int  `json:"whiteKingSideRookFile,omitempty"`
+    WhiteQueenSideRookFile int  `json:"whiteQueenSideRookFile,omitempty"`
+    BlackKingSideRookFile  int  `json:"blackKingSideRookFile,omitempty"`
+    BlackQueenSideRookFile int  `json:"blackQueenSideRookFile,omitempty"`
 }
 
+// Game variants
+const (
+    VariantStandard      = "standard"
+    VariantChess960      = "chess960"
+    VariantKingOfTheHill = "kingOfTheHill"
+    VariantThreeCheck    = "threeCheck"
+    VariantAtomic        = "atomic"
+)
+
 // Game state for internal logic
 type GameState struct {
     ActiveColor     string
@@ -39,10 +76,14 @@ type GameState struct {
     EnPassantSquare *Position
     MoveCount       int
 	HalfMoveClock   int
+    ZobristKey      uint64
+    Variant         string
 }
 
 // Complete server game state
 type ServerGameState struct {
+    Variant         string                 `json:"variant"`
+    InitialFen      string                 `json:"initialFen"`
     CurrentFen      string                 `json:"currentFen"`
     Bitboards       BitboardGame          `json:"bitboards"`
     ActiveColor     string                `json:"activeColor"`
@@ -51,16 +92,20 @@ type ServerGameState struct {
     MoveHistory     []MoveNotation        `json:"moveHistory"`
     FullMoveNumber  int                   `json:"fullMoveNumber"`
     HalfMoveClock   int                   `json:"halfMoveClock"`
-    PositionCounts  map[string]int        `json:"positionCounts"`
+    ZobristKey      uint64                `json:"zobristKey,string"`
+    PositionCounts  map[uint64]int        `json:"positionCounts"` // Zobrist key -> occurrences
     MaterialCount   map[string]MaterialCount `json:"materialCount"`
+    VariantState    VariantState          `json:"variantState"`
 }
 
 type ClientGameState struct {
+    Variant         string                 `json:"variant,omitempty"`
     CurrentFen      string                 `json:"currentFen"`
     Bitboards       BitboardGame          `json:"bitboards"`
     ActiveColor     string                `json:"activeColor"`
     CastlingRights  CastlingRights        `json:"castlingRights"`
     EnPassantSquare *Position             `json:"enPassantSquare"`
+    VariantState    VariantState          `json:"variantState"`
 }
 
 // Piece count for insufficient material check
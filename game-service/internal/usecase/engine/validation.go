@@ -1,8 +1,12 @@
 package engine
 
+import (
+    "fmt"
+)
+
 // Check for checkmate
 func (ce *ChessEngine) IsCheckmate(game BitboardGame, state GameState) bool {
-    if !ce.IsInCheck(game, state.ActiveColor) {
+    if !ce.variantOf(state).IsInCheck(game, state.ActiveColor) {
         return false
     }
     
@@ -13,7 +17,7 @@ func (ce *ChessEngine) IsCheckmate(game BitboardGame, state GameState) bool {
 
 // Check for stalemate
 func (ce *ChessEngine) IsStalemate(game BitboardGame, state GameState) bool {
-    if ce.IsInCheck(game, state.ActiveColor) {
+    if ce.variantOf(state).IsInCheck(game, state.ActiveColor) {
         return false
     }
     
@@ -39,17 +43,15 @@ func (ce *ChessEngine) GetAllLegalMoves(game BitboardGame, state GameState, colo
 }
 
 // Check if game is over
-func (ce *ChessEngine) IsGameOver(game BitboardGame, state GameState, positionCounts map[string]int, currentFen string) (bool, string) {
-    // Automatic draws (no claim needed)
-    if ce.IsThreefoldRepetition(positionCounts, currentFen) {
-        return true, "fivefold repetition"
-    }
+func (ce *ChessEngine) IsGameOver(game BitboardGame, state GameState, positionCounts map[uint64]int, variantState VariantState) (bool, string) {
+    variant := ce.variantOf(state)
 
-	if ce.IsFiftyMoveRule(state.HalfMoveClock) {
-		return true, "fifty move rule"
-	}
+    // Variant win conditions (king of the hill, third check, exploded king)
+    if over, reason := variant.GameOver(game, state, variantState); over {
+        return true, reason
+    }
 
-    // Checkmate/Stalemate
+    // Checkmate/Stalemate (mate on the 75th move still counts)
     if ce.IsCheckmate(game, state) {
         return true, "checkmate"
     }
@@ -57,9 +59,18 @@ func (ce *ChessEngine) IsGameOver(game BitboardGame, state GameState, positionCo
     if ce.IsStalemate(game, state) {
         return true, "stalemate"
     }
+
+    // Automatic draws (threefold and fifty moves only end the game when claimed)
+    if ce.IsFivefoldRepetition(positionCounts, state.ZobristKey) {
+        return true, "fivefold repetition"
+    }
+
+    if ce.IsSeventyFiveMoveRule(state.HalfMoveClock) {
+        return true, "seventy-five move rule"
+    }
     
     // Insufficient material
-    if ce.IsInsufficientMaterial(game) {
+    if variant.IsInsufficientMaterial(game) {
         return true, "insufficient material"
     }
     
@@ -128,9 +139,27 @@ func (ce *ChessEngine) IsFiftyMoveRule(halfMoveClock int) bool {
     return halfMoveClock >= 100 // 50 full moves = 100 half-moves
 }
 
-func (ce *ChessEngine) IsThreefoldRepetition(positionCounts map[string]int, currentFen string) bool {
-    count, exists := positionCounts[currentFen]
-    return exists && count >= 3
+func (ce *ChessEngine) IsSeventyFiveMoveRule(halfMoveClock int) bool {
+    return halfMoveClock >= 150
+}
+
+func (ce *ChessEngine) IsThreefoldRepetition(positionCounts map[uint64]int, key uint64) bool {
+    return positionCounts[key] >= 3
+}
+
+func (ce *ChessEngine) IsFivefoldRepetition(positionCounts map[uint64]int, key uint64) bool {
+    return positionCounts[key] >= 5
+}
+
+// Whether a draw can be claimed in the current position (threefold or fifty moves)
+func (ce *ChessEngine) CanClaimDraw(state *ServerGameState) (bool, string) {
+    if ce.IsThreefoldRepetition(state.PositionCounts, state.ZobristKey) {
+        return true, "threefold repetition"
+    }
+    if ce.IsFiftyMoveRule(state.HalfMoveClock) {
+        return true, "fifty move rule"
+    }
+    return false, ""
 }
 
 func (ce *ChessEngine) BishopsOnSameColorSquares(game BitboardGame) bool {
@@ -147,4 +176,65 @@ func (ce *ChessEngine) BishopsOnSameColorSquares(game BitboardGame) bool {
     blackSquareColor := (blackBishopPos[0].Row + blackBishopPos[0].Col) % 2
     
     return whiteSquareColor == blackSquareColor
-}
\ No newline at end of file
+}
+
+// Back ranks (rank 1 and rank 8)
+const backRanks uint64 = 0xFF000000000000FF
+
+// Check that a parsed position can arise in a game of the given variant
+func (ce *ChessEngine) ValidatePosition(game BitboardGame, state GameState) error {
+    if ce.CountBits(game.WhiteKing) != 1 || ce.CountBits(game.BlackKing) != 1 {
+        return fmt.Errorf("invalid position: each side needs exactly one king")
+    }
+
+    if (game.WhitePawns|game.BlackPawns)&backRanks != 0 {
+        return fmt.Errorf("invalid position: pawns on the first or last rank")
+    }
+
+    for _, color := range []string{"white", "black"} {
+        pieces := ce.GetAllPiecesOfColor(game, color)
+        pawns := *game.pieceBoard(Piece{Type: "pawn", Color: color})
+        if ce.CountBits(pieces) > 16 || ce.CountBits(pawns) > 8 {
+            return fmt.Errorf("invalid position: too many %s pieces", color)
+        }
+    }
+
+    // The side that just moved cannot have left its king in check
+    if ce.variantOf(state).IsInCheck(game, oppositeColor(state.ActiveColor)) {
+        return fmt.Errorf("invalid position: %s to move but %s king is in check", state.ActiveColor, oppositeColor(state.ActiveColor))
+    }
+
+    if state.CastlingRights.Chess960 && state.Variant != VariantChess960 {
+        return fmt.Errorf("invalid position: castling rights need the king on e-file and rooks on a/h files")
+    }
+
+    if err := ce.validateEnPassantSquare(game, state); err != nil {
+        return err
+    }
+
+    return nil
+}
+
+// En passant target must sit behind a pawn that just made a double push
+func (ce *ChessEngine) validateEnPassantSquare(game BitboardGame, state GameState) error {
+    ep := state.EnPassantSquare
+    if ep == nil {
+        return nil
+    }
+
+    // White to move: black pawn pushed from rank 7 to rank 5, target on rank 6
+    targetRow, pawnRow, fromRow := 5, 4, 6
+    if state.ActiveColor == "black" {
+        targetRow, pawnRow, fromRow = 2, 3, 1
+    }
+
+    pawn := ce.GetPieceAt(game, Position{Row: pawnRow, Col: ep.Col})
+    if ep.Row != targetRow ||
+        pawn == nil || pawn.Type != "pawn" || pawn.Color == state.ActiveColor ||
+        ce.GetPieceAt(game, *ep) != nil ||
+        ce.GetPieceAt(game, Position{Row: fromRow, Col: ep.Col}) != nil {
+        return fmt.Errorf("invalid position: en passant square %s does not follow a double pawn push", ep.ToFEN())
+    }
+
+    return nil
+}
@@ -0,0 +1,310 @@
+package engine
+
+import (
+    "fmt"
+)
+
+// Rules that differ between chess variants. Standard chess is the default implementation;
+// variants embed standardVariant and override what they change.
+type Variant interface {
+    Name() string
+
+    // Start position and castling rights (seed picks randomized setups such as Chess960)
+    InitialPosition(seed int64) (BitboardGame, CastlingRights, error)
+
+    // Whether a pseudo-legal move may be played (standard: own king not left in check)
+    IsLegalMove(game *BitboardGame, state GameState, move Move) bool
+
+    // Board side effects after MakeMove (Atomic explosions); returns pieces removed by them
+    ApplyMoveEffects(game *BitboardGame, state *GameState, move Move) []Piece
+
+    // Post-move hook on the server state (counters kept in VariantState)
+    AfterMove(state *ServerGameState, move Move)
+
+    // Whether color's king is in check under the variant's rules
+    IsInCheck(game BitboardGame, color string) bool
+
+    // Variant-specific end of game, checked before checkmate/stalemate/draw rules
+    GameOver(game BitboardGame, state GameState, variantState VariantState) (bool, string)
+
+    // Whether neither side can win any more
+    IsInsufficientMaterial(game BitboardGame) bool
+}
+
+// Variant-specific counters carried by ServerGameState
+type VariantState struct {
+    Checks map[string]int `json:"checks,omitempty"` // Three-check: checks given by each color
+}
+
+func (v VariantState) clone() VariantState {
+    if v.Checks == nil {
+        return v
+    }
+    checks := make(map[string]int, len(v.Checks))
+    for color, n := range v.Checks {
+        checks[color] = n
+    }
+    return VariantState{Checks: checks}
+}
+
+var standardRules Variant = standardVariant{}
+
+var variants = map[string]Variant{
+    VariantStandard:      standardRules,
+    VariantChess960:      chess960Variant{},
+    VariantKingOfTheHill: kingOfTheHillVariant{},
+    VariantThreeCheck:    threeCheckVariant{},
+    VariantAtomic:        atomicVariant{},
+}
+
+// Look up a variant by name ("" means standard)
+func GetVariant(name string) (Variant, error) {
+    if name == "" {
+        return standardRules, nil
+    }
+    variant, ok := variants[name]
+    if !ok {
+        return nil, fmt.Errorf("unknown variant: %s", name)
+    }
+    return variant, nil
+}
+
+// Rules for the variant a position is played under
+func (ce *ChessEngine) variantOf(state GameState) Variant {
+    if state.Variant == "" || state.Variant == VariantStandard {
+        return standardRules
+    }
+    if variant, ok := variants[state.Variant]; ok {
+        return variant
+    }
+    return standardRules
+}
+
+// MakeMove plus the variant's side effects
+func (ce *ChessEngine) PlayMove(game *BitboardGame, state *GameState, move Move) []Piece {
+    game.MakeMove(state, move)
+    return ce.variantOf(*state).ApplyMoveEffects(game, state, move)
+}
+
+// Standard chess
+type standardVariant struct{}
+
+func (standardVariant) Name() string { return VariantStandard }
+
+func (standardVariant) InitialPosition(seed int64) (BitboardGame, CastlingRights, error) {
+    ce := &ChessEngine{}
+    return ce.CreateBitboardGame(), CastlingRights{
+        WhiteKingSide:  true,
+        WhiteQueenSide: true,
+        BlackKingSide:  true,
+        BlackQueenSide: true,
+    }, nil
+}
+
+// Make/unmake the move and check the mover's king is safe
+func (standardVariant) IsLegalMove(game *BitboardGame, state GameState, move Move) bool {
+    ce := &ChessEngine{}
+    undo := game.MakeMove(&state, move)
+
+    king := *game.pieceBoard(Piece{Type: "king", Color: move.Piece.Color})
+    legal := king == 0 || !ce.isSquareAttacked(game, ce.GetLSBPosition(king), oppositeColor(move.Piece.Color))
+
+    game.UnmakeMove(&state, undo)
+    return legal
+}
+
+func (standardVariant) ApplyMoveEffects(game *BitboardGame, state *GameState, move Move) []Piece {
+    return nil
+}
+
+func (standardVariant) AfterMove(state *ServerGameState, move Move) {}
+
+func (standardVariant) IsInCheck(game BitboardGame, color string) bool {
+    ce := &ChessEngine{}
+    return ce.IsInCheck(game, color)
+}
+
+func (standardVariant) GameOver(game BitboardGame, state GameState, variantState VariantState) (bool, string) {
+    return false, ""
+}
+
+func (standardVariant) IsInsufficientMaterial(game BitboardGame) bool {
+    ce := &ChessEngine{}
+    return ce.IsInsufficientMaterial(game)
+}
+
+// Chess960: standard rules from a shuffled back rank
+type chess960Variant struct {
+    standardVariant
+}
+
+func (chess960Variant) Name() string { return VariantChess960 }
+
+func (chess960Variant) InitialPosition(seed int64) (BitboardGame, CastlingRights, error) {
+    ce := &ChessEngine{}
+    return ce.CreateChess960BitboardGame(Chess960PositionID(seed))
+}
+
+// King of the Hill: a king reaching d4, e4, d5 or e5 wins
+type kingOfTheHillVariant struct {
+    standardVariant
+}
+
+// d4, e4, d5, e5
+const hillSquares uint64 = 0x0000001818000000
+
+func (kingOfTheHillVariant) Name() string { return VariantKingOfTheHill }
+
+func (kingOfTheHillVariant) GameOver(game BitboardGame, state GameState, variantState VariantState) (bool, string) {
+    // Only the side that just moved can have reached the hill
+    mover := oppositeColor(state.ActiveColor)
+    if *game.pieceBoard(Piece{Type: "king", Color: mover})&hillSquares != 0 {
+        return true, "king of the hill"
+    }
+    return false, ""
+}
+
+// A bare king can still walk to the centre
+func (kingOfTheHillVariant) IsInsufficientMaterial(game BitboardGame) bool {
+    return false
+}
+
+// Three-check: giving a third check wins
+type threeCheckVariant struct {
+    standardVariant
+}
+
+func (threeCheckVariant) Name() string { return VariantThreeCheck }
+
+func (v threeCheckVariant) AfterMove(state *ServerGameState, move Move) {
+    if !v.IsInCheck(state.Bitboards, state.ActiveColor) {
+        return
+    }
+    if state.VariantState.Checks == nil {
+        state.VariantState.Checks = make(map[string]int)
+    }
+    state.VariantState.Checks[move.Piece.Color]++
+}
+
+func (threeCheckVariant) GameOver(game BitboardGame, state GameState, variantState VariantState) (bool, string) {
+    if variantState.Checks[oppositeColor(state.ActiveColor)] >= 3 {
+        return true, "three checks"
+    }
+    return false, ""
+}
+
+// Any piece can still give checks, so only bare kings are a draw
+func (threeCheckVariant) IsInsufficientMaterial(game BitboardGame) bool {
+    return onlyKingsLeft(game)
+}
+
+// Atomic: captures explode, removing the capturing piece and every non-pawn piece
+// next to the capture square. Blowing up the enemy king wins.
+type atomicVariant struct {
+    standardVariant
+}
+
+func (atomicVariant) Name() string { return VariantAtomic }
+
+func (v atomicVariant) IsLegalMove(game *BitboardGame, state GameState, move Move) bool {
+    // Kings cannot capture: they would explode themselves
+    if move.Piece.Type == "king" && move.Captured != nil {
+        return false
+    }
+
+    after := *game
+    after.MakeMove(&state, move)
+    v.ApplyMoveEffects(&after, &state, move)
+
+    if *after.pieceBoard(Piece{Type: "king", Color: move.Piece.Color}) == 0 {
+        return false
+    }
+    if *after.pieceBoard(Piece{Type: "king", Color: oppositeColor(move.Piece.Color)}) == 0 {
+        return true
+    }
+    return !v.IsInCheck(after, move.Piece.Color)
+}
+
+func (atomicVariant) ApplyMoveEffects(game *BitboardGame, state *GameState, move Move) []Piece {
+    if move.Captured == nil {
+        return nil
+    }
+
+    ce := &ChessEngine{}
+    var removed []Piece
+
+    // The capturing piece (promoted already, if it promoted) goes up with the blast
+    if piece := ce.GetPieceAt(*game, move.To); piece != nil {
+        ce.ClearPieceAt(game, move.To, *piece)
+        removed = append(removed, *piece)
+    }
+
+    for around := kingAttacks[squareIndex(move.To)]; around != 0; around &= around - 1 {
+        pos := squarePosition(ce.GetLSBPosition(around))
+        piece := ce.GetPieceAt(*game, pos)
+        if piece == nil || piece.Type == "pawn" {
+            continue
+        }
+        ce.ClearPieceAt(game, pos, *piece)
+        removed = append(removed, *piece)
+    }
+
+    ce.dropCastlingRightsForMissingRooks(*game, state)
+    state.ZobristKey = ce.ComputeZobristKey(*game, *state)
+    return removed
+}
+
+// Adjacent kings never give check: capturing one would blow up the other
+func (atomicVariant) IsInCheck(game BitboardGame, color string) bool {
+    ce := &ChessEngine{}
+    white, black := game.WhiteKing, game.BlackKing
+    if white != 0 && black != 0 && kingAttacks[ce.GetLSBPosition(white)]&black != 0 {
+        return false
+    }
+    return ce.IsInCheck(game, color)
+}
+
+func (atomicVariant) GameOver(game BitboardGame, state GameState, variantState VariantState) (bool, string) {
+    if *game.pieceBoard(Piece{Type: "king", Color: state.ActiveColor}) == 0 {
+        return true, "king exploded"
+    }
+    return false, ""
+}
+
+func (atomicVariant) IsInsufficientMaterial(game BitboardGame) bool {
+    return onlyKingsLeft(game)
+}
+
+// Drop castling rights whose king or rook has been blown off the board
+func (ce *ChessEngine) dropCastlingRightsForMissingRooks(game BitboardGame, state *GameState) {
+    hasRook := func(row, col int) bool {
+        color := "white"
+        if row == 7 {
+            color = "black"
+        }
+        if *game.pieceBoard(Piece{Type: "king", Color: color}) == 0 {
+            return false
+        }
+        piece := ce.GetPieceAt(game, Position{Row: row, Col: col})
+        return piece != nil && piece.Type == "rook" && piece.Color == color
+    }
+
+    rights := &state.CastlingRights
+    if rights.WhiteKingSide && !hasRook(0, rights.KingSideRookCol("white")) {
+        rights.WhiteKingSide = false
+    }
+    if rights.WhiteQueenSide && !hasRook(0, rights.QueenSideRookCol("white")) {
+        rights.WhiteQueenSide = false
+    }
+    if rights.BlackKingSide && !hasRook(7, rights.KingSideRookCol("black")) {
+        rights.BlackKingSide = false
+    }
+    if rights.BlackQueenSide && !hasRook(7, rights.QueenSideRookCol("black")) {
+        rights.BlackQueenSide = false
+    }
+}
+
+func onlyKingsLeft(game BitboardGame) bool {
+    ce := &ChessEngine{}
+    return ce.GetAllPieces(game) == game.WhiteKing|game.BlackKing
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+    "strings"
+    "testing"
+)
+
+// Moves one variant allows and another doesn't
+func TestVariantLegalMoves(t *testing.T) {
+    tests := []struct {
+        name     string
+        variant  string
+        fen      string
+        from, to string
+        legal    bool
+    }{
+        {"king captures", VariantStandard, "4k3/8/8/8/8/8/3p4/4K3 w - - 0 1", "e1", "d2", true},
+        {"atomic king can't capture", VariantAtomic, "4k3/8/8/8/8/8/3p4/4K3 w - - 0 1", "e1", "d2", false},
+        {"capture next to own king", VariantStandard, "4k3/8/8/8/8/8/3p4/3RK3 w - - 0 1", "d1", "d2", true},
+        {"atomic capture blows up own king", VariantAtomic, "4k3/8/8/8/8/8/3p4/3RK3 w - - 0 1", "d1", "d2", false},
+        // Blowing up the enemy king wins, even out of a pin
+        {"atomic king blast beats a pin", VariantAtomic, "4k3/4q3/8/8/8/8/8/r3R1K1 w - - 0 1", "e1", "e7", true},
+        {"atomic king may step next to the enemy king", VariantAtomic, "8/8/8/8/3k4/8/8/3K4 w - - 0 1", "d1", "d2", true},
+        {"three-check plays standard moves", VariantThreeCheck, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1", "a8", true},
+        {"king of the hill king moves one square", VariantKingOfTheHill, "7k/8/8/8/8/4K3/8/8 w - - 0 1", "e3", "e5", false},
+        {"standard capture leaves the king in check", VariantStandard, "4k3/8/8/2nbr3/3p4/2N5/8/4K3 w - - 0 1", "c3", "d5", false},
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            state, err := ce.CreateServerGameStateFromFEN(test.fen, test.variant)
+            if err != nil {
+                t.Fatal(err)
+            }
+            _, played := ce.PlayServerMove(state, ce.AlgebraicToPosition(test.from), ce.AlgebraicToPosition(test.to), "")
+            if played != test.legal {
+                t.Fatalf("%s-%s: played %v, want %v", test.from, test.to, played, test.legal)
+            }
+        })
+    }
+}
+
+// A move's effect on the board and whether it ends the game
+func TestVariantMoveEffects(t *testing.T) {
+    tests := []struct {
+        name       string
+        variant    string
+        fen        string
+        checks     map[string]int // Three-check counters before the move
+        from, to   string
+        placement  string         // Board after the move
+        wantReason string         // "" if the game goes on
+    }{
+        // Pieces next to the capture square go up with the capturing knight; pawns survive.
+        // The blast takes the checking rook with it, so the capture is legal here.
+        {"atomic explosion", VariantAtomic, "4k3/8/8/2nbr3/3p4/2N5/8/4K3 w - - 0 1", nil,
+            "c3", "d5", "4k3/8/8/8/3p4/8/8/4K3", ""},
+        {"atomic king exploded", VariantAtomic, "4k3/4q3/8/8/8/8/8/4RK2 w - - 0 1", nil,
+            "e1", "e7", "8/8/8/8/8/8/8/5K2", "king exploded"},
+        {"standard capture", VariantStandard, "4k3/8/8/2nbr3/3p4/2N5/8/5K2 w - - 0 1", nil,
+            "c3", "d5", "4k3/8/8/2nNr3/3p4/8/8/5K2", ""},
+        {"second check", VariantThreeCheck, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", map[string]int{"white": 1},
+            "a1", "a8", "R3k3/8/8/8/8/8/8/4K3", ""},
+        {"third check", VariantThreeCheck, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", map[string]int{"white": 2},
+            "a1", "a8", "R3k3/8/8/8/8/8/8/4K3", "three checks"},
+        {"king reaches the hill", VariantKingOfTheHill, "7k/8/8/8/8/4K3/8/8 w - - 0 1", nil,
+            "e3", "e4", "7k/8/8/8/4K3/8/8/8", "king of the hill"},
+        // Bare kings are no draw while one can still walk to the centre
+        {"king off the hill", VariantKingOfTheHill, "7k/8/8/8/8/4K3/8/8 w - - 0 1", nil,
+            "e3", "f3", "7k/8/8/8/8/5K2/8/8", ""},
+        {"bare kings", VariantStandard, "7k/8/8/8/8/4K3/8/8 w - - 0 1", nil,
+            "e3", "f3", "7k/8/8/8/8/5K2/8/8", "insufficient material"},
+    }
+
+    ce := &ChessEngine{}
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            state, err := ce.CreateServerGameStateFromFEN(test.fen, test.variant)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if test.checks != nil {
+                state.VariantState.Checks = test.checks
+            }
+
+            if _, ok := ce.PlayServerMove(state, ce.AlgebraicToPosition(test.from), ce.AlgebraicToPosition(test.to), ""); !ok {
+                t.Fatalf("%s-%s rejected", test.from, test.to)
+            }
+            if placement := strings.Fields(state.CurrentFen)[0]; placement != test.placement {
+                t.Fatalf("board: got %s, want %s", placement, test.placement)
+            }
+
+            over, reason := ce.IsGameOver(state.Bitboards, state.GameState(), state.PositionCounts, state.VariantState)
+            if over != (test.wantReason != "") || reason != test.wantReason {
+                t.Fatalf("game over %v (%q), want %q", over, reason, test.wantReason)
+            }
+        })
+    }
+}
+
+// Kings standing next to each other never give check in Atomic
+func TestAtomicAdjacentKings(t *testing.T) {
+    ce := &ChessEngine{}
+    game, _, err := ce.FENToGameState("8/8/8/8/8/3k4/r2K4/8 w - - 0 1")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if !variants[VariantStandard].IsInCheck(game, "white") {
+        t.Fatal("standard: rook check not seen")
+    }
+    if variants[VariantAtomic].IsInCheck(game, "white") {
+        t.Fatal("atomic: king next to the enemy king reported in check")
+    }
+}
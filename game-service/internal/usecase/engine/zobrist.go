@@ -0,0 +1,102 @@
+package engine
+
+// Zobrist keys: [color*6 + piece type][square]
+var (
+    zobristPieces    [12][64]uint64
+    zobristSide      uint64 // XORed in when black is to move
+    zobristCastling  [4]uint64 // K, Q, k, q
+    zobristEnPassant [8]uint64 // by file
+)
+
+func init() {
+    seed := uint64(0x2545F4914F6CDD1D)
+    next := func() uint64 {
+        // splitmix64, fixed seed so keys are stable across restarts
+        seed += 0x9E3779B97F4A7C15
+        z := seed
+        z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+        z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+        return z ^ (z >> 31)
+    }
+
+    for piece := 0; piece < 12; piece++ {
+        for sq := 0; sq < 64; sq++ {
+            zobristPieces[piece][sq] = next()
+        }
+    }
+    zobristSide = next()
+    for i := range zobristCastling {
+        zobristCastling[i] = next()
+    }
+    for i := range zobristEnPassant {
+        zobristEnPassant[i] = next()
+    }
+}
+
+// Compute position key from scratch
+func (ce *ChessEngine) ComputeZobristKey(game BitboardGame, state GameState) uint64 {
+    var key uint64
+
+    for _, color := range []string{"white", "black"} {
+        for _, pieceType := range []string{"pawn", "knight", "bishop", "rook", "queen", "king"} {
+            piece := Piece{Type: pieceType, Color: color}
+            for pieces := *game.pieceBoard(piece); pieces != 0; pieces &= pieces - 1 {
+                key ^= zobristPieces[zobristPieceIndex(piece)][ce.GetLSBPosition(pieces)]
+            }
+        }
+    }
+
+    if state.ActiveColor == "black" {
+        key ^= zobristSide
+    }
+    key ^= zobristCastlingKey(state.CastlingRights)
+    key ^= ce.zobristEnPassantKey(&game, state)
+
+    return key
+}
+
+func zobristPieceIndex(piece Piece) int {
+    index := 0
+    switch piece.Type {
+    case "pawn": index = 0
+    case "knight": index = 1
+    case "bishop": index = 2
+    case "rook": index = 3
+    case "queen": index = 4
+    case "king": index = 5
+    }
+    if piece.Color == "black" {
+        index += 6
+    }
+    return index
+}
+
+func zobristPieceKey(piece Piece, pos Position) uint64 {
+    return zobristPieces[zobristPieceIndex(piece)][squareIndex(pos)]
+}
+
+func zobristCastlingKey(rights CastlingRights) uint64 {
+    var key uint64
+    if rights.WhiteKingSide { key ^= zobristCastling[0] }
+    if rights.WhiteQueenSide { key ^= zobristCastling[1] }
+    if rights.BlackKingSide { key ^= zobristCastling[2] }
+    if rights.BlackQueenSide { key ^= zobristCastling[3] }
+    return key
+}
+
+// En passant file counts only when a pawn of the side to move can actually capture,
+// otherwise identical positions after a double push would never repeat
+func (ce *ChessEngine) zobristEnPassantKey(game *BitboardGame, state GameState) uint64 {
+    if state.EnPassantSquare == nil {
+        return 0
+    }
+
+    epSq := squareIndex(*state.EnPassantSquare)
+    pawns := *game.pieceBoard(Piece{Type: "pawn", Color: state.ActiveColor})
+    // Capturing pawns stand where an enemy pawn on the ep square would attack
+    if pawnAttacks[sideIndex(oppositeColor(state.ActiveColor))][epSq]&pawns == 0 {
+        return 0
+    }
+
+    return zobristEnPassant[state.EnPassantSquare.Col]
+}
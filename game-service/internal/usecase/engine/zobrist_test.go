@@ -0,0 +1,90 @@
+package engine
+
+import (
+    "testing"
+)
+
+// Shuffling the knights back and forth repeats the start position: claimable on the
+// third occurrence, over on the fifth
+func TestRepetition(t *testing.T) {
+    ce := &ChessEngine{}
+    state, err := ce.CreateVariantGameState(VariantStandard, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for cycle := 1; cycle <= 4; cycle++ {
+        for _, san := range []string{"Nf3", "Nf6", "Ng1", "Ng8"} {
+            from, to, promotion, err := ce.ParseSAN(state.Bitboards, state.GameState(), san)
+            if err != nil {
+                t.Fatalf("cycle %d, %s: %v", cycle, san, err)
+            }
+            if !ce.ExecuteServerMove(state, from, to, promotion) {
+                t.Fatalf("cycle %d: %s rejected", cycle, san)
+            }
+        }
+
+        occurrences := cycle + 1
+        claimable, reason := ce.CanClaimDraw(state)
+        if claimable != (occurrences >= 3) {
+            t.Fatalf("position seen %d times: claimable %v (%s)", occurrences, claimable, reason)
+        }
+        if claimable && reason != "threefold repetition" {
+            t.Fatalf("position seen %d times: claim reason %q", occurrences, reason)
+        }
+
+        over, reason := ce.IsGameOver(state.Bitboards, state.GameState(), state.PositionCounts, state.VariantState)
+        if over != (occurrences >= 5) {
+            t.Fatalf("position seen %d times: game over %v (%s)", occurrences, over, reason)
+        }
+        if over && reason != "fivefold repetition" {
+            t.Fatalf("position seen %d times: ended by %q", occurrences, reason)
+        }
+    }
+}
+
+// The key MakeMove and UnmakeMove keep up to date matches one computed from scratch
+func TestZobristIncremental(t *testing.T) {
+    ce := &ChessEngine{}
+    var castles, enPassants, promotions int
+
+    var walk func(game *BitboardGame, state *GameState, depth int)
+    walk = func(game *BitboardGame, state *GameState, depth int) {
+        if depth == 0 {
+            return
+        }
+        for _, move := range ce.GenerateLegalMovesFor(*game, *state) {
+            switch {
+            case move.Flags&(MoveFlagCastleKingSide|MoveFlagCastleQueenSide) != 0:
+                castles++
+            case move.Flags&MoveFlagEnPassant != 0:
+                enPassants++
+            case move.Flags&MoveFlagPromotion != 0:
+                promotions++
+            }
+
+            before := state.ZobristKey
+            undo := game.MakeMove(state, move)
+            if want := ce.ComputeZobristKey(*game, *state); state.ZobristKey != want {
+                t.Fatalf("after %s: key %x, want %x", move.UCI(), state.ZobristKey, want)
+            }
+            walk(game, state, depth-1)
+            game.UnmakeMove(state, undo)
+            if state.ZobristKey != before {
+                t.Fatalf("after undoing %s: key %x, want %x", move.UCI(), state.ZobristKey, before)
+            }
+        }
+    }
+
+    for _, position := range PerftPositions {
+        game, state, err := ce.FENToGameState(position.FEN)
+        if err != nil {
+            t.Fatal(err)
+        }
+        walk(&game, &state, 3)
+    }
+
+    if castles == 0 || enPassants == 0 || promotions == 0 {
+        t.Fatalf("walk missed special moves: %d castles, %d en passant, %d promotions", castles, enPassants, promotions)
+    }
+}
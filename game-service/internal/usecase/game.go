@@ -2,14 +2,37 @@ package usecase
 
 import (
     "context"
+    "encoding/base64"
+    "errors"
     "fmt"
+    "strconv"
+    "strings"
+    "time"
     
     "github.com/locne/game-service/internal/entity"
     "github.com/locne/game-service/internal/interface/repository"
 )
 
+const (
+    DefaultGamePageSize = 20
+    MaxGamePageSize     = 100
+)
+
+var (
+    // Returned, wrapped, for filters or cursors the archive can't serve
+    ErrInvalidQuery = errors.New("invalid query")
+    // Returned, wrapped, for uploads that aren't valid games
+    ErrInvalidPGN = errors.New("invalid PGN")
+)
+
+type GamePage struct {
+    Games      []entity.Game `json:"games"`
+    NextCursor string        `json:"nextCursor,omitempty"` // Empty on the last page
+}
+
 type GameUseCase struct {
     gameRepo repository.GameRepository
+    site     string
 }
 
 func NewGameUseCase(gameRepo repository.GameRepository) *GameUseCase {
@@ -29,4 +52,87 @@ func (uc *GameUseCase) GetGameByID(ctx context.Context, gameID string) (*entity.
     }
     
     return game, nil
-}
\ No newline at end of file
+}
+// One page of archived games, newest first. cursor is the NextCursor of the previous page.
+func (uc *GameUseCase) ListGames(ctx context.Context, filter repository.GameFilter, cursor string) (*GamePage, error) {
+    if err := ValidateGameFilter(&filter); err != nil {
+        return nil, err
+    }
+    if cursor != "" {
+        after, err := decodeGameCursor(cursor)
+        if err != nil {
+            return nil, err
+        }
+        filter.After = after
+    }
+
+    // One extra game tells us whether another page follows
+    pageSize := filter.Limit
+    filter.Limit++
+    games, err := uc.gameRepo.ListGames(ctx, filter)
+    if err != nil {
+        return nil, err
+    }
+
+    page := &GamePage{Games: games}
+    if len(games) > pageSize {
+        page.Games = games[:pageSize]
+        last := page.Games[pageSize-1]
+        page.NextCursor = encodeGameCursor(last.CreatedAt, last.GameID)
+    }
+    return page, nil
+}
+
+// Check filter and fill in the default page size
+func ValidateGameFilter(filter *repository.GameFilter) error {
+    switch filter.Color {
+    case "":
+    case "white", "black":
+        if filter.UserID == 0 {
+            return fmt.Errorf("%w: color needs a userId", ErrInvalidQuery)
+        }
+    default:
+        return fmt.Errorf("%w: unknown color %q", ErrInvalidQuery, filter.Color)
+    }
+
+    switch filter.Result {
+    case "", "1-0", "0-1", "1/2-1/2", "*":
+    case "win", "loss", "draw":
+        if filter.UserID == 0 {
+            return fmt.Errorf("%w: result %q needs a userId", ErrInvalidQuery, filter.Result)
+        }
+    default:
+        return fmt.Errorf("%w: unknown result %q", ErrInvalidQuery, filter.Result)
+    }
+
+    if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+        return fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+    }
+
+    switch {
+    case filter.Limit == 0:
+        filter.Limit = DefaultGamePageSize
+    case filter.Limit < 0 || filter.Limit > MaxGamePageSize:
+        return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxGamePageSize)
+    }
+    return nil
+}
+
+// Cursors are opaque to clients: "<createdAt unix ms>:<gameId>", base64url encoded
+func encodeGameCursor(createdAt time.Time, gameID string) string {
+    raw := strconv.FormatInt(createdAt.UnixMilli(), 10) + ":" + gameID
+    return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeGameCursor(cursor string) (*repository.GameCursor, error) {
+    raw, err := base64.RawURLEncoding.DecodeString(cursor)
+    if err != nil {
+        return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    millis, gameID, found := strings.Cut(string(raw), ":")
+    createdAt, err := strconv.ParseInt(millis, 10, 64)
+    if !found || err != nil || gameID == "" {
+        return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    return &repository.GameCursor{CreatedAt: time.UnixMilli(createdAt).UTC(), GameID: gameID}, nil
+}
@@ -0,0 +1,219 @@
+package game
+
+import (
+    "time"
+    "github.com/locne/game-service/internal/entity"
+)
+
+// How often clients get an authoritative clock snapshot
+const clockSyncInterval = time.Second
+
+// Default time the first side has to make its first move before the game is aborted
+const DefaultFirstMoveWindow = 30 * time.Second
+
+// Per-game clock: a flag timer for the side to move and a clockSync ticker.
+// Clocks only run from the first move; until then the first-move timer applies.
+type gameClock struct {
+    flagTimer      *time.Timer
+    firstMoveTimer *time.Timer
+    stop           chan struct{}
+}
+
+func (gm *GameManager) SetFirstMoveWindow(window time.Duration) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+    gm.firstMoveWindow = window
+}
+
+// Start the game clock. Must not be called with gm.mutex held (flag fall removes the game).
+func (gm *GameManager) StartClock(g *Game) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    if g.clock != nil || g.finished {
+        return
+    }
+    g.clock = &gameClock{stop: make(chan struct{})}
+    g.armFlagTimer(gm)
+
+    go gm.runClockSync(g, g.clock.stop)
+}
+
+func (gm *GameManager) runClockSync(g *Game, stop <-chan struct{}) {
+    ticker := time.NewTicker(clockSyncInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-stop:
+            return
+        case <-gm.ctx.Done():
+            return
+        case <-ticker.C:
+            gm.publishClockSync(g)
+        }
+    }
+}
+
+// (Re)schedule the flag timer for the side to move. Caller must hold g.mutex.
+func (g *Game) armFlagTimer(gm *GameManager) {
+    if g.clock == nil || g.finished {
+        return
+    }
+    if !g.clockStarted() {
+        // New game, or taken back to the start position: nobody's clock runs and the
+        // first-move window starts (over)
+        if g.clock.flagTimer != nil {
+            g.clock.flagTimer.Stop()
+            g.clock.flagTimer = nil
+        }
+        g.armFirstMoveTimer(gm)
+        return
+    }
+    if g.clock.flagTimer != nil {
+        g.clock.flagTimer.Stop()
+    }
+    if g.clock.firstMoveTimer != nil {
+        g.clock.firstMoveTimer.Stop()
+        g.clock.firstMoveTimer = nil
+    }
+
+    color := g.GameState.ActiveColor
+    remaining := time.Duration(g.timeLeft(color)+g.delayLeft()) * time.Millisecond
+    g.clock.flagTimer = time.AfterFunc(remaining, func() {
+        gm.onFlagFall(g, color)
+    })
+}
+
+// Caller must hold g.mutex (taken before gm.mutex, as everywhere)
+func (g *Game) armFirstMoveTimer(gm *GameManager) {
+    gm.mutex.RLock()
+    window := gm.firstMoveWindow
+    gm.mutex.RUnlock()
+
+    if g.clock.firstMoveTimer != nil {
+        g.clock.firstMoveTimer.Stop()
+    }
+    g.clock.firstMoveTimer = time.AfterFunc(window, func() {
+        gm.onFirstMoveTimeout(g)
+    })
+}
+
+func (gm *GameManager) onFlagFall(g *Game, color string) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    // The game ended or a move landed just before the timer fired
+    if g.finished || g.GameState.ActiveColor != color {
+        return
+    }
+    if g.timeLeft(color) > 0 {
+        g.armFlagTimer(gm)
+        return
+    }
+
+    g.updatePlayerTime(color)
+    g.handleTimeOut(color, gm)
+}
+
+func (gm *GameManager) onFirstMoveTimeout(g *Game) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    if g.finished || g.clockStarted() {
+        return
+    }
+    g.abortGame(entity.ReasonNoFirstMove, gm)
+}
+
+// Stop the flag timer and clockSync ticker. Caller must hold g.mutex.
+func (g *Game) stopClock() {
+    if g.clock == nil {
+        return
+    }
+    if g.clock.flagTimer != nil {
+        g.clock.flagTimer.Stop()
+    }
+    if g.clock.firstMoveTimer != nil {
+        g.clock.firstMoveTimer.Stop()
+    }
+    close(g.clock.stop)
+    g.clock = nil
+}
+
+// Milliseconds left for color, including the time the side to move has used so far
+func (g *Game) timeLeft(color string) int {
+    left := g.WhiteTimeLeft
+    if color == "black" {
+        left = g.BlackTimeLeft
+    }
+
+    if color == g.GameState.ActiveColor && g.clockStarted() && !g.LastMoveTime.IsZero() {
+        used := int(time.Since(g.LastMoveTime).Milliseconds()) - g.delayFor(color)
+        if used > 0 {
+            left -= used
+        }
+    }
+    if left < 0 {
+        left = 0
+    }
+    return left
+}
+
+// Whether the first move has been played
+func (g *Game) clockStarted() bool {
+    return g.WhiteMoves+g.BlackMoves > 0
+}
+
+// Period in force for color's next move
+func (g *Game) currentStage(color string) TimeStage {
+    if g.TimeControl == nil {
+        return TimeStage{}
+    }
+    moves := g.WhiteMoves
+    if color == "black" {
+        moves = g.BlackMoves
+    }
+    return g.TimeControl.stageFor(moves)
+}
+
+// Simple delay for color's next move in milliseconds (0 unless the period uses simple delay)
+func (g *Game) delayFor(color string) int {
+    stage := g.currentStage(color)
+    if stage.Mode != ClockDelay {
+        return 0
+    }
+    return stage.Delay * 1000
+}
+
+// Milliseconds of simple delay the side to move has not used yet
+func (g *Game) delayLeft() int {
+    if g.LastMoveTime.IsZero() || !g.clockStarted() {
+        return 0
+    }
+    left := g.delayFor(g.GameState.ActiveColor) - int(time.Since(g.LastMoveTime).Milliseconds())
+    if left < 0 {
+        return 0
+    }
+    return left
+}
+
+func (gm *GameManager) publishClockSync(g *Game) {
+    g.mutex.RLock()
+    if g.finished {
+        g.mutex.RUnlock()
+        return
+    }
+    update := StateUpdateMessage{
+        Type:          "clockSync",
+        RoomID:        g.ID,
+        WhiteTimeLeft: g.timeLeft("white"),
+        BlackTimeLeft: g.timeLeft("black"),
+        ActiveColor:   g.GameState.ActiveColor,
+        DelayLeft:     g.delayLeft(),
+        ServerTime:    time.Now().UnixMilli(),
+    }
+    g.mutex.RUnlock()
+
+    gm.PublishStateUpdate(update)
+}
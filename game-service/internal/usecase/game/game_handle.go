@@ -1,7 +1,9 @@
 package game
 
 import (
+    "errors"
     "fmt"
+    "log"
     "time"
     "github.com/locne/game-service/internal/usecase/engine"
     //"github.com/locne/game-service/internal/interface/repository"
@@ -9,9 +11,29 @@ import (
     "strconv"
 )
 
-func (g *Game) MakeMove(playerID int, from, to engine.Position, gm *GameManager) error {
+// Returned for a move ID the player already had accepted
+var errDuplicateMove = errors.New("move already played")
+
+// Returned when another instance took the game over before the move could be saved. The
+// move is dropped with our copy of the game; the client's retry reaches the new owner.
+var errGameMoved = errors.New("game moved to another instance")
+
+// Backoff between attempts to journal a finished game
+const (
+    finishRetryBase = 500 * time.Millisecond
+    finishRetryMax  = 30 * time.Second
+)
+
+func (g *Game) MakeMove(playerID int, moveID string, from, to engine.Position, promotion string, gm *GameManager) error {
     g.mutex.Lock()
     defer g.mutex.Unlock()
+
+    if moveID != "" && g.lastMoveIDs[playerID] == moveID {
+        return errDuplicateMove
+    }
+    if g.finished {
+        return fmt.Errorf("game is over")
+    }
     
     // 1. Check if spectator trying to move 
     if _, isSpectator := g.Spectators[playerID]; isSpectator {
@@ -34,85 +56,125 @@ func (g *Game) MakeMove(playerID int, from, to engine.Position, gm *GameManager)
         return fmt.Errorf("not your turn")
     }
     
-    // 5. Update time before move
-    g.updatePlayerTime()
-    
-    // 6. Check if player has time left
-    if g.GameState.ActiveColor == "white" && g.WhiteTimeLeft <= 0 {
-        g.handleTimeOut("white", gm)
-        return fmt.Errorf("white time expired")
-    }
-    if g.GameState.ActiveColor == "black" && g.BlackTimeLeft <= 0 {
-        g.handleTimeOut("black", gm)
-        return fmt.Errorf("black time expired")
+    // 5-6. Check if player has time left (time is only charged once the move is played)
+    if g.timeLeft(player.Color) <= 0 {
+        g.updatePlayerTime(player.Color)
+        g.handleTimeOut(player.Color, gm)
+        return fmt.Errorf("%s time expired", player.Color)
     }
     
+    return g.applyMove(playerID, moveID, from, to, promotion, false, gm)
+}
+
+// Validate and play a move for the side to move. A premove is played the instant the
+// opponent's move lands, so it costs the premover no time. Caller must hold g.mutex.
+func (g *Game) applyMove(playerID int, moveID string, from, to engine.Position, promotion string, premove bool, gm *GameManager) error {
     // 7. Validate move positions
     if err := g.validateMovePositions(from, to); err != nil {
         return err
     }
+    if err := g.validatePromotion(from, to, promotion); err != nil {
+        return err
+    }
 
     // 8. Store state before move for notation
     gameBefore := g.GameState.Bitboards
-    stateBefore := engine.GameState{
-        ActiveColor:     g.GameState.ActiveColor,
-        CastlingRights:  g.GameState.CastlingRights,
-        EnPassantSquare: g.GameState.EnPassantSquare,
-        MoveCount:       g.GameState.FullMoveNumber,
-        HalfMoveClock:   g.GameState.HalfMoveClock,
-    }
+    stateBefore := g.GameState.GameState()
+    moverColor := g.GameState.ActiveColor
     
     // 9. Execute move using chess engine
+    clocks := g.clocksBefore()
     chessEngine := &engine.ChessEngine{}
-    success := chessEngine.ExecuteServerMove(g.GameState, from, to)
+    undo, success := chessEngine.PlayServerMove(g.GameState, from, to, promotion)
     if !success {
         return fmt.Errorf("invalid move: from (%d,%d) to (%d,%d)", from.Row, from.Col, to.Row, to.Col)
     }
+    g.pushUndo(undo, clocks)
 
     // 10. Build notation
-    notation := chessEngine.BuildNotation(
-        gameBefore,
-        stateBefore,
-        g.GameState.Bitboards,
-        engine.GameState{
-            ActiveColor:     g.GameState.ActiveColor,
-            CastlingRights:  g.GameState.CastlingRights,
-            EnPassantSquare: g.GameState.EnPassantSquare,
-            MoveCount:       g.GameState.FullMoveNumber,
-            HalfMoveClock:   g.GameState.HalfMoveClock,
-        },
-        from, to,
-    )
-    g.addNotationToMoveHistory(notation)
+    notation := chessEngine.BuildNotation(gameBefore, stateBefore, from, to, promotion)
+    g.addNotationToMoveHistory(moverColor, notation)
 
-    // 11. Post-move actions
-    g.addTimeIncrement()
-    g.LastMoveTime = time.Now()
+    // 11. Post-move actions (engine has already switched the side to move)
+    elapsed, thinkTime := 0, 0
+    if premove {
+        g.LastMoveTime = time.Now()
+    } else {
+        if !g.LastMoveTime.IsZero() {
+            thinkTime = int(time.Since(g.LastMoveTime).Milliseconds())
+        }
+        elapsed = g.updatePlayerTime(moverColor)
+    }
+    g.addTimeIncrement(moverColor, elapsed)
+    g.recordMove(notation, chessEngine.NewMove(gameBefore, stateBefore, from, to, promotion).UCI(), moverColor, thinkTime)
+    g.TakebackOffers = make(map[string]*TakebackOffer)
     g.UpdatedAt = time.Now()
-    
-    g.switchTurn()
+    if moveID != "" {
+        if g.lastMoveIDs == nil {
+            g.lastMoveIDs = make(map[int]string)
+        }
+        g.lastMoveIDs[playerID] = moveID
+    }
 
-    // 12. Check if game ended
+    // 12. Check if game ended, otherwise start the opponent's clock
     finished, winner, reason := g.isGameFinished(gm)
     if finished {
         g.endGame(winner, reason, gm)
+    } else {
+        g.armFlagTimer(gm)
+        if !gm.persistGame(g) {
+            return errGameMoved
+        }
     }
 
     return nil
 }
 
-func (g *Game) switchTurn() {
-    if g.GameState.ActiveColor == "white" {
-        g.GameState.ActiveColor = "black"
-    } else {
-        g.GameState.ActiveColor = "white"
+// Append the ply just played to MoveRecords, clock included once increments are in.
+// Caller must hold g.mutex.
+func (g *Game) recordMove(san string, uci string, color string, thinkTime int) {
+    record := entity.MoveRecord{
+        Ply:       len(g.MoveRecords) + 1,
+        SAN:       san,
+        UCI:       uci,
+        FENAfter:  g.GameState.CurrentFen,
+        ThinkTime: max(thinkTime, 0),
+    }
+    if g.TimeControl != nil {
+        clock := g.timeLeft(color)
+        record.Clock = &clock
     }
+    g.MoveRecords = append(g.MoveRecords, record)
+}
+
+// Resolve a SAN move ("Nbd7", "exd8=N") against the current position
+func (g *Game) ResolveSAN(san string) (engine.Position, engine.Position, string, error) {
+    g.mutex.RLock()
+    defer g.mutex.RUnlock()
+
+    chessEngine := &engine.ChessEngine{}
+    return chessEngine.ParseSAN(
+        g.GameState.Bitboards,
+        g.GameState.GameState(),
+        san,
+    )
+}
+
+// All legal moves for the side to move
+func (g *Game) LegalMoves() []engine.Move {
+    g.mutex.RLock()
+    defer g.mutex.RUnlock()
+
+    chessEngine := &engine.ChessEngine{}
+    return chessEngine.GenerateLegalMoves(g.GameState)
 }
 
-func (g *Game) addNotationToMoveHistory(notation string) {
-    isWhite := g.GameState.ActiveColor == "white"
+func (g *Game) addNotationToMoveHistory(color string, notation string) {
+    isWhite := color == "white"
+    // Called after the move: fullmove number only advances after black, and games
+    // started from a FEN don't begin at move 1
     if isWhite {
-        moveNum := len(g.GameState.MoveHistory) + 1
+        moveNum := g.GameState.FullMoveNumber
         g.GameState.MoveHistory = append(g.GameState.MoveHistory, engine.MoveNotation{
             MoveNumber: moveNum,
             White:      notation,
@@ -121,7 +183,7 @@ func (g *Game) addNotationToMoveHistory(notation string) {
     } else {
         if len(g.GameState.MoveHistory) == 0 {
             g.GameState.MoveHistory = append(g.GameState.MoveHistory, engine.MoveNotation{
-                MoveNumber: 1,
+                MoveNumber: g.GameState.FullMoveNumber - 1,
                 White:      "",
                 Black:      notation,
             })
@@ -131,6 +193,20 @@ func (g *Game) addNotationToMoveHistory(notation string) {
     }
 }
 
+// Drop the notation of the last ply, played by color. Caller must hold g.mutex.
+func (g *Game) removeLastNotation(color string) {
+    history := g.GameState.MoveHistory
+    if len(history) == 0 {
+        return
+    }
+    last := &history[len(history)-1]
+    if color == "black" && last.White != "" {
+        last.Black = ""
+        return
+    }
+    g.GameState.MoveHistory = history[:len(history)-1]
+}
+
 func (g *Game) validateMovePositions(from, to engine.Position) error {
     if from.Row < 0 || from.Row > 7 || from.Col < 0 || from.Col > 7 {
         return fmt.Errorf("invalid 'from' position: row and col must be 0-7")
@@ -144,35 +220,73 @@ func (g *Game) validateMovePositions(from, to engine.Position) error {
     return nil
 }
 
-func (g *Game) updatePlayerTime() {
+func (g *Game) validatePromotion(from, to engine.Position, promotion string) error {
+    chessEngine := &engine.ChessEngine{}
+    piece := chessEngine.GetPieceAt(g.GameState.Bitboards, from)
+    if !chessEngine.IsPromotionMove(piece, to) {
+        return nil
+    }
+    if _, ok := chessEngine.ParsePromotionPiece(promotion); !ok {
+        return fmt.Errorf("promotion piece required: queen, rook, bishop or knight")
+    }
+    return nil
+}
+
+// Charge color for the time used since its clock started and restart the clock.
+// Returns the milliseconds used; simple delay is not charged.
+func (g *Game) updatePlayerTime(color string) int {
     if g.LastMoveTime.IsZero() || g.GameState == nil {
-        return
+        return 0
     }
     
-    elapsed := int(time.Since(g.LastMoveTime).Seconds())
+    now := time.Now()
+    elapsed := int(now.Sub(g.LastMoveTime).Milliseconds())
+    if !g.clockStarted() {
+        // Thinking over the first move is free
+        g.LastMoveTime = now
+        return 0
+    }
+    charged := elapsed - g.delayFor(color)
+    if charged < 0 {
+        charged = 0
+    }
+    g.LastMoveTime = now
     
-    if g.GameState.ActiveColor == "white" {
-        g.WhiteTimeLeft -= elapsed
+    if color == "white" {
+        g.WhiteTimeLeft -= charged
         if g.WhiteTimeLeft < 0 {
             g.WhiteTimeLeft = 0
         }
     } else {
-        g.BlackTimeLeft -= elapsed
+        g.BlackTimeLeft -= charged
         if g.BlackTimeLeft < 0 {
             g.BlackTimeLeft = 0
         }
     }
+    return elapsed
 }
 
-func (g *Game) addTimeIncrement() {
-    if g.TimeControl == nil || g.TimeControl.Increment == 0 {
+// Increment or Bronstein refund for the move color just played, plus the time of the
+// next period when the move completes one
+func (g *Game) addTimeIncrement(color string, elapsed int) {
+    if g.TimeControl == nil {
         return
     }
     
-    if g.GameState.ActiveColor == "white" {
-        g.WhiteTimeLeft += g.TimeControl.Increment
+    stage := g.currentStage(color)
+    bonus := stage.Increment * 1000
+    if stage.Mode == ClockBronstein {
+        bonus += min(elapsed, stage.Delay*1000)
+    }
+
+    if color == "white" {
+        g.WhiteMoves++
+        bonus += g.TimeControl.stageBonus(g.WhiteMoves) * 1000
+        g.WhiteTimeLeft += bonus
     } else {
-        g.BlackTimeLeft += g.TimeControl.Increment
+        g.BlackMoves++
+        bonus += g.TimeControl.stageBonus(g.BlackMoves) * 1000
+        g.BlackTimeLeft += bonus
     }
 }
 
@@ -181,7 +295,7 @@ func (g *Game) handleTimeOut(color string, gm *GameManager) {
     if color == "black" {
         winner = "white"
     }
-    reason := "timeout"
+    reason := entity.ReasonTimeout
 
     g.endGame(winner, reason, gm)
 }
@@ -194,27 +308,14 @@ func (g *Game) isGameFinished(gm *GameManager) (bool, string, string) {
     chessEngine := &engine.ChessEngine{}
     finished, reason := chessEngine.IsGameOver(
         g.GameState.Bitboards,
-        engine.GameState{
-            ActiveColor:     g.GameState.ActiveColor,
-            CastlingRights:  g.GameState.CastlingRights,
-            EnPassantSquare: g.GameState.EnPassantSquare,
-            MoveCount:       g.GameState.FullMoveNumber,
-            HalfMoveClock:   g.GameState.HalfMoveClock,
-        },
+        g.GameState.GameState(),
         g.GameState.PositionCounts,
-        g.GameState.CurrentFen,
+        g.GameState.VariantState,
     )
 
     winner := "none"
     if finished {
-        drawReasons := map[string]bool{
-            "threefold repetition":   true,
-            "stalemate":              true,
-            "insufficient material":  true,
-            "fifty move rule":        true,
-        }
-
-        if drawReasons[reason] {
+        if entity.IsDrawReason(reason) {
             winner = "none"
         } else if g.GameState.ActiveColor == "black" {
             winner = "white"
@@ -227,25 +328,40 @@ func (g *Game) isGameFinished(gm *GameManager) (bool, string, string) {
     return finished, winner, reason
 }
 
+// Finish the game, publish the result and queue it for saving. Caller must hold g.mutex.
 func (g *Game) endGame(winner string, reason string, gm *GameManager) {
+    result := "1/2-1/2" // draw
+    if winner == "white" {
+        result = "1-0"
+    } else if winner == "black" {
+        result = "0-1"
+    }
+    g.finishGame(result, winner, reason, gm)
+}
+
+// End the game without a result. Caller must hold g.mutex.
+func (g *Game) abortGame(reason string, gm *GameManager) {
+    g.finishGame("*", "", reason, gm)
+}
+
+func (g *Game) finishGame(result string, winner string, reason string, gm *GameManager) {
+    if g.finished {
+        return
+    }
+    g.finished = true
+    g.stopClock()
+    g.stopAbandonTimers()
+
     whitePlayer := g.getPlayerByColor("white")
     blackPlayer := g.getPlayerByColor("black")
     
-    result := "1/2-1/2" // draw
     winnerId := "none"
-    
     if winner == "white" {
-        result = "1-0"
         winnerId = strconv.Itoa(whitePlayer.ID)
     } else if winner == "black" {
-        result = "0-1"
         winnerId = strconv.Itoa(blackPlayer.ID)
     }
 
-    chessEngine := &engine.ChessEngine{}
-    castlingStr := g.GameState.CastlingRights.ToFEN()
-    enPassantStr := g.GameState.EnPassantSquare.ToFEN()
-    
     game := entity.Game{
         GameID: g.ID,
         Players: struct {
@@ -266,35 +382,84 @@ func (g *Game) endGame(winner string, reason string, gm *GameManager) {
         Moves:         moveHistoryToNotationList(g.GameState.MoveHistory),
         Result:        result,
         CreatedAt:     g.CreatedAt,
-        TimeControl:   strconv.Itoa(g.TimeControl.InitialTime/60) + "+" + strconv.Itoa(g.TimeControl.Increment),
+        TimeControl:   g.TimeControl.String(),
         GameType:      g.TimeControl.Type,
         WinnerID:      winnerId,
         WhiteTimeLeft: g.WhiteTimeLeft,
         BlackTimeLeft: g.BlackTimeLeft,
         Reason:        reason,
-        LastFen: chessEngine.BitboardToFEN(
-            g.GameState.Bitboards,
-            g.GameState.ActiveColor,
-            castlingStr,
-            enPassantStr,
-            g.GameState.HalfMoveClock,
-            g.GameState.FullMoveNumber,
-        ),
+        LastFen:       g.GameState.CurrentFen,
+        Variant:       g.GameState.Variant,
+        InitialFen:    g.GameState.InitialFen,
+        Rated:         g.Rated,
+        MoveRecords:   g.MoveRecords,
     }
     fmt.Print(game)
 
-    endUpdate := StateUpdateMessage{
-        Type:   "gameEnd",
-        RoomID: g.ID,
-        Result: result,
-        Winner: winner,
-        Reason: reason,
+    g.pendingFinish = &finishRecord{
+        Game: game,
+        End: StateUpdateMessage{
+            Type:   "gameEnd",
+            RoomID: g.ID,
+            Result: result,
+            Winner: winner,
+            Reason: reason,
+        },
     }
-    gm.PublishStateUpdate(endUpdate)
-    
+    g.completeFinish(gm)
+}
+
+// A finished game on its way into the journal
+type finishRecord struct {
+    Game entity.Game        `json:"game"`
+    End  StateUpdateMessage `json:"end"`
+}
+
+// Journal the finished game, then announce the end and drop the live copy. Once
+// forgetGame drops the live snapshot the journal is the only copy until Mongo has it, so
+// if the journal write fails the snapshot keeps the result and we try again later; nobody
+// hears the game is over before then. Caller must hold g.mutex.
+func (g *Game) completeFinish(gm *GameManager) {
+    record := g.pendingFinish
+    if record == nil {
+        return
+    }
+
+    // The game.finished event for other services goes into the outbox alongside
+    if err := gm.recordFinishedGame(record.Game); err != nil {
+        g.finishAttempts++
+        delay := finishRetryBase << (g.finishAttempts - 1)
+        if delay > finishRetryMax || delay <= 0 {
+            delay = finishRetryMax
+        }
+        log.Printf("Failed to journal finished game %s (attempt %d), retrying in %v: %v",
+            g.ID, g.finishAttempts, delay, err)
+        gm.persistGame(g)
+        time.AfterFunc(delay, func() {
+            gm.retryFinish(g)
+        })
+        return
+    }
+    g.pendingFinish = nil
+
+    gm.PublishStateUpdate(record.End)
+
     gm.RemoveGame(g.ID)
+    gm.forgetGame(g.ID)
+
+    // Never blocks: the journal has the game if the queue is full
+    gm.savePool.SaveGame(record.Game)
+}
+
+func (gm *GameManager) retryFinish(g *Game) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
 
-    gm.savePool.SaveGame(game)
+    // Shutting down or no longer ours: whoever restores the snapshot finishes the job
+    if gm.ctx.Err() != nil || !gm.owns(g) {
+        return
+    }
+    g.completeFinish(gm)
 }
 
 func (g *Game) getPlayerByColor(color string) *Player {
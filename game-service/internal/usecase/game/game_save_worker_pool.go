@@ -2,58 +2,113 @@ package game
 
 import (
     "context"
+    "encoding/json"
+    "fmt"
     "log"
+    "sync"
     "time"
+    "github.com/go-redis/redis/v8"
     "github.com/locne/game-service/internal/entity"
     "github.com/locne/game-service/internal/interface/repository"
 )
 
+// Finished games are journaled in Redis before anyone is told the game is over, and
+// only leave the journal once Mongo (or the dead-letter collection) has them. Saves are
+// upserts on gameId, so replaying the journal after a crash never duplicates a game.
+const (
+    finishedGamesKey     = "games:finished"
+    saveQueueSize        = 1000
+    saveMaxAttempts      = 5
+    saveRetryBase        = 500 * time.Millisecond
+    saveRetryMax         = 30 * time.Second
+    journalSweepInterval = time.Minute
+)
+
 type GameSaveJob struct {
     Game      entity.Game
     Timestamp time.Time
 }
 
+// A finished game waiting in the journal
+type journalEntry struct {
+    Game        entity.Game `json:"game"`
+    JournaledAt time.Time   `json:"journaledAt"`
+}
+
 type GameSaveWorkerPool struct {
-    jobs       chan GameSaveJob
-    batchSize  int
-    flushTime  time.Duration
-    repo       repository.GameRepository
-    workers    int
+    jobs         chan GameSaveJob
+    batchSize    int
+    flushTime    time.Duration
+    repo         repository.GameRepository
+    redis        *redis.Client
+    ctx          context.Context
+    workers      int
+    wg           sync.WaitGroup
+    mutex        sync.RWMutex // Guards closed; held for reading while sending on jobs
+    closed       bool
+    pendingMutex sync.Mutex
+    pending      map[string]bool // Queued or being saved, so sweeps don't queue them twice
+    stop         chan struct{}   // Closed when draining starts
+    abort        chan struct{}   // Closed when the drain deadline passes: stop retrying
 }
 
-func NewGameSaveWorkerPool(repo repository.GameRepository, workers int) *GameSaveWorkerPool {
+func NewGameSaveWorkerPool(repo repository.GameRepository, redisClient *redis.Client, ctx context.Context, workers int) *GameSaveWorkerPool {
     pool := &GameSaveWorkerPool{
-        jobs:      make(chan GameSaveJob, 1000), // Buffer for 1000 games
+        jobs:      make(chan GameSaveJob, saveQueueSize),
         batchSize: 50,                           // Batch size
         flushTime: 5 * time.Second,             // Max wait time
         repo:      repo,
+        redis:     redisClient,
+        ctx:       ctx,
         workers:   workers,
+        pending:   make(map[string]bool),
+        stop:      make(chan struct{}),
+        abort:     make(chan struct{}),
     }
-    
+
     // Start workers
+    pool.wg.Add(workers)
     for i := 0; i < workers; i++ {
         go pool.worker()
     }
-    
+    go pool.sweepJournal()
+
     return pool
 }
 
+// Queue the journal write for a finished game on pipe
+func (pool *GameSaveWorkerPool) journal(pipe redis.Pipeliner, game entity.Game) error {
+    data, err := json.Marshal(journalEntry{Game: game, JournaledAt: time.Now()})
+    if err != nil {
+        return err
+    }
+    pipe.HSet(pool.ctx, finishedGamesKey, game.GameID, data)
+    return nil
+}
+
 func (pool *GameSaveWorkerPool) worker() {
+    defer pool.wg.Done()
+
     ticker := time.NewTicker(pool.flushTime)
     defer ticker.Stop()
-    
+
     batch := make([]entity.Game, 0, pool.batchSize)
-    
+
     for {
         select {
-        case job := <-pool.jobs:
+        case job, ok := <-pool.jobs:
+            if !ok {
+                // Draining: save what we have and stop
+                pool.flushBatch(batch)
+                return
+            }
             batch = append(batch, job.Game)
-            
+
             if len(batch) >= pool.batchSize {
                 pool.flushBatch(batch)
-                batch = batch[:0] 
+                batch = batch[:0]
             }
-            
+
         case <-ticker.C:
             if len(batch) > 0 {
                 pool.flushBatch(batch)
@@ -67,38 +122,190 @@ func (pool *GameSaveWorkerPool) flushBatch(games []entity.Game) {
     if len(games) == 0 {
         return
     }
-    
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer pool.release(games)
+
+    for attempt := 1; attempt <= saveMaxAttempts; attempt++ {
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        start := time.Now()
+        err := pool.repo.SaveGamesBatch(ctx, games)
+        cancel()
+
+        if err == nil {
+            log.Printf("Successfully saved batch of %d games (took %v)", len(games), time.Since(start))
+            pool.forget(games)
+            return
+        }
+        log.Printf("Failed to save batch of %d games (attempt %d/%d): %v",
+            len(games), attempt, saveMaxAttempts, err)
+
+        if attempt < saveMaxAttempts && !pool.backoff(attempt) {
+            log.Printf("Save of %d games abandoned at shutdown; they stay in the journal", len(games))
+            return
+        }
+    }
+
+    // One bad game shouldn't keep the rest out: save them singly and dead-letter the failures
+    for _, game := range games {
+        select {
+        case <-pool.abort:
+            return
+        default:
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+        err := pool.repo.SaveGame(ctx, game)
+        cancel()
+
+        if err == nil {
+            pool.forget([]entity.Game{game})
+            continue
+        }
+        pool.deadLetter(game, err)
+    }
+}
+
+// Wait before the next attempt; false if the pool is being torn down
+func (pool *GameSaveWorkerPool) backoff(attempt int) bool {
+    delay := saveRetryBase << (attempt - 1)
+    if delay > saveRetryMax {
+        delay = saveRetryMax
+    }
+
+    select {
+    case <-time.After(delay):
+        return true
+    case <-pool.abort:
+        return false
+    }
+}
+
+func (pool *GameSaveWorkerPool) deadLetter(game entity.Game, saveErr error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
-    
-    start := time.Now()
-    err := pool.repo.SaveGamesBatch(ctx, games)
-    duration := time.Since(start)
-    
+
+    if err := pool.repo.SaveDeadLetter(ctx, game, saveErr.Error()); err != nil {
+        log.Printf("Failed to dead-letter game %s: %v; it stays in the journal", game.GameID, err)
+        return
+    }
+    log.Printf("Game %s moved to the dead-letter collection: %v", game.GameID, saveErr)
+    pool.forget([]entity.Game{game})
+}
+
+// Drop saved games from the journal
+func (pool *GameSaveWorkerPool) forget(games []entity.Game) {
+    gameIDs := make([]string, len(games))
+    for i, game := range games {
+        gameIDs[i] = game.GameID
+    }
+    if err := pool.redis.HDel(pool.ctx, finishedGamesKey, gameIDs...).Err(); err != nil {
+        log.Printf("Failed to clear %d saved games from the journal: %v", len(gameIDs), err)
+    }
+}
+
+func (pool *GameSaveWorkerPool) release(games []entity.Game) {
+    pool.pendingMutex.Lock()
+    defer pool.pendingMutex.Unlock()
+    for _, game := range games {
+        delete(pool.pending, game.GameID)
+    }
+}
+
+// Queue a journaled game for saving. Never blocks (callers hold a game's lock): if the
+// queue is full the game waits in the journal for the next sweep instead.
+func (pool *GameSaveWorkerPool) SaveGame(game entity.Game) {
+    pool.pendingMutex.Lock()
+    if pool.pending[game.GameID] {
+        pool.pendingMutex.Unlock()
+        return
+    }
+    pool.pending[game.GameID] = true
+    pool.pendingMutex.Unlock()
+
+    // Close waits for the read lock, so the channel stays open while we send
+    pool.mutex.RLock()
+    sent := false
+    if pool.closed {
+        log.Printf("Save pool closed, game %s stays in the journal", game.GameID)
+    } else {
+        select {
+        case pool.jobs <- GameSaveJob{Game: game, Timestamp: time.Now()}:
+            sent = true
+        default:
+            log.Printf("Game save queue full, game %s stays in the journal", game.GameID)
+        }
+    }
+    pool.mutex.RUnlock()
+
+    if !sent {
+        pool.release([]entity.Game{game})
+    }
+}
+
+// Requeue journaled games nobody is saving: right away for leftovers of a previous run,
+// then periodically for games that didn't fit in the queue or outlived their retries
+func (pool *GameSaveWorkerPool) sweepJournal() {
+    pool.requeueJournal()
+
+    ticker := time.NewTicker(journalSweepInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-pool.stop:
+            return
+        case <-ticker.C:
+            pool.requeueJournal()
+        }
+    }
+}
+
+func (pool *GameSaveWorkerPool) requeueJournal() {
+    entries, err := pool.redis.HGetAll(pool.ctx, finishedGamesKey).Result()
     if err != nil {
-        log.Printf("Failed to save batch of %d games: %v (took %v)", 
-            len(games), err, duration)
-        
-        for _, game := range games {
-            log.Printf("Failed game: %s", game.GameID)
+        log.Printf("Failed to read the finished game journal: %v", err)
+        return
+    }
+
+    requeued := 0
+    for gameID, data := range entries {
+        var entry journalEntry
+        if err := json.Unmarshal([]byte(data), &entry); err != nil {
+            log.Printf("Unreadable journal entry for game %s: %v", gameID, err)
+            continue
         }
-    } else {
-        log.Printf("Successfully saved batch of %d games (took %v)", 
-            len(games), duration)
+        pool.SaveGame(entry.Game)
+        requeued++
+    }
+    if requeued > 0 {
+        log.Printf("Requeued %d journaled games", requeued)
     }
 }
 
-func (pool *GameSaveWorkerPool) SaveGame(game entity.Game) {
+// Stop taking games and save the queued ones. Games still unsaved when ctx ends stay in
+// the journal for the next run.
+func (pool *GameSaveWorkerPool) Close(ctx context.Context) error {
+    pool.mutex.Lock()
+    if pool.closed {
+        pool.mutex.Unlock()
+        return nil
+    }
+    pool.closed = true
+    close(pool.stop)
+    close(pool.jobs)
+    pool.mutex.Unlock()
+
+    done := make(chan struct{})
+    go func() {
+        pool.wg.Wait()
+        close(done)
+    }()
+
     select {
-    case pool.jobs <- GameSaveJob{Game: game, Timestamp: time.Now()}:
-    default:
-        log.Printf("Game save queue full, dropping game %s", game.GameID)
-        go func() {
-            ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-            defer cancel()
-            if err := pool.repo.SaveGame(ctx, game); err != nil {
-                log.Printf("Failed to save game %s: %v", game.GameID, err)
-            }
-        }()
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        close(pool.abort)
+        <-done
+        return fmt.Errorf("save pool drain cut short: %w", ctx.Err())
     }
-}
\ No newline at end of file
+}
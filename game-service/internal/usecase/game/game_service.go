@@ -4,32 +4,41 @@ import (
     "context"
     "encoding/json"
     "fmt"
+    "log"
     "time"
     "sync"
     "github.com/go-redis/redis/v8"
     "github.com/locne/game-service/internal/usecase/engine"
     "github.com/locne/game-service/internal/interface/repository"
+    "github.com/locne/game-service/internal/entity"
+    "github.com/locne/protocol"
 )
 
-type Player struct {
-    ID       int    `json:"userId"`       
-    Username string `json:"username"`
-    Color    string `json:"color"`       
-    Rating   int    `json:"rating,omitempty"`   
-    IsOnline bool   `json:"isOnline"`   
-}
+// Wire types are shared with ws-service through the protocol module
+type Player = protocol.Player
 
 type TimeControl struct {
-    Type        string `json:"type"`        
-    InitialTime int    `json:"initialTime"` 
-    Increment   int    `json:"increment"`   
+    Type        string      `json:"type"`        
+    InitialTime int         `json:"initialTime"` // Seconds
+    Increment   int         `json:"increment"`   // Seconds added after each move
+    Mode        string      `json:"mode,omitempty"`   // "fischer" (default), "delay" or "bronstein"
+    Delay       int         `json:"delay,omitempty"`  // Seconds, for delay and bronstein
+    Moves       int         `json:"moves,omitempty"`  // Moves in the first period, 0 for the whole game
+    Stages      []TimeStage `json:"stages,omitempty"` // Later periods, e.g. 30 minutes after move 40
 }
 
+// Draw offers lapse after DrawOfferTTL; a player may offer again after DrawOfferCooldown
+const (
+    DrawOfferTTL      = 60 * time.Second
+    DrawOfferCooldown = 30 * time.Second
+)
+
 type DrawOffer struct {
     ID       string    `json:"id"`
     FromID   int       `json:"fromId"`
     ToID     int       `json:"toId"`
     CreatedAt time.Time `json:"createdAt"`
+    ExpiresAt time.Time `json:"expiresAt"`
 }
 
 type Game struct {
@@ -38,146 +47,216 @@ type Game struct {
     Spectators    map[int]*Player        `json:"spectators"`
     GameState     *engine.ServerGameState `json:"gameState"`
     TimeControl   *TimeControl           `json:"timeControl"`
-    WhiteTimeLeft int                    `json:"whiteTimeLeft"`
-    BlackTimeLeft int                    `json:"blackTimeLeft"`
-    LastMoveTime  time.Time              `json:"lastMoveTime"`
+    WhiteTimeLeft int                    `json:"whiteTimeLeft"` // Milliseconds
+    BlackTimeLeft int                    `json:"blackTimeLeft"` // Milliseconds
+    WhiteMoves    int                    `json:"whiteMoves"`    // Moves played, for multi-period controls
+    BlackMoves    int                    `json:"blackMoves"`
+    LastMoveTime  time.Time              `json:"lastMoveTime"`  // When the side to move's clock started
     CreatedAt     time.Time              `json:"createdAt"`
     UpdatedAt     time.Time              `json:"updatedAt"`
-    DrawOffers    map[string]*DrawOffer  `json:"drawOffers"` // Active draw offers
+    DrawOffers    map[string]*DrawOffer  `json:"drawOffers"` // Active draw offers, never shown to spectators
+    lastDrawOffer map[int]time.Time      // Per-player time of the last draw offer
+    lastTakebackRequest map[int]time.Time // Per-player time of the last takeback request
+    Rated         bool                   `json:"rated"`
+    TakebacksAllowed bool                `json:"takebacksAllowed"`
+    TakebackOffers map[string]*TakebackOffer `json:"takebackOffers"` // Pending takeback requests
+    SpectatorDelay int                   `json:"spectatorDelay,omitempty"` // Seconds spectators lag behind the players
+    MoveRecords   []entity.MoveRecord    `json:"moveRecords,omitempty"` // One per ply played so far
+    history       []plyUndo              // How to undo each ply, for takebacks
+    clock         *gameClock
+    abandonTimers map[int]*time.Timer    // Reconnection windows of disconnected players
+    claimant      int                    // Player who may claim the game after the opponent left
+    claimTimer    *time.Timer            // Gives the claimant the win if they don't claim in time
+    expired       map[int]bool           // Disconnected players whose reconnection window ran out
+    lastMoveIDs   map[int]string         // Per-player ID of the last move played, to spot retries
+    premoves      map[int][]Premove      // Moves queued by a player while waiting for the opponent
+    spectatorFeed *spectatorFeed         // Updates held back for spectators
+    spectatorView *StateUpdateMessage    // Latest position spectators have been shown
+    finished      bool
+    pendingFinish *finishRecord          // Result not journaled yet; the live snapshot holds it meanwhile
+    finishAttempts int                   // Failed journal writes so far, for the retry backoff
     mutex         sync.RWMutex
 }
 
 type GameManager struct {
-    redis    *redis.Client
-    games    map[string]*Game
-    mutex    sync.RWMutex
-    ctx      context.Context
-    savePool *GameSaveWorkerPool
+    redis           *redis.Client
+    games           map[string]*Game
+    mutex           sync.RWMutex
+    ctx             context.Context
+    savePool        *GameSaveWorkerPool
+    reconnectWindow time.Duration
+    firstMoveWindow time.Duration
+    claimWindow     time.Duration
+    maxPremoves     int
+    instanceID      string // Owner id for per-game Redis locks
+    recovering      map[string]bool // Restored games whose pending stream entries are not drained yet
+    cancelRead      context.CancelFunc // Interrupts ListenStreams' current read
+    events          EventPublisher
+    outboxWake      chan struct{}
 }
 
-type MoveMessage struct {
-    Type      string `json:"type"`
-    RoomID    string `json:"roomId"`
-    PlayerID  int    `json:"playerId"`
-    FromRow   int    `json:"fromRow"`
-    FromCol   int    `json:"fromCol"`
-    ToRow     int    `json:"toRow"`
-    ToCol     int    `json:"toCol"`
-    Promotion string `json:"promotion,omitempty"`
-}
+type MoveMessage = protocol.MoveMessage
 
-type GameActionMessage struct {
-    Type     string `json:"type"` // "gameAction"
-    RoomID   string `json:"roomId"`
-    PlayerID int    `json:"playerId"`
-    Action   string `json:"action"` // "resign", "drawOffer", "drawAccept", "drawDecline"
-    OfferID  string `json:"offerId,omitempty"` // For draw offers
-}
+type GameActionMessage = protocol.GameActionMessage
 
-type StateUpdateMessage struct {
-    Type          string                  `json:"type"`
-    RoomID        string                  `json:"roomId"`
-    GameState     engine.ClientGameState  `json:"gameState,omitempty"`
-    Player1       Player                  `json:"player1,omitempty"`
-    Player2       Player                  `json:"player2,omitempty"`
-    WhiteTimeLeft int                     `json:"whiteTimeLeft,omitempty"`
-    BlackTimeLeft int                     `json:"blackTimeLeft,omitempty"`
-    MoveHistory   []engine.MoveNotation   `json:"moveHistory,omitempty"`
-    Error         string                  `json:"error,omitempty"`
-    Result        string                  `json:"result,omitempty"`
-    Reason        string                  `json:"reason,omitempty"`
-    Winner        string                  `json:"winner,omitempty"`
-    OfferID       string                  `json:"offerId,omitempty"` // For draw offers
-    OfferFrom     int                     `json:"offerFrom,omitempty"` // Player ID who made the offer
-    TargetPlayerID *int                   `json:"targetPlayerId,omitempty"` // For targeted messages
-}
+type StateUpdateMessage = protocol.StateUpdateMessage
 
 func NewGameManager(redis *redis.Client, ctx context.Context, repo repository.GameRepository) *GameManager {
     return &GameManager{
         redis:    redis,
         games:    make(map[string]*Game),
-        savePool: NewGameSaveWorkerPool(repo, 3),
+        savePool: NewGameSaveWorkerPool(repo, redis, context.Background(), 3), // Outlives ctx until DrainSaves
         ctx:      ctx,
+        reconnectWindow: DefaultReconnectWindow,
+        firstMoveWindow: DefaultFirstMoveWindow,
+        claimWindow:     DefaultClaimWindow,
+        maxPremoves:     DefaultMaxPremoves,
+        instanceID:      newInstanceID(),
+        recovering:      make(map[string]bool),
+        outboxWake:      make(chan struct{}, 1),
     }
 }
 
-func (gm *GameManager) ListenChannels() {
-    // Start move listener
-    go gm.ListenMoves()
-    
-    // Start game action listener
-    go gm.ListenGameActions()
+// Save the finished games still queued; call on shutdown before closing Mongo and Redis
+func (gm *GameManager) DrainSaves(ctx context.Context) error {
+    return gm.savePool.Close(ctx)
 }
 
-func (gm *GameManager) ListenMoves() {
-    pubsub := gm.redis.Subscribe(gm.ctx, "move_in")
-    defer pubsub.Close()
-
-    for msg := range pubsub.Channel() {
-        var moveMsg MoveMessage
-        if err := json.Unmarshal([]byte(msg.Payload), &moveMsg); err != nil {
-            continue
-        }
-        gm.ProcessMove(moveMsg)
-    }
-}
-
-func (gm *GameManager) ListenGameActions() {
-    pubsub := gm.redis.Subscribe(gm.ctx, "game_action")
-    defer pubsub.Close()
-
-    for msg := range pubsub.Channel() {
-        var actionMsg GameActionMessage
-        if err := json.Unmarshal([]byte(msg.Payload), &actionMsg); err != nil {
-            continue
+func (gm *GameManager) ProcessMove(moveMsg MoveMessage) {
+    if moveMsg.Type == "getGameState" {
+        gameState, err := gm.GetGameState(moveMsg.RoomID)
+        if err != nil {
+            if !gm.ownedElsewhere(moveMsg.RoomID) {
+                gm.PublishError(moveMsg.RoomID, err.Error())
+            }
+            return
         }
-        gm.ProcessGameAction(actionMsg)
+        gm.publishGameState(gameState, moveMsg.PlayerID)
+        return
     }
-}
 
-func (gm *GameManager) ProcessMove(moveMsg MoveMessage) {
-    if moveMsg.Type == "getGameState" {
+    // ws-service missed trimmed updates: resend the full state to the whole room. An
+    // untargeted update still goes through the spectator delay.
+    if moveMsg.Type == "resync" {
         gameState, err := gm.GetGameState(moveMsg.RoomID)
         if err != nil {
-            gm.PublishError(moveMsg.RoomID, err.Error())
+            if !gm.ownedElsewhere(moveMsg.RoomID) {
+                gm.PublishError(moveMsg.RoomID, err.Error())
+            }
             return
         }
         gm.PublishStateUpdate(*gameState)
         return
     }
+
+    if moveMsg.Type == "getLegalMoves" {
+        gm.publishLegalMoves(moveMsg.RoomID, moveMsg.PlayerID)
+        return
+    }
     
     gm.mutex.RLock()
     game, exists := gm.games[moveMsg.RoomID]
     gm.mutex.RUnlock()
     
     if !exists {
+        if !gm.ownedElsewhere(moveMsg.RoomID) {
+            gm.PublishStateUpdate(StateUpdateMessage{
+                Type:           "moveRejected",
+                RoomID:         moveMsg.RoomID,
+                MoveID:         moveMsg.MoveID,
+                Reason:         "game not found",
+                TargetPlayerID: &moveMsg.PlayerID,
+            })
+        }
+        return
+    }
+
+    // A premove that arrives once it is already the player's turn is just a move
+    if moveMsg.Type == "premove" && !game.isTurnOf(moveMsg.PlayerID) {
+        gm.queuePremove(game, moveMsg)
         return
     }
 
     from := engine.Position{Row: moveMsg.FromRow, Col: moveMsg.FromCol}
     to := engine.Position{Row: moveMsg.ToRow, Col: moveMsg.ToCol}
+    promotion := moveMsg.Promotion
+
+    if moveMsg.SAN != "" {
+        var err error
+        from, to, promotion, err = game.ResolveSAN(moveMsg.SAN)
+        if err != nil {
+            gm.PublishStateUpdate(game.moveReply("moveRejected", moveMsg, err.Error()))
+            return
+        }
+    }
     
-    err := game.MakeMove(moveMsg.PlayerID, from, to, gm)
+    err := game.MakeMove(moveMsg.PlayerID, moveMsg.MoveID, from, to, promotion, gm)
+    if err == errDuplicateMove {
+        // A retry of a move we already played: just confirm it again
+        gm.PublishStateUpdate(game.moveReply("moveAck", moveMsg, ""))
+        return
+    }
+    if err == errGameMoved {
+        return
+    }
     if err != nil {
+        gm.PublishStateUpdate(game.moveReply("moveRejected", moveMsg, err.Error()))
         return
     }
 
-    stateUpdate := StateUpdateMessage{
-        Type:          "gameUpdate",
-        RoomID:        moveMsg.RoomID,
-        GameState:     engine.ClientGameState{
-            CurrentFen:     game.GameState.CurrentFen,
-            Bitboards:      game.GameState.Bitboards,
-            ActiveColor:    game.GameState.ActiveColor,
-            CastlingRights: game.GameState.CastlingRights,
-            EnPassantSquare: game.GameState.EnPassantSquare,
-        },
-        WhiteTimeLeft: game.WhiteTimeLeft,
-        BlackTimeLeft: game.BlackTimeLeft,
-        MoveHistory: game.GameState.MoveHistory,
-    }
+    gm.PublishStateUpdate(game.moveReply("moveAck", moveMsg, ""))
+
+    game.mutex.RLock()
+    stateUpdate := game.gameUpdate()
+    game.mutex.RUnlock()
     
     gm.PublishStateUpdate(stateUpdate)
+
+    // The opponent may have a premove waiting for exactly this
+    gm.playPremoves(game)
+}
+
+// Position and clocks after a move. Caller must hold g.mutex.
+func (g *Game) gameUpdate() StateUpdateMessage {
+    return StateUpdateMessage{
+        Type:          "gameUpdate",
+        RoomID:        g.ID,
+        GameState:     g.clientGameState(),
+        WhiteTimeLeft: g.WhiteTimeLeft,
+        BlackTimeLeft: g.BlackTimeLeft,
+        DelayLeft:     g.delayFor(g.GameState.ActiveColor),
+        ServerTime:    g.LastMoveTime.UnixMilli(),
+        MoveHistory:   wireHistory(g.GameState.MoveHistory),
+    }
+}
+
+// Answer the sender of a move. ServerTime is when the clocks were last charged, which
+// for an accepted move is the instant its time was deducted.
+func (g *Game) moveReply(replyType string, moveMsg MoveMessage, reason string) StateUpdateMessage {
+    g.mutex.RLock()
+    defer g.mutex.RUnlock()
+    return g.buildMoveReply(replyType, moveMsg.MoveID, moveMsg.PlayerID, reason)
+}
+
+// Caller must hold g.mutex
+func (g *Game) buildMoveReply(replyType, moveID string, playerID int, reason string) StateUpdateMessage {
+    reply := StateUpdateMessage{
+        Type:           replyType,
+        RoomID:         g.ID,
+        MoveID:         moveID,
+        Reason:         reason,
+        WhiteTimeLeft:  g.WhiteTimeLeft,
+        BlackTimeLeft:  g.BlackTimeLeft,
+        ServerTime:     g.LastMoveTime.UnixMilli(),
+        TargetPlayerID: &playerID,
+    }
+    if replyType == "moveRejected" {
+        // Let the client put its board back in sync
+        reply.GameState = g.clientGameState()
+        reply.MoveHistory = wireHistory(g.GameState.MoveHistory)
+        reply.DelayLeft = g.delayFor(g.GameState.ActiveColor)
+    }
+    return reply
 }
 
 func (gm *GameManager) ProcessGameAction(actionMsg GameActionMessage) {
@@ -198,6 +277,20 @@ func (gm *GameManager) ProcessGameAction(actionMsg GameActionMessage) {
         gm.handleDrawAccept(game, actionMsg.PlayerID, actionMsg.OfferID)
     case "drawDecline":
         gm.handleDrawDecline(game, actionMsg.PlayerID, actionMsg.OfferID)
+    case "abort":
+        gm.handleAbort(game, actionMsg.PlayerID)
+    case "claimVictory":
+        gm.handleClaimVictory(game, actionMsg.PlayerID)
+    case "claimDraw":
+        gm.handleClaimDraw(game, actionMsg.PlayerID)
+    case "takebackRequest":
+        gm.handleTakebackRequest(game, actionMsg.PlayerID)
+    case "takebackAccept":
+        gm.handleTakebackAccept(game, actionMsg.PlayerID, actionMsg.OfferID)
+    case "takebackDecline":
+        gm.handleTakebackDecline(game, actionMsg.PlayerID, actionMsg.OfferID)
+    case "cancelPremove":
+        gm.handleCancelPremove(game, actionMsg.PlayerID)
     default:
         gm.PublishError(actionMsg.RoomID, "Unknown action: "+actionMsg.Action)
     }
@@ -205,6 +298,8 @@ func (gm *GameManager) ProcessGameAction(actionMsg GameActionMessage) {
 
 func (gm *GameManager) handleResign(game *Game, playerID int) {
     game.mutex.Lock()
+    defer game.mutex.Unlock()
+
     var winner string
     for _, player := range game.Players {
         if player.ID != playerID {
@@ -213,19 +308,50 @@ func (gm *GameManager) handleResign(game *Game, playerID int) {
         }
     }
 
-    game.mutex.Unlock() 
-    
-    
-    game.endGame(winner, "resignation", gm)
-    
+    game.updatePlayerTime(game.GameState.ActiveColor)
+    game.endGame(winner, entity.ReasonResignation, gm)
+}
+
+// Abort is only allowed until both sides have made a move
+func (gm *GameManager) handleAbort(game *Game, playerID int) {
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    if _, isPlayer := game.Players[playerID]; !isPlayer {
+        return
+    }
+    if game.WhiteMoves > 0 && game.BlackMoves > 0 {
+        return
+    }
+
+    game.abortGame(entity.ReasonAborted, gm)
 }
 
 func (gm *GameManager) handleDrawOffer(game *Game, playerID int) {
     game.mutex.Lock()
     defer game.mutex.Unlock()
+
+    if _, isPlayer := game.Players[playerID]; !isPlayer || game.finished {
+        return
+    }
+
+    now := time.Now()
+    game.pruneDrawOffers(now)
+
+    // One pending offer per player, and a cooldown between offers
+    for _, offer := range game.DrawOffers {
+        if offer.FromID == playerID {
+            gm.publishErrorTo(game.ID, playerID, "draw offer already pending")
+            return
+        }
+    }
+    if last, ok := game.lastDrawOffer[playerID]; ok && now.Sub(last) < DrawOfferCooldown {
+        gm.publishErrorTo(game.ID, playerID, "too many draw offers, try again later")
+        return
+    }
     
     // Generate offer ID
-    offerID := fmt.Sprintf("%s_%d_%d", game.ID, playerID, time.Now().Unix())
+    offerID := fmt.Sprintf("%s_%d_%d", game.ID, playerID, now.Unix())
     
     // Find opponent
     var opponentID int
@@ -241,16 +367,24 @@ func (gm *GameManager) handleDrawOffer(game *Game, playerID int) {
         ID:        offerID,
         FromID:    playerID,
         ToID:      opponentID,
-        CreatedAt: time.Now(),
+        CreatedAt: now,
+        ExpiresAt: now.Add(DrawOfferTTL),
     }
     
     // Initialize DrawOffers map if nil
     if game.DrawOffers == nil {
         game.DrawOffers = make(map[string]*DrawOffer)
     }
+    if game.lastDrawOffer == nil {
+        game.lastDrawOffer = make(map[int]time.Time)
+    }
     
     // Store offer
     game.DrawOffers[offerID] = offer
+    game.lastDrawOffer[playerID] = now
+    if !gm.persistGame(game) {
+        return
+    }
     
     // Notify opponent only (targeted message)
     update := StateUpdateMessage{
@@ -258,33 +392,71 @@ func (gm *GameManager) handleDrawOffer(game *Game, playerID int) {
         RoomID:         game.ID,
         OfferID:        offerID,
         OfferFrom:      playerID,
+        OfferExpiresAt: offer.ExpiresAt.UnixMilli(),
         TargetPlayerID: &opponentID, 
     }
     
     gm.PublishStateUpdate(update)
 }
 
+// Drop expired draw offers. Caller must hold game.mutex.
+func (g *Game) pruneDrawOffers(now time.Time) {
+    for offerID, offer := range g.DrawOffers {
+        if now.After(offer.ExpiresAt) {
+            delete(g.DrawOffers, offerID)
+        }
+    }
+}
+
 func (gm *GameManager) handleDrawAccept(game *Game, playerID int, offerID string) {
     game.mutex.Lock()
+    defer game.mutex.Unlock()
     
+    game.pruneDrawOffers(time.Now())
+
     // Check if offer exists
     offer, exists := game.DrawOffers[offerID]
     if !exists {
-        game.mutex.Unlock() 
+        gm.publishErrorTo(game.ID, playerID, "draw offer expired")
         return
     }
     
     // Check if player is the recipient of the offer
     if offer.ToID != playerID {
-        game.mutex.Unlock() 
         return
     }
     
     game.DrawOffers = make(map[string]*DrawOffer)
-    
-    game.mutex.Unlock() 
 
-    game.endGame("", "draw by agreement", gm) 
+    game.updatePlayerTime(game.GameState.ActiveColor)
+    game.endGame("", entity.ReasonDrawAgreement, gm)
+}
+
+// Claim a draw by threefold repetition or the fifty-move rule, or against an
+// opponent whose reconnection window expired
+func (gm *GameManager) handleClaimDraw(game *Game, playerID int) {
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    if _, isPlayer := game.Players[playerID]; !isPlayer || game.finished {
+        return
+    }
+
+    if game.claimant != 0 && game.claimant == playerID {
+        game.updatePlayerTime(game.GameState.ActiveColor)
+        game.endGame("", entity.ReasonAbandonmentDraw, gm)
+        return
+    }
+
+    chessEngine := &engine.ChessEngine{}
+    claimable, reason := chessEngine.CanClaimDraw(game.GameState)
+    if !claimable {
+        gm.publishErrorTo(game.ID, playerID, "no draw to claim in this position")
+        return
+    }
+
+    game.updatePlayerTime(game.GameState.ActiveColor)
+    game.endGame("", reason, gm)
 }
 
 func (gm *GameManager) handleDrawDecline(game *Game, playerID int, offerID string) {
@@ -304,12 +476,16 @@ func (gm *GameManager) handleDrawDecline(game *Game, playerID int, offerID strin
     
     // Remove offer
     delete(game.DrawOffers, offerID)
+    if !gm.persistGame(game) {
+        return
+    }
     
     // Notify that offer was declined
     update := StateUpdateMessage{
-        Type:    "drawDeclined",
-        RoomID:  game.ID,
-        OfferID: offerID,
+        Type:     "drawDeclined",
+        RoomID:   game.ID,
+        OfferID:  offerID,
+        Audience: protocol.AudiencePlayers,
     }
     
     gm.PublishStateUpdate(update)
@@ -327,6 +503,9 @@ func (gm *GameManager) GetGameState(gameID string) (*StateUpdateMessage, error)
     if game.GameState == nil {
         return nil, fmt.Errorf("game state is invalid")
     }
+
+    game.mutex.RLock()
+    defer game.mutex.RUnlock()
     
     var player1, player2 *Player
     for _, player := range game.Players {
@@ -340,45 +519,150 @@ func (gm *GameManager) GetGameState(gameID string) (*StateUpdateMessage, error)
     gameStateMsg := &StateUpdateMessage{
         Type:   "gameState",
         RoomID: gameID,
-        GameState: engine.ClientGameState{
-            CurrentFen:      game.GameState.CurrentFen,
-            Bitboards:       game.GameState.Bitboards,
-            ActiveColor:     game.GameState.ActiveColor,
-            CastlingRights:  game.GameState.CastlingRights,
-            EnPassantSquare: game.GameState.EnPassantSquare,
-        },
+        GameState: game.clientGameState(),
         Player1:       *player1,
         Player2:       *player2,
-        WhiteTimeLeft: game.WhiteTimeLeft,
-        BlackTimeLeft: game.BlackTimeLeft,
+        WhiteTimeLeft: game.timeLeft("white"),
+        BlackTimeLeft: game.timeLeft("black"),
+        DelayLeft:     game.delayLeft(),
+        TimeControl:   game.TimeControl.wire(),
+        ServerTime:    time.Now().UnixMilli(),
+        SpectatorCount: len(game.Spectators),
     }
     
     return gameStateMsg, nil
 }
 
+// Position as sent to clients
+func (gm *GameManager) publishLegalMoves(gameID string, playerID int) {
+    gm.mutex.RLock()
+    game, exists := gm.games[gameID]
+    gm.mutex.RUnlock()
+    
+    if !exists {
+        if !gm.ownedElsewhere(gameID) {
+            gm.PublishError(gameID, "game not found")
+        }
+        return
+    }
+
+    // Spectators would get the live position around the spectator delay
+    game.mutex.RLock()
+    _, isPlayer := game.Players[playerID]
+    game.mutex.RUnlock()
+    if !isPlayer {
+        return
+    }
+    
+    update := StateUpdateMessage{
+        Type:           "legalMoves",
+        RoomID:         gameID,
+        LegalMoves:     wireMoves(game.LegalMoves()),
+        TargetPlayerID: &playerID,
+    }
+    
+    gm.PublishStateUpdate(update)
+}
+
 func (gm *GameManager) PublishStateUpdate(update StateUpdateMessage) {
+    // Spectators of a delayed game see untargeted updates only once the delay has passed
+    if update.TargetPlayerID == nil && update.Audience == "" {
+        if game := gm.delayedGame(update.RoomID); game != nil {
+            update.Audience = protocol.AudiencePlayers
+            game.queueForSpectators(update, gm)
+        }
+    }
+    gm.appendUpdate(update)
+}
+
+// Append an update to the game's outbound stream as is
+func (gm *GameManager) appendUpdate(update StateUpdateMessage) {
+    update.Version = protocol.Version
+    data, err := json.Marshal(update)
+    if err != nil {
+        log.Printf("Failed to encode %s update for game %s: %v", update.Type, update.RoomID, err)
+        return
+    }
+
+    err = publishUpdateScript.Run(gm.ctx, gm.redis,
+        []string{protocol.OutStreamKey(update.RoomID), protocol.OutSeqKey(update.RoomID)},
+        data, outStreamMaxLen, outStreamTTL.Milliseconds()).Err()
+    if err != nil {
+        log.Printf("Failed to publish %s update for game %s: %v", update.Type, update.RoomID, err)
+    }
+}
+
+// Tell the matched players where their game is; not part of any game's stream
+func (gm *GameManager) PublishMatchFound(update StateUpdateMessage) {
+    update.Version = protocol.Version
     data, _ := json.Marshal(update)
-    gm.redis.Publish(gm.ctx, "move_out", data)
+    err := gm.redis.XAdd(gm.ctx, &redis.XAddArgs{
+        Stream: protocol.MatchFoundStream,
+        MaxLen: outStreamMaxLen,
+        Approx: true,
+        Values: map[string]interface{}{protocol.FieldData: data},
+    }).Err()
+    if err != nil {
+        log.Printf("Failed to publish matchFound for game %s: %v", update.RoomID, err)
+    }
 }
 
 func (gm *GameManager) PublishError(roomID, errorMsg string) {
-    errorUpdate := StateUpdateMessage{
+    gm.PublishStateUpdate(StateUpdateMessage{
         Type:   "error", 
         RoomID: roomID,
         Error:  errorMsg,
-    }
-    data, _ := json.Marshal(errorUpdate)
-    gm.redis.Publish(gm.ctx, "move_out", data)
+    })
 }
 
-func (gm *GameManager) AddGame(game *Game) {
+// Error for one player only
+func (gm *GameManager) publishErrorTo(roomID string, playerID int, errorMsg string) {
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:           "error",
+        RoomID:         roomID,
+        Error:          errorMsg,
+        TargetPlayerID: &playerID,
+    })
+}
+
+// Register a new game: take its lock, keep it here and write the first snapshot
+func (gm *GameManager) AddGame(game *Game) error {
+    if claimed, err := gm.claimGame(game.ID); err != nil || !claimed {
+        return fmt.Errorf("could not claim game %s: %v", game.ID, err)
+    }
+    if err := gm.ensureInStream(game.ID); err != nil {
+        releaseGameScript.Run(gm.ctx, gm.redis, []string{gameLockKey(game.ID)}, gm.instanceID)
+        return fmt.Errorf("could not create stream for game %s: %v", game.ID, err)
+    }
+
+    game.mutex.Lock()
+    game.initSpectatorFeed()
+    game.mutex.Unlock()
+
     gm.mutex.Lock()
-    defer gm.mutex.Unlock()
     gm.games[game.ID] = game
+    gm.interruptStreamRead()
+    gm.mutex.Unlock()
+
+    // Outside gm.mutex: a flag fall locks the game, then removes it from the manager
+    gm.StartClock(game)
+
+    game.mutex.Lock()
+    gm.persistGame(game)
+    game.mutex.Unlock()
+    return nil
+}
+
+// Whether g is the copy of its game this instance runs
+func (gm *GameManager) owns(g *Game) bool {
+    gm.mutex.RLock()
+    defer gm.mutex.RUnlock()
+    return gm.games[g.ID] == g
 }
 
 func (gm *GameManager) RemoveGame(gameID string) {
     gm.mutex.Lock()
     defer gm.mutex.Unlock()
     delete(gm.games, gameID)
+    delete(gm.recovering, gameID)
 }
\ No newline at end of file
@@ -0,0 +1,149 @@
+package game
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "time"
+    "github.com/go-redis/redis/v8"
+    "github.com/locne/game-service/internal/entity"
+    "github.com/locne/protocol"
+)
+
+// Events for other services go into a Redis outbox in the same transaction that journals
+// the finished game, and are relayed to the broker from there. An entry only leaves the
+// outbox once the broker has confirmed it, so events survive crashes and broker outages.
+const (
+    outboxKey      = "events:outbox"       // Hash: game ID -> GameFinishedEvent JSON
+    outboxLockKey  = "events:outbox:relay" // Held by the instance relaying right now
+    outboxLockTTL  = 30 * time.Second
+    outboxInterval = 5 * time.Second
+)
+
+// Delivers domain events to other services
+type EventPublisher interface {
+    // Returns once the broker has taken responsibility for the event
+    PublishGameFinished(ctx context.Context, event protocol.GameFinishedEvent) error
+}
+
+func (gm *GameManager) SetEventPublisher(publisher EventPublisher) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+    gm.events = publisher
+}
+
+func finishedEvent(game entity.Game) protocol.GameFinishedEvent {
+    moves := game.Moves
+    if moves == nil {
+        moves = []string{}
+    }
+
+    return protocol.GameFinishedEvent{
+        Version: protocol.Version,
+        GameID:  game.GameID,
+        White: protocol.FinishedPlayer{
+            UserID:   game.Players.White.UserID,
+            Username: game.Players.White.Username,
+            Rating:   game.Players.White.Elo,
+        },
+        Black: protocol.FinishedPlayer{
+            UserID:   game.Players.Black.UserID,
+            Username: game.Players.Black.Username,
+            Rating:   game.Players.Black.Elo,
+        },
+        Result:      game.Result,
+        Reason:      game.Reason,
+        Aborted:     entity.IsAbortReason(game.Reason),
+        Rated:       game.Rated,
+        GameType:    game.GameType,
+        TimeControl: game.TimeControl,
+        Variant:     game.Variant,
+        InitialFen:  game.InitialFen,
+        Moves:       moves,
+        FinishedAt:  time.Now().UnixMilli(),
+    }
+}
+
+// Journal the finished game and queue its game.finished event, atomically
+func (gm *GameManager) recordFinishedGame(game entity.Game) error {
+    eventData, err := json.Marshal(finishedEvent(game))
+    if err != nil {
+        return err
+    }
+
+    pipe := gm.redis.TxPipeline()
+    if err := gm.savePool.journal(pipe, game); err != nil {
+        return err
+    }
+    pipe.HSet(gm.ctx, outboxKey, game.GameID, eventData)
+    if _, err := pipe.Exec(gm.ctx); err != nil {
+        return err
+    }
+
+    select {
+    case gm.outboxWake <- struct{}{}:
+    default:
+    }
+    return nil
+}
+
+// Relay outbox entries to the broker until the manager's context is cancelled
+func (gm *GameManager) RunOutbox() {
+    ticker := time.NewTicker(outboxInterval)
+    defer ticker.Stop()
+
+    for {
+        gm.relayOutbox()
+
+        select {
+        case <-gm.ctx.Done():
+            return
+        case <-ticker.C:
+        case <-gm.outboxWake:
+        }
+    }
+}
+
+func (gm *GameManager) relayOutbox() {
+    gm.mutex.RLock()
+    publisher := gm.events
+    gm.mutex.RUnlock()
+    if publisher == nil {
+        return
+    }
+
+    // One relaying instance at a time keeps duplicates down; consumers dedupe the rest
+    locked, err := gm.redis.SetNX(gm.ctx, outboxLockKey, gm.instanceID, outboxLockTTL).Result()
+    if err != nil || !locked {
+        return
+    }
+    defer releaseGameScript.Run(gm.ctx, gm.redis, []string{outboxLockKey}, gm.instanceID)
+
+    entries, err := gm.redis.HGetAll(gm.ctx, outboxKey).Result()
+    if err != nil {
+        log.Printf("Failed to read the event outbox: %v", err)
+        return
+    }
+
+    for gameID, data := range entries {
+        var event protocol.GameFinishedEvent
+        if err := json.Unmarshal([]byte(data), &event); err != nil {
+            log.Printf("Dropping unreadable outbox entry for game %s: %v", gameID, err)
+            gm.redis.HDel(gm.ctx, outboxKey, gameID)
+            continue
+        }
+
+        ctx, cancel := context.WithTimeout(gm.ctx, 10*time.Second)
+        err := publisher.PublishGameFinished(ctx, event)
+        cancel()
+        if err != nil {
+            // Broker trouble: everything left waits for the next round
+            log.Printf("Failed to publish game.finished for game %s: %v", gameID, err)
+            return
+        }
+
+        if err := gm.redis.HDel(gm.ctx, outboxKey, gameID).Err(); err != nil && err != redis.Nil {
+            log.Printf("Failed to clear outbox entry for game %s: %v", gameID, err)
+        }
+    }
+}
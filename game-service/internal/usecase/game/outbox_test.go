@@ -0,0 +1,42 @@
+package game
+
+import (
+    "testing"
+
+    "github.com/locne/game-service/internal/entity"
+    "github.com/locne/game-service/internal/usecase/engine"
+)
+
+// Consumers only rate and analyze standard chess from the initial position, and every
+// game carries its start FEN, so the standard one must read as such
+func TestFinishedEventStandardChess(t *testing.T) {
+    tests := []struct {
+        variant  string
+        fen      string // "" for the variant's own start
+        standard bool
+    }{
+        {engine.VariantStandard, "", true},
+        {engine.VariantStandard, "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false},
+        {engine.VariantChess960, "", false},
+        {engine.VariantThreeCheck, "", false},
+    }
+
+    ce := &engine.ChessEngine{}
+    for _, test := range tests {
+        var state *engine.ServerGameState
+        var err error
+        if test.fen == "" {
+            state, err = ce.CreateVariantGameState(test.variant, 1)
+        } else {
+            state, err = ce.CreateServerGameStateFromFEN(test.fen, test.variant)
+        }
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        event := finishedEvent(entity.Game{GameID: "g1", Variant: state.Variant, InitialFen: state.InitialFen})
+        if event.IsStandardChess() != test.standard {
+            t.Fatalf("%s from %s: standard %v, want %v", test.variant, event.InitialFen, !test.standard, test.standard)
+        }
+    }
+}
@@ -0,0 +1,168 @@
+package game
+
+import (
+    "fmt"
+    "github.com/locne/game-service/internal/usecase/engine"
+)
+
+// Default number of moves a player may queue while waiting for the opponent
+const DefaultMaxPremoves = 1
+
+// A move queued while it was the opponent's turn, played as soon as our turn comes
+type Premove struct {
+    MoveID    string          `json:"moveId,omitempty"`
+    From      engine.Position `json:"from"`
+    To        engine.Position `json:"to"`
+    Promotion string          `json:"promotion,omitempty"`
+    SAN       string          `json:"san,omitempty"` // Resolved against the position it is played in
+}
+
+func (gm *GameManager) SetMaxPremoves(n int) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+    gm.maxPremoves = n
+}
+
+func (g *Game) isTurnOf(playerID int) bool {
+    g.mutex.RLock()
+    defer g.mutex.RUnlock()
+
+    player, exists := g.Players[playerID]
+    return exists && player.Color == g.GameState.ActiveColor
+}
+
+func (gm *GameManager) queuePremove(game *Game, moveMsg MoveMessage) {
+    gm.mutex.RLock()
+    limit := gm.maxPremoves
+    gm.mutex.RUnlock()
+
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    reject := func(reason string) {
+        gm.PublishStateUpdate(game.buildMoveReply("premoveRejected", moveMsg.MoveID, moveMsg.PlayerID, reason))
+    }
+
+    player, exists := game.Players[moveMsg.PlayerID]
+    if !exists {
+        reject("player not found in this game")
+        return
+    }
+    if game.finished {
+        reject("game is over")
+        return
+    }
+    if !player.IsOnline {
+        reject("player is offline")
+        return
+    }
+    if len(game.premoves[player.ID]) >= limit {
+        reject(fmt.Sprintf("at most %d premove(s) can be queued", limit))
+        return
+    }
+
+    if game.premoves == nil {
+        game.premoves = make(map[int][]Premove)
+    }
+    game.premoves[player.ID] = append(game.premoves[player.ID], Premove{
+        MoveID:    moveMsg.MoveID,
+        From:      engine.Position{Row: moveMsg.FromRow, Col: moveMsg.FromCol},
+        To:        engine.Position{Row: moveMsg.ToRow, Col: moveMsg.ToCol},
+        Promotion: moveMsg.Promotion,
+        SAN:       moveMsg.SAN,
+    })
+    gm.persistGame(game)
+
+    gm.PublishStateUpdate(game.buildMoveReply("premoveQueued", moveMsg.MoveID, player.ID, ""))
+}
+
+// Play queued premoves for as long as the side to move has one
+func (gm *GameManager) playPremoves(game *Game) {
+    for {
+        updates, played := game.playNextPremove(gm)
+        for _, update := range updates {
+            gm.PublishStateUpdate(update)
+        }
+        if !played {
+            return
+        }
+    }
+}
+
+func (g *Game) playNextPremove(gm *GameManager) ([]StateUpdateMessage, bool) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    if g.finished {
+        return nil, false
+    }
+
+    var player *Player
+    for _, p := range g.Players {
+        if p.Color == g.GameState.ActiveColor {
+            player = p
+        }
+    }
+    if player == nil || len(g.premoves[player.ID]) == 0 {
+        return nil, false
+    }
+
+    premove := g.premoves[player.ID][0]
+    g.premoves[player.ID] = g.premoves[player.ID][1:]
+
+    err := g.playPremove(player, premove, gm)
+    if err == errGameMoved {
+        return nil, false
+    }
+    if err != nil {
+        // Later premoves were planned on top of this one: drop them too
+        delete(g.premoves, player.ID)
+        if !g.finished {
+            gm.persistGame(g)
+        }
+        return []StateUpdateMessage{
+            g.buildMoveReply("premoveRejected", premove.MoveID, player.ID, err.Error()),
+        }, false
+    }
+
+    return []StateUpdateMessage{
+        g.buildMoveReply("moveAck", premove.MoveID, player.ID, ""),
+        g.gameUpdate(),
+    }, true
+}
+
+// Caller must hold g.mutex
+func (g *Game) playPremove(player *Player, premove Premove, gm *GameManager) error {
+    if !player.IsOnline {
+        return fmt.Errorf("player is offline")
+    }
+
+    from, to, promotion := premove.From, premove.To, premove.Promotion
+    if premove.SAN != "" {
+        chessEngine := &engine.ChessEngine{}
+        var err error
+        from, to, promotion, err = chessEngine.ParseSAN(g.GameState.Bitboards, g.GameState.GameState(), premove.SAN)
+        if err != nil {
+            return err
+        }
+    }
+
+    return g.applyMove(player.ID, premove.MoveID, from, to, promotion, true, gm)
+}
+
+func (gm *GameManager) handleCancelPremove(game *Game, playerID int) {
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    if _, queued := game.premoves[playerID]; !queued {
+        return
+    }
+    delete(game.premoves, playerID)
+    gm.persistGame(game)
+
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:           "premoveCancelled",
+        RoomID:         game.ID,
+        TargetPlayerID: &playerID,
+    })
+}
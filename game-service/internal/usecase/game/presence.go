@@ -0,0 +1,240 @@
+package game
+
+import (
+    "time"
+    "github.com/locne/game-service/internal/entity"
+    "github.com/locne/protocol"
+)
+
+// Default time a disconnected player has to come back before the game is decided
+const DefaultReconnectWindow = 60 * time.Second
+
+// Default time the remaining player has to claim a win or a draw once the opponent's
+// reconnection window expired; after that the win is theirs automatically
+const DefaultClaimWindow = 30 * time.Second
+
+type PresenceMessage = protocol.PresenceMessage
+
+func (gm *GameManager) SetReconnectWindow(window time.Duration) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+    gm.reconnectWindow = window
+}
+
+func (gm *GameManager) SetClaimWindow(window time.Duration) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+    gm.claimWindow = window
+}
+
+func (gm *GameManager) ProcessPresence(presenceMsg PresenceMessage) {
+    gm.mutex.RLock()
+    game, exists := gm.games[presenceMsg.RoomID]
+    window := gm.reconnectWindow
+    gm.mutex.RUnlock()
+
+    if !exists {
+        return
+    }
+
+    if presenceMsg.Role == "spectator" {
+        gm.handleSpectatorPresence(game, presenceMsg)
+        return
+    }
+
+    switch presenceMsg.Status {
+    case "disconnected":
+        game.handleDisconnect(presenceMsg.PlayerID, window, gm)
+    case "connected":
+        game.handleReconnect(presenceMsg.PlayerID, gm)
+    }
+}
+
+func (g *Game) handleDisconnect(playerID int, window time.Duration, gm *GameManager) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    // Spectators come and go freely
+    player, exists := g.Players[playerID]
+    if !exists || g.finished || !player.IsOnline {
+        return
+    }
+
+    player.IsOnline = false
+    g.startAbandonTimer(playerID, window, gm)
+    gm.persistGame(g)
+
+    opponent := g.opponentOf(playerID)
+    if opponent == nil {
+        return
+    }
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:              "opponentDisconnected",
+        RoomID:            g.ID,
+        PlayerID:          playerID,
+        ReconnectTimeLeft: int(window.Milliseconds()),
+        TargetPlayerID:    &opponent.ID,
+    })
+}
+
+func (g *Game) handleReconnect(playerID int, gm *GameManager) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    player, exists := g.Players[playerID]
+    if !exists || g.finished || player.IsOnline {
+        return
+    }
+
+    player.IsOnline = true
+    if timer, ok := g.abandonTimers[playerID]; ok {
+        timer.Stop()
+        delete(g.abandonTimers, playerID)
+    }
+    delete(g.expired, playerID)
+    if g.claimant != playerID {
+        // Back before the opponent claimed: the game goes on
+        g.cancelClaim()
+    }
+
+    // The opponent's window ran out while we were away too: the claim is ours now
+    opponent := g.opponentOf(playerID)
+    if opponent != nil && g.claimant == 0 && g.expired[opponent.ID] && !opponent.IsOnline {
+        g.offerClaim(playerID, opponent.ID, gm)
+    }
+    gm.persistGame(g)
+
+    if opponent == nil {
+        return
+    }
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:           "opponentReconnected",
+        RoomID:         g.ID,
+        PlayerID:       playerID,
+        TargetPlayerID: &opponent.ID,
+    })
+}
+
+// The player did not come back in time: abort a game that barely started, otherwise
+// let the opponent claim a win or a draw. An opponent who is away as well gets the
+// claim when they come back.
+func (gm *GameManager) onReconnectWindowExpired(g *Game, playerID int) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    player, exists := g.Players[playerID]
+    if !exists || g.finished || player.IsOnline {
+        return
+    }
+    delete(g.abandonTimers, playerID)
+
+    if g.WhiteMoves+g.BlackMoves < 2 {
+        g.updatePlayerTime(g.GameState.ActiveColor)
+        g.abortGame(entity.ReasonDisconnectAbort, gm)
+        return
+    }
+
+    if g.expired == nil {
+        g.expired = make(map[int]bool)
+    }
+    g.expired[playerID] = true
+
+    if opponent := g.opponentOf(playerID); opponent != nil && opponent.IsOnline {
+        g.offerClaim(opponent.ID, playerID, gm)
+    }
+    gm.persistGame(g)
+}
+
+// Let claimantID claim a win or a draw against absentID. Caller must hold g.mutex.
+func (g *Game) offerClaim(claimantID, absentID int, gm *GameManager) {
+    g.claimant = claimantID
+    g.startClaimTimer(gm)
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:           "claimAvailable",
+        RoomID:         g.ID,
+        PlayerID:       absentID,
+        TargetPlayerID: &claimantID,
+    })
+}
+
+// Award the claimant the win if they don't decide in time. Caller must hold g.mutex
+// (taken before gm.mutex, as everywhere).
+func (g *Game) startClaimTimer(gm *GameManager) {
+    gm.mutex.RLock()
+    window := gm.claimWindow
+    gm.mutex.RUnlock()
+
+    if g.claimTimer != nil {
+        g.claimTimer.Stop()
+    }
+    claimant := g.claimant
+    g.claimTimer = time.AfterFunc(window, func() {
+        gm.onClaimWindowExpired(g, claimant)
+    })
+}
+
+// Withdraw a pending claim. Caller must hold g.mutex.
+func (g *Game) cancelClaim() {
+    g.claimant = 0
+    if g.claimTimer != nil {
+        g.claimTimer.Stop()
+        g.claimTimer = nil
+    }
+}
+
+func (gm *GameManager) onClaimWindowExpired(g *Game, claimant int) {
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+
+    // Claimed, or the opponent came back just before the timer fired
+    if g.finished || g.claimant != claimant {
+        return
+    }
+    g.claimTimer = nil
+    g.updatePlayerTime(g.GameState.ActiveColor)
+    g.endGame(g.Players[claimant].Color, entity.ReasonAbandonment, gm)
+}
+
+// Claim a win against an opponent whose reconnection window expired
+func (gm *GameManager) handleClaimVictory(game *Game, playerID int) {
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    if game.finished || game.claimant == 0 || game.claimant != playerID {
+        return
+    }
+
+    game.updatePlayerTime(game.GameState.ActiveColor)
+    game.endGame(game.Players[playerID].Color, entity.ReasonAbandonment, gm)
+}
+
+// Give a disconnected player window to come back. Caller must hold g.mutex.
+func (g *Game) startAbandonTimer(playerID int, window time.Duration, gm *GameManager) {
+    if g.abandonTimers == nil {
+        g.abandonTimers = make(map[int]*time.Timer)
+    }
+    g.abandonTimers[playerID] = time.AfterFunc(window, func() {
+        gm.onReconnectWindowExpired(g, playerID)
+    })
+}
+
+// Stop pending abandonment and claim timers. Caller must hold g.mutex.
+func (g *Game) stopAbandonTimers() {
+    for playerID, timer := range g.abandonTimers {
+        timer.Stop()
+        delete(g.abandonTimers, playerID)
+    }
+    if g.claimTimer != nil {
+        g.claimTimer.Stop()
+        g.claimTimer = nil
+    }
+}
+
+func (g *Game) opponentOf(playerID int) *Player {
+    for _, player := range g.Players {
+        if player.ID != playerID {
+            return player
+        }
+    }
+    return nil
+}
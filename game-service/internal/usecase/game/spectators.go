@@ -0,0 +1,169 @@
+package game
+
+import (
+    "sync"
+    "time"
+    "github.com/locne/protocol"
+)
+
+// Longest broadcast delay a game may ask for
+const MaxSpectatorDelay = 15 * 60
+
+// Updates held back from the spectators of a delayed game, in publishing order
+type spectatorFeed struct {
+    mutex   sync.Mutex
+    queue   []heldUpdate
+    running bool
+}
+
+type heldUpdate struct {
+    due    time.Time
+    update StateUpdateMessage
+}
+
+// Set up the spectator delay of a new or restored game. Caller must hold g.mutex.
+func (g *Game) initSpectatorFeed() {
+    if g.SpectatorDelay <= 0 {
+        return
+    }
+    g.spectatorFeed = &spectatorFeed{}
+    view := g.gameUpdate()
+    g.spectatorView = &view
+}
+
+// The game if it holds updates back from its spectators
+func (gm *GameManager) delayedGame(gameID string) *Game {
+    gm.mutex.RLock()
+    game, exists := gm.games[gameID]
+    gm.mutex.RUnlock()
+
+    if !exists || game.spectatorFeed == nil {
+        return nil
+    }
+    return game
+}
+
+// Publish a spectator copy of update once the delay has passed. May be called with g.mutex held.
+func (g *Game) queueForSpectators(update StateUpdateMessage, gm *GameManager) {
+    update.Audience = protocol.AudienceSpectators
+
+    feed := g.spectatorFeed
+    feed.mutex.Lock()
+    defer feed.mutex.Unlock()
+
+    feed.queue = append(feed.queue, heldUpdate{
+        due:    time.Now().Add(time.Duration(g.SpectatorDelay) * time.Second),
+        update: update,
+    })
+    if !feed.running {
+        feed.running = true
+        go g.runSpectatorFeed(gm)
+    }
+}
+
+func (g *Game) runSpectatorFeed(gm *GameManager) {
+    feed := g.spectatorFeed
+    for {
+        feed.mutex.Lock()
+        if len(feed.queue) == 0 {
+            feed.running = false
+            feed.mutex.Unlock()
+            return
+        }
+        next := feed.queue[0]
+        feed.queue = feed.queue[1:]
+        feed.mutex.Unlock()
+
+        time.Sleep(time.Until(next.due))
+        g.rememberSpectatorView(next.update)
+        gm.appendUpdate(next.update)
+    }
+}
+
+// Keep the last position spectators were shown, for spectators who join later
+func (g *Game) rememberSpectatorView(update StateUpdateMessage) {
+    switch update.Type {
+    case "gameUpdate", "takebackAccepted", "gameState":
+    default:
+        return
+    }
+
+    g.mutex.Lock()
+    defer g.mutex.Unlock()
+    g.spectatorView = &update
+}
+
+func (g *Game) isSpectator(userID int) bool {
+    g.mutex.RLock()
+    defer g.mutex.RUnlock()
+
+    _, exists := g.Spectators[userID]
+    return exists
+}
+
+// Answer a getGameState request. Players share the answer with the room; a spectator
+// gets its own, showing the delayed position in a delayed game.
+func (gm *GameManager) publishGameState(state *StateUpdateMessage, requesterID int) {
+    gm.mutex.RLock()
+    game, exists := gm.games[state.RoomID]
+    gm.mutex.RUnlock()
+
+    if !exists || !game.isSpectator(requesterID) {
+        gm.PublishStateUpdate(*state)
+        return
+    }
+
+    game.mutex.RLock()
+    if view := game.spectatorView; view != nil {
+        state.GameState = view.GameState
+        state.MoveHistory = view.MoveHistory
+        state.WhiteTimeLeft = view.WhiteTimeLeft
+        state.BlackTimeLeft = view.BlackTimeLeft
+        state.DelayLeft = view.DelayLeft
+        state.ServerTime = view.ServerTime
+    }
+    game.mutex.RUnlock()
+
+    state.TargetPlayerID = &requesterID
+    gm.PublishStateUpdate(*state)
+}
+
+// Track who is watching and tell the room how many
+func (gm *GameManager) handleSpectatorPresence(game *Game, presenceMsg PresenceMessage) {
+    game.mutex.Lock()
+
+    // Players keep their seat even if they open the game as spectators
+    if _, isPlayer := game.Players[presenceMsg.PlayerID]; isPlayer || game.finished {
+        game.mutex.Unlock()
+        return
+    }
+    if game.Spectators == nil {
+        game.Spectators = make(map[int]*Player)
+    }
+
+    _, watching := game.Spectators[presenceMsg.PlayerID]
+    switch presenceMsg.Status {
+    case "connected":
+        if watching {
+            game.mutex.Unlock()
+            return
+        }
+        game.Spectators[presenceMsg.PlayerID] = &Player{ID: presenceMsg.PlayerID, IsOnline: true}
+    case "disconnected":
+        if !watching {
+            game.mutex.Unlock()
+            return
+        }
+        delete(game.Spectators, presenceMsg.PlayerID)
+    }
+    count := len(game.Spectators)
+    gm.persistGame(game)
+    game.mutex.Unlock()
+
+    // Not part of the game itself, so nothing to hold back
+    gm.appendUpdate(StateUpdateMessage{
+        Type:           "spectatorCount",
+        RoomID:         game.ID,
+        SpectatorCount: count,
+    })
+}
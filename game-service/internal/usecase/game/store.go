@@ -0,0 +1,318 @@
+package game
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "time"
+    "github.com/go-redis/redis/v8"
+)
+
+// Live games are kept in Redis so another instance (or this one after a restart) can pick
+// them up. Each game is owned by exactly one instance through an expiring lock key; only
+// the owner keeps the game in GameManager.games and so processes its moves.
+const (
+    liveGamesKey      = "games:live"
+    gameLockTTL       = 30 * time.Second
+    ownershipInterval = 10 * time.Second
+)
+
+func liveGameKey(gameID string) string { return "game:" + gameID }
+func gameLockKey(gameID string) string { return "game:" + gameID + ":owner" }
+
+// Take the lock if it is free or already ours
+var claimGameScript = redis.NewScript(`
+local owner = redis.call("GET", KEYS[1])
+if owner == false or owner == ARGV[1] then
+    redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+    return 1
+end
+return 0`)
+
+// Delete the lock only if we still hold it
+var releaseGameScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Write the snapshot only while we still hold the lock, so an instance that lost the game
+// can't overwrite what the new owner wrote
+var persistGameScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+redis.call("SET", KEYS[2], ARGV[2])
+redis.call("SADD", KEYS[3], ARGV[3])
+return 1`)
+
+// Everything needed to rebuild a live Game
+type gameSnapshot struct {
+    Game          *Game             `json:"game"`
+    History       []plyUndo         `json:"history,omitempty"`
+    Claimant      int               `json:"claimant,omitempty"`
+    Expired       map[int]bool      `json:"expired,omitempty"`
+    LastDrawOffer map[int]time.Time `json:"lastDrawOffer,omitempty"`
+    LastTakebackRequest map[int]time.Time `json:"lastTakebackRequest,omitempty"`
+    LastMoveIDs   map[int]string    `json:"lastMoveIds,omitempty"`
+    Premoves      map[int][]Premove `json:"premoves,omitempty"`
+    PendingFinish *finishRecord     `json:"pendingFinish,omitempty"` // Finished but not journaled yet
+}
+
+func newInstanceID() string {
+    host, _ := os.Hostname()
+    return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+func (gm *GameManager) claimGame(gameID string) (bool, error) {
+    claimed, err := claimGameScript.Run(gm.ctx, gm.redis,
+        []string{gameLockKey(gameID)}, gm.instanceID, gameLockTTL.Milliseconds()).Int()
+    if err != nil {
+        return false, err
+    }
+    return claimed == 1, nil
+}
+
+// Whether another instance currently runs the game (so we should stay quiet about it)
+func (gm *GameManager) ownedElsewhere(gameID string) bool {
+    owner, err := gm.redis.Get(gm.ctx, gameLockKey(gameID)).Result()
+    return err == nil && owner != gm.instanceID
+}
+
+// Write the game to Redis. Returns false if another instance owns the game now, in which
+// case it has been dropped here. Caller must hold g.mutex.
+func (gm *GameManager) persistGame(g *Game) bool {
+    // A finished game is only kept while its result still has to reach the journal
+    if g.finished && g.pendingFinish == nil {
+        return true
+    }
+
+    data, err := json.Marshal(gameSnapshot{
+        Game:          g,
+        History:       g.history,
+        Claimant:      g.claimant,
+        Expired:       g.expired,
+        LastDrawOffer: g.lastDrawOffer,
+        LastTakebackRequest: g.lastTakebackRequest,
+        LastMoveIDs:   g.lastMoveIDs,
+        Premoves:      g.premoves,
+        PendingFinish: g.pendingFinish,
+    })
+    if err != nil {
+        log.Printf("Failed to encode game %s: %v", g.ID, err)
+        return true
+    }
+
+    written, err := persistGameScript.Run(gm.ctx, gm.redis,
+        []string{gameLockKey(g.ID), liveGameKey(g.ID), liveGamesKey}, gm.instanceID, data, g.ID).Int()
+    if err != nil {
+        // Can't tell who owns it: keep going, refreshOwnership sorts it out
+        log.Printf("Failed to persist game %s: %v", g.ID, err)
+        return true
+    }
+    if written == 0 {
+        log.Printf("Lost ownership of game %s", g.ID)
+        gm.dropGame(g)
+        return false
+    }
+    return true
+}
+
+// Stop driving a game another instance took over; the new owner carries on from its
+// snapshot. Caller must hold g.mutex.
+func (gm *GameManager) dropGame(g *Game) {
+    g.stopClock()
+    g.stopAbandonTimers()
+
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+    if gm.games[g.ID] == g {
+        delete(gm.games, g.ID)
+        delete(gm.recovering, g.ID)
+    }
+}
+
+// Remove a finished game from Redis and give up its lock
+func (gm *GameManager) forgetGame(gameID string) {
+    pipe := gm.redis.TxPipeline()
+    pipe.Del(gm.ctx, liveGameKey(gameID))
+    pipe.SRem(gm.ctx, liveGamesKey, gameID)
+    if _, err := pipe.Exec(gm.ctx); err != nil {
+        log.Printf("Failed to remove game %s from Redis: %v", gameID, err)
+    }
+    gm.deleteInStream(gameID)
+    releaseGameScript.Run(gm.ctx, gm.redis, []string{gameLockKey(gameID)}, gm.instanceID)
+}
+
+func (gm *GameManager) loadGame(gameID string) (*Game, error) {
+    data, err := gm.redis.Get(gm.ctx, liveGameKey(gameID)).Bytes()
+    if err != nil {
+        return nil, err
+    }
+
+    var snapshot gameSnapshot
+    if err := json.Unmarshal(data, &snapshot); err != nil {
+        return nil, err
+    }
+    if snapshot.Game == nil || snapshot.Game.GameState == nil {
+        return nil, fmt.Errorf("game %s: empty snapshot", gameID)
+    }
+
+    g := snapshot.Game
+    g.history = snapshot.History
+    g.claimant = snapshot.Claimant
+    g.expired = snapshot.Expired
+    g.lastDrawOffer = snapshot.LastDrawOffer
+    g.lastTakebackRequest = snapshot.LastTakebackRequest
+    g.lastMoveIDs = snapshot.LastMoveIDs
+    g.premoves = snapshot.Premoves
+    g.pendingFinish = snapshot.PendingFinish
+    g.finished = g.pendingFinish != nil
+    return g, nil
+}
+
+// Take over every live game without a running owner (all of them on a cold start)
+func (gm *GameManager) RestoreGames() {
+    gameIDs, err := gm.redis.SMembers(gm.ctx, liveGamesKey).Result()
+    if err != nil {
+        log.Printf("Failed to list live games: %v", err)
+        return
+    }
+
+    for _, gameID := range gameIDs {
+        gm.mutex.RLock()
+        _, owned := gm.games[gameID]
+        gm.mutex.RUnlock()
+        if owned {
+            continue
+        }
+        if claimed, err := gm.claimGame(gameID); err != nil || !claimed {
+            continue
+        }
+
+        g, err := gm.loadGame(gameID)
+        if err == redis.Nil {
+            // Finished while we were looking
+            gm.redis.SRem(gm.ctx, liveGamesKey, gameID)
+            releaseGameScript.Run(gm.ctx, gm.redis, []string{gameLockKey(gameID)}, gm.instanceID)
+            continue
+        }
+        if err != nil {
+            log.Printf("Failed to restore game %s: %v", gameID, err)
+            releaseGameScript.Run(gm.ctx, gm.redis, []string{gameLockKey(gameID)}, gm.instanceID)
+            continue
+        }
+
+        if err := gm.ensureInStream(gameID); err != nil {
+            log.Printf("Failed to open stream of game %s: %v", gameID, err)
+            releaseGameScript.Run(gm.ctx, gm.redis, []string{gameLockKey(gameID)}, gm.instanceID)
+            continue
+        }
+
+        gm.addRestoredGame(g)
+        log.Printf("Restored game %s", gameID)
+    }
+}
+
+func (gm *GameManager) addRestoredGame(g *Game) {
+    // Held-back spectator updates died with the previous owner: spectators resume from here
+    g.mutex.Lock()
+    g.initSpectatorFeed()
+    g.mutex.Unlock()
+
+    gm.mutex.Lock()
+    gm.games[g.ID] = g
+    gm.recovering[g.ID] = true
+    gm.interruptStreamRead()
+    window := gm.reconnectWindow
+    gm.mutex.Unlock()
+
+    // The previous owner finished the game but couldn't journal it: finish the job
+    g.mutex.Lock()
+    if g.pendingFinish != nil {
+        g.completeFinish(gm)
+        g.mutex.Unlock()
+        return
+    }
+    g.mutex.Unlock()
+
+    gm.StartClock(g)
+
+    // Reconnection and claim windows were lost with the previous owner: start them over
+    g.mutex.Lock()
+    for _, player := range g.Players {
+        if !player.IsOnline && !g.expired[player.ID] {
+            g.startAbandonTimer(player.ID, window, gm)
+        }
+    }
+    if g.claimant != 0 {
+        g.startClaimTimer(gm)
+    }
+    g.mutex.Unlock()
+}
+
+// Stop driving every game we own and give up its lock, so another instance takes it over
+// right away instead of after the lock expires. For shutdown, once the manager's context
+// is cancelled and the stream loop has returned; each game's last snapshot is already written.
+func (gm *GameManager) ReleaseGames(ctx context.Context) {
+    gm.mutex.RLock()
+    owned := make([]*Game, 0, len(gm.games))
+    for _, g := range gm.games {
+        owned = append(owned, g)
+    }
+    gm.mutex.RUnlock()
+
+    for _, g := range owned {
+        g.mutex.Lock()
+        gm.dropGame(g)
+        g.mutex.Unlock()
+        releaseGameScript.Run(ctx, gm.redis, []string{gameLockKey(g.ID)}, gm.instanceID)
+    }
+    log.Printf("Released %d games", len(owned))
+}
+
+// Keep our locks alive, drop games another instance took over and pick up orphaned ones
+func (gm *GameManager) RunOwnership() {
+    ticker := time.NewTicker(ownershipInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-gm.ctx.Done():
+            return
+        case <-ticker.C:
+            gm.refreshOwnership()
+            gm.RestoreGames()
+        }
+    }
+}
+
+func (gm *GameManager) refreshOwnership() {
+    gm.mutex.RLock()
+    owned := make([]*Game, 0, len(gm.games))
+    for _, g := range gm.games {
+        owned = append(owned, g)
+    }
+    gm.mutex.RUnlock()
+
+    for _, g := range owned {
+        claimed, err := gm.claimGame(g.ID)
+        if err != nil {
+            // Can't tell who owns it: keep going and try again next round
+            log.Printf("Failed to refresh lock of game %s: %v", g.ID, err)
+            continue
+        }
+        if claimed {
+            continue
+        }
+
+        // Lost the lock (e.g. Redis unreachable for longer than the TTL): stop driving
+        // the game here, the new owner restores it from its snapshot
+        log.Printf("Lost ownership of game %s", g.ID)
+        g.mutex.Lock()
+        gm.dropGame(g)
+        g.mutex.Unlock()
+    }
+}
@@ -0,0 +1,195 @@
+package game
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "strings"
+    "sync"
+    "time"
+    "github.com/go-redis/redis/v8"
+    "github.com/locne/protocol"
+)
+
+// Moves and updates travel over per-game Redis streams instead of pub/sub, so nothing is
+// lost while a reader reconnects or the game changes owner:
+//   protocol.InStreamKey   requests from ws-service, read by the owner through a consumer group
+//   protocol.OutStreamKey  state updates, each tagged with a per-game sequence number
+const (
+    streamGroup      = "game-service"
+    streamConsumer   = "owner" // Same name on every instance so a new owner inherits pending entries
+    streamBlock      = time.Second
+    streamReadCount  = 100
+    outStreamMaxLen  = 2000
+    outStreamTTL     = 24 * time.Hour
+)
+
+// Number the update and append it to the game's outbound stream
+var publishUpdateScript = redis.NewScript(`
+local seq = redis.call("INCR", KEYS[2])
+redis.call("XADD", KEYS[1], "MAXLEN", "~", ARGV[2], "*", "seq", seq, "data", ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+return seq`)
+
+// Create the inbound stream and its consumer group if they don't exist yet
+func (gm *GameManager) ensureInStream(gameID string) error {
+    err := gm.redis.XGroupCreateMkStream(gm.ctx, protocol.InStreamKey(gameID), streamGroup, "0").Err()
+    if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+        return err
+    }
+    return nil
+}
+
+// Read requests for every game we own until the manager's context is cancelled. Taking on
+// a game interrupts the blocking read, so its first requests don't wait for the timeout.
+func (gm *GameManager) ListenStreams() {
+    for gm.ctx.Err() == nil {
+        readCtx, cancel := context.WithCancel(gm.ctx)
+        recovering, live := gm.inStreams(cancel)
+
+        // Entries a previous owner read but never acknowledged come first
+        if len(recovering) > 0 {
+            gm.readInStreams(readCtx, recovering, "0", -1)
+        }
+
+        if len(live) == 0 {
+            select {
+            case <-readCtx.Done():
+            case <-time.After(streamBlock):
+            }
+        } else {
+            gm.readInStreams(readCtx, live, ">", streamBlock)
+        }
+        cancel()
+    }
+}
+
+// Games to read, and cancel registered for interruptStreamRead in the same critical
+// section so no game added afterwards goes unnoticed
+func (gm *GameManager) inStreams(cancel context.CancelFunc) (recovering, live []string) {
+    gm.mutex.Lock()
+    defer gm.mutex.Unlock()
+
+    gm.cancelRead = cancel
+    for gameID := range gm.games {
+        if gm.recovering[gameID] {
+            recovering = append(recovering, gameID)
+        } else {
+            live = append(live, gameID)
+        }
+    }
+    return recovering, live
+}
+
+// Cut the current blocking read short so it picks up a newly owned game. Caller must hold gm.mutex.
+func (gm *GameManager) interruptStreamRead() {
+    if gm.cancelRead != nil {
+        gm.cancelRead()
+    }
+}
+
+func (gm *GameManager) readInStreams(ctx context.Context, gameIDs []string, id string, block time.Duration) {
+    streams := make([]string, 0, 2*len(gameIDs))
+    streamGames := make(map[string]string, len(gameIDs))
+    for _, gameID := range gameIDs {
+        streams = append(streams, protocol.InStreamKey(gameID))
+        streamGames[protocol.InStreamKey(gameID)] = gameID
+    }
+    for range gameIDs {
+        streams = append(streams, id)
+    }
+
+    results, err := gm.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+        Group:    streamGroup,
+        Consumer: streamConsumer,
+        Streams:  streams,
+        Count:    streamReadCount,
+        Block:    block,
+    }).Result()
+    if err == redis.Nil {
+        return
+    }
+    if err != nil {
+        if ctx.Err() != nil {
+            // Shutting down, or interrupted to pick up a new game
+            return
+        }
+        log.Printf("Failed to read game streams: %v", err)
+        if strings.HasPrefix(err.Error(), "NOGROUP") {
+            for _, gameID := range gameIDs {
+                gm.ensureInStream(gameID)
+            }
+        }
+        time.Sleep(streamBlock)
+        return
+    }
+
+    // Games in the batch run side by side, each in stream order, so one game's backlog
+    // doesn't queue behind another's. The next read waits for the whole batch: entries
+    // stay pending until acked, and a recovery read from "0" would hand them out again.
+    var wg sync.WaitGroup
+    for _, result := range results {
+        gameID := streamGames[result.Stream]
+        if id == "0" && len(result.Messages) == 0 {
+            gm.mutex.Lock()
+            delete(gm.recovering, gameID)
+            gm.mutex.Unlock()
+            continue
+        }
+
+        wg.Add(1)
+        go func(result redis.XStream) {
+            defer wg.Done()
+            for _, entry := range result.Messages {
+                gm.dispatchEntry(entry)
+                gm.redis.XAck(gm.ctx, result.Stream, streamGroup, entry.ID)
+            }
+        }(result)
+    }
+    wg.Wait()
+}
+
+func (gm *GameManager) dispatchEntry(entry redis.XMessage) {
+    kind, _ := entry.Values[protocol.FieldKind].(string)
+    data, _ := entry.Values[protocol.FieldData].(string)
+
+    switch kind {
+    case protocol.KindMove:
+        var moveMsg MoveMessage
+        if err := decodeRequest(data, &moveMsg); err != nil {
+            log.Printf("Dropping stream entry %s: %v", entry.ID, err)
+            return
+        }
+        gm.ProcessMove(moveMsg)
+    case protocol.KindGameAction:
+        var actionMsg GameActionMessage
+        if err := decodeRequest(data, &actionMsg); err != nil {
+            log.Printf("Dropping stream entry %s: %v", entry.ID, err)
+            return
+        }
+        gm.ProcessGameAction(actionMsg)
+    case protocol.KindPresence:
+        var presenceMsg PresenceMessage
+        if err := decodeRequest(data, &presenceMsg); err != nil {
+            log.Printf("Dropping stream entry %s: %v", entry.ID, err)
+            return
+        }
+        gm.ProcessPresence(presenceMsg)
+    default:
+        log.Printf("Unknown stream entry %s: %q", entry.ID, kind)
+    }
+}
+
+func decodeRequest(data string, msg interface{ Validate() error }) error {
+    if err := json.Unmarshal([]byte(data), msg); err != nil {
+        return err
+    }
+    return msg.Validate()
+}
+
+// Drop the inbound stream of a finished game; the outbound one expires on its own so
+// late reconnects can still replay the end of the game
+func (gm *GameManager) deleteInStream(gameID string) {
+    gm.redis.Del(gm.ctx, protocol.InStreamKey(gameID))
+}
@@ -0,0 +1,188 @@
+package game
+
+import (
+    "fmt"
+    "time"
+    "github.com/locne/game-service/internal/usecase/engine"
+)
+
+// A player may ask again TakebackRequestCooldown after their last request
+const TakebackRequestCooldown = 30 * time.Second
+
+type TakebackOffer struct {
+    ID        string    `json:"id"`
+    FromID    int       `json:"fromId"`
+    ToID      int       `json:"toId"`
+    Plies     int       `json:"plies"` // Half-moves to undo
+    CreatedAt time.Time `json:"createdAt"`
+}
+
+// What a takeback needs to undo one ply: the engine's undo record and the clocks before it.
+// A few hundred bytes, so the stack stays small however long the game gets.
+type plyUndo struct {
+    Move          engine.ServerMoveUndo `json:"move"`
+    WhiteTimeLeft int                   `json:"whiteTimeLeft"`
+    BlackTimeLeft int                   `json:"blackTimeLeft"`
+    WhiteMoves    int                   `json:"whiteMoves"`
+    BlackMoves    int                   `json:"blackMoves"`
+}
+
+// Remember how to undo a move, given the clocks before it. Caller must hold g.mutex.
+func (g *Game) pushUndo(move engine.ServerMoveUndo, clocks plyUndo) {
+    if !g.TakebacksAllowed {
+        return
+    }
+    clocks.Move = move
+    g.history = append(g.history, clocks)
+}
+
+// Clocks before the ply about to be played. Caller must hold g.mutex.
+func (g *Game) clocksBefore() plyUndo {
+    return plyUndo{
+        WhiteTimeLeft: g.WhiteTimeLeft,
+        BlackTimeLeft: g.BlackTimeLeft,
+        WhiteMoves:    g.WhiteMoves,
+        BlackMoves:    g.BlackMoves,
+    }
+}
+
+// Undo the last plies half-moves. Caller must hold g.mutex.
+func (g *Game) undoPlies(plies int, gm *GameManager) {
+    chessEngine := &engine.ChessEngine{}
+    var undo plyUndo
+    for i := 0; i < plies; i++ {
+        undo = g.history[len(g.history)-1]
+        g.history = g.history[:len(g.history)-1]
+        chessEngine.UndoServerMove(g.GameState, undo.Move)
+        g.removeLastNotation(undo.Move.ActiveColor)
+    }
+
+    // Clocks as they were before the earliest ply taken back
+    g.WhiteTimeLeft = undo.WhiteTimeLeft
+    g.BlackTimeLeft = undo.BlackTimeLeft
+    g.WhiteMoves = undo.WhiteMoves
+    g.BlackMoves = undo.BlackMoves
+    g.MoveRecords = g.MoveRecords[:max(len(g.MoveRecords)-plies, 0)]
+    g.LastMoveTime = time.Now()
+    g.UpdatedAt = time.Now()
+
+    // Pending offers and premoves referred to the position we just left
+    g.TakebackOffers = make(map[string]*TakebackOffer)
+    g.premoves = nil
+    g.armFlagTimer(gm)
+}
+
+func (gm *GameManager) handleTakebackRequest(game *Game, playerID int) {
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    player, isPlayer := game.Players[playerID]
+    if !isPlayer || game.finished || !game.TakebacksAllowed {
+        return
+    }
+
+    // Undo our last move, and the opponent's reply if it is our turn again
+    plies := 1
+    if player.Color == game.GameState.ActiveColor {
+        plies = 2
+    }
+    if len(game.history) < plies {
+        return
+    }
+
+    opponent := game.opponentOf(playerID)
+    if opponent == nil {
+        return
+    }
+
+    // One pending request per player, and a cooldown between requests
+    now := time.Now()
+    for _, offer := range game.TakebackOffers {
+        if offer.FromID == playerID {
+            gm.publishErrorTo(game.ID, playerID, "takeback request already pending")
+            return
+        }
+    }
+    if last, ok := game.lastTakebackRequest[playerID]; ok && now.Sub(last) < TakebackRequestCooldown {
+        gm.publishErrorTo(game.ID, playerID, "too many takeback requests, try again later")
+        return
+    }
+
+    offerID := fmt.Sprintf("%s_tb_%d_%d", game.ID, playerID, now.Unix())
+    if game.TakebackOffers == nil {
+        game.TakebackOffers = make(map[string]*TakebackOffer)
+    }
+    if game.lastTakebackRequest == nil {
+        game.lastTakebackRequest = make(map[int]time.Time)
+    }
+    game.TakebackOffers[offerID] = &TakebackOffer{
+        ID:        offerID,
+        FromID:    playerID,
+        ToID:      opponent.ID,
+        Plies:     plies,
+        CreatedAt: now,
+    }
+    game.lastTakebackRequest[playerID] = now
+    if !gm.persistGame(game) {
+        return
+    }
+
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:           "takebackRequest",
+        RoomID:         game.ID,
+        OfferID:        offerID,
+        OfferFrom:      playerID,
+        TargetPlayerID: &opponent.ID,
+    })
+}
+
+func (gm *GameManager) handleTakebackAccept(game *Game, playerID int, offerID string) {
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    offer, exists := game.TakebackOffers[offerID]
+    if !exists || offer.ToID != playerID || game.finished {
+        return
+    }
+    if len(game.history) < offer.Plies {
+        delete(game.TakebackOffers, offerID)
+        return
+    }
+
+    game.undoPlies(offer.Plies, gm)
+    if !gm.persistGame(game) {
+        return
+    }
+
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:          "takebackAccepted",
+        RoomID:        game.ID,
+        OfferID:       offerID,
+        GameState:     game.clientGameState(),
+        WhiteTimeLeft: game.WhiteTimeLeft,
+        BlackTimeLeft: game.BlackTimeLeft,
+        ServerTime:    game.LastMoveTime.UnixMilli(),
+        MoveHistory:   wireHistory(game.GameState.MoveHistory),
+    })
+}
+
+func (gm *GameManager) handleTakebackDecline(game *Game, playerID int, offerID string) {
+    game.mutex.Lock()
+    defer game.mutex.Unlock()
+
+    offer, exists := game.TakebackOffers[offerID]
+    if !exists || offer.ToID != playerID {
+        return
+    }
+    delete(game.TakebackOffers, offerID)
+    if !gm.persistGame(game) {
+        return
+    }
+
+    gm.PublishStateUpdate(StateUpdateMessage{
+        Type:           "takebackDeclined",
+        RoomID:         game.ID,
+        OfferID:        offerID,
+        TargetPlayerID: &offer.FromID,
+    })
+}
@@ -0,0 +1,108 @@
+package game
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "github.com/locne/protocol"
+)
+
+// Clock modes
+const (
+    ClockFischer   = "fischer"   // Increment added after each move
+    ClockDelay     = "delay"     // Simple (US) delay: the clock only starts after Delay seconds
+    ClockBronstein = "bronstein" // Time used on a move is given back, up to Delay seconds
+)
+
+// A later period of a multi-stage control
+type TimeStage = protocol.TimeStage
+
+// All periods, the first one built from the top-level fields
+func (tc *TimeControl) periods() []TimeStage {
+    first := TimeStage{
+        Moves:     tc.Moves,
+        Time:      tc.InitialTime,
+        Increment: tc.Increment,
+        Mode:      tc.Mode,
+        Delay:     tc.Delay,
+    }
+    return append([]TimeStage{first}, tc.Stages...)
+}
+
+// Period in force for a player who has already played movesPlayed moves
+func (tc *TimeControl) stageFor(movesPlayed int) TimeStage {
+    periods := tc.periods()
+    boundary := 0
+    for i, period := range periods {
+        if period.Moves == 0 || i == len(periods)-1 || movesPlayed < boundary+period.Moves {
+            return period
+        }
+        boundary += period.Moves
+    }
+    return periods[len(periods)-1]
+}
+
+// Seconds added when a player's movesPlayed-th move completes a period
+func (tc *TimeControl) stageBonus(movesPlayed int) int {
+    periods := tc.periods()
+    boundary := 0
+    for i, period := range periods[:len(periods)-1] {
+        if period.Moves == 0 {
+            return 0
+        }
+        boundary += period.Moves
+        if movesPlayed == boundary {
+            return periods[i+1].Time
+        }
+    }
+    return 0
+}
+
+func (tc *TimeControl) Validate() error {
+    if tc.InitialTime <= 0 {
+        return fmt.Errorf("invalid time control: initial time must be positive")
+    }
+    periods := tc.periods()
+    for i, period := range periods {
+        switch period.Mode {
+        case "", ClockFischer, ClockDelay, ClockBronstein:
+        default:
+            return fmt.Errorf("invalid time control: unknown mode %q", period.Mode)
+        }
+        if period.Time < 0 || period.Increment < 0 || period.Delay < 0 || period.Moves < 0 {
+            return fmt.Errorf("invalid time control: negative value in period %d", i+1)
+        }
+        if period.Moves == 0 && i < len(periods)-1 {
+            return fmt.Errorf("invalid time control: period %d needs a move count", i+1)
+        }
+    }
+    return nil
+}
+
+// Compact notation stored with finished games: periods joined by ":", each
+// "[moves/]minutes" followed by "d<delay>" (simple delay) or "b<delay>" (Bronstein)
+// and "+<increment>", e.g. "10+5", "90d30", "40/90+30:30+30"
+func (tc *TimeControl) String() string {
+    if tc == nil {
+        return ""
+    }
+
+    parts := make([]string, 0, len(tc.Stages)+1)
+    for _, period := range tc.periods() {
+        part := strconv.FormatFloat(float64(period.Time)/60, 'f', -1, 64)
+        if period.Moves > 0 {
+            part = strconv.Itoa(period.Moves) + "/" + part
+        }
+        switch period.Mode {
+        case ClockDelay:
+            part += "d" + strconv.Itoa(period.Delay)
+        case ClockBronstein:
+            part += "b" + strconv.Itoa(period.Delay)
+        }
+        if period.Increment > 0 || period.Mode == "" || period.Mode == ClockFischer {
+            part += "+" + strconv.Itoa(period.Increment)
+        }
+        parts = append(parts, part)
+    }
+    return strings.Join(parts, ":")
+}
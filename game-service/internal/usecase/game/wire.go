@@ -0,0 +1,64 @@
+package game
+
+import (
+    "github.com/locne/game-service/internal/usecase/engine"
+    "github.com/locne/protocol"
+)
+
+// The engine keeps its own types; these copy them into the shared wire types
+
+func (g *Game) clientGameState() protocol.ClientGameState {
+    state := protocol.ClientGameState{
+        Variant:        g.GameState.Variant,
+        CurrentFen:     g.GameState.CurrentFen,
+        Bitboards:      protocol.BitboardGame(g.GameState.Bitboards),
+        ActiveColor:    g.GameState.ActiveColor,
+        CastlingRights: protocol.CastlingRights(g.GameState.CastlingRights),
+        VariantState:   protocol.VariantState(g.GameState.VariantState),
+    }
+    if square := g.GameState.EnPassantSquare; square != nil {
+        state.EnPassantSquare = &protocol.Position{Row: square.Row, Col: square.Col}
+    }
+    return state
+}
+
+func wireMoves(moves []engine.Move) []protocol.Move {
+    wire := make([]protocol.Move, len(moves))
+    for i, move := range moves {
+        wire[i] = protocol.Move{
+            From:      protocol.Position(move.From),
+            To:        protocol.Position(move.To),
+            Piece:     protocol.Piece(move.Piece),
+            Promotion: move.Promotion,
+            Flags:     move.Flags,
+        }
+        if move.Captured != nil {
+            captured := protocol.Piece(*move.Captured)
+            wire[i].Captured = &captured
+        }
+    }
+    return wire
+}
+
+func wireHistory(history []engine.MoveNotation) []protocol.MoveNotation {
+    wire := make([]protocol.MoveNotation, len(history))
+    for i, row := range history {
+        wire[i] = protocol.MoveNotation(row)
+    }
+    return wire
+}
+
+func (tc *TimeControl) wire() *protocol.TimeControl {
+    if tc == nil {
+        return nil
+    }
+    return &protocol.TimeControl{
+        Type:        tc.Type,
+        InitialTime: tc.InitialTime,
+        Increment:   tc.Increment,
+        Mode:        tc.Mode,
+        Delay:       tc.Delay,
+        Moves:       tc.Moves,
+        Stages:      tc.Stages,
+    }
+}
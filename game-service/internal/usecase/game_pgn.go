@@ -0,0 +1,259 @@
+package usecase
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "math/rand"
+    "strconv"
+    "strings"
+    "time"
+    
+    "github.com/locne/game-service/internal/entity"
+    "github.com/locne/game-service/internal/interface/repository"
+    "github.com/locne/game-service/internal/usecase/engine"
+    "github.com/locne/game-service/internal/usecase/pgn"
+)
+
+// Most games a single PGN upload may hold
+const MaxImportGames = 100
+
+// Side the uploader played in an imported game, so it shows up in their history
+type ImportOwner struct {
+    UserID int
+    Color  string // "white" or "black"
+}
+
+// Site tag written into exported games
+func (uc *GameUseCase) SetSite(site string) {
+    uc.site = site
+}
+
+func (uc *GameUseCase) GetGamePGN(ctx context.Context, gameID string) (string, error) {
+    game, err := uc.GetGameByID(ctx, gameID)
+    if err != nil {
+        return "", err
+    }
+
+    var b strings.Builder
+    if err := pgn.Write(&b, *game, uc.site); err != nil {
+        return "", err
+    }
+    return b.String(), nil
+}
+
+// Write every game matching filter as PGN, newest first, one page at a time
+func (uc *GameUseCase) ExportGamesPGN(ctx context.Context, w io.Writer, filter repository.GameFilter) error {
+    filter.Limit = MaxGamePageSize
+    if err := ValidateGameFilter(&filter); err != nil {
+        return err
+    }
+
+    for {
+        games, err := uc.gameRepo.ListGames(ctx, filter)
+        if err != nil {
+            return err
+        }
+        for _, game := range games {
+            if err := pgn.Write(w, game, uc.site); err != nil {
+                return err
+            }
+        }
+        if flusher, ok := w.(interface{ Flush() }); ok {
+            flusher.Flush()
+        }
+
+        if len(games) < filter.Limit {
+            return nil
+        }
+        last := games[len(games)-1]
+        filter.After = &repository.GameCursor{CreatedAt: last.CreatedAt, GameID: last.GameID}
+    }
+}
+
+// Check every game in text against the rules and store them as unrated imported games.
+// Nothing is stored unless all of them are valid.
+func (uc *GameUseCase) ImportPGN(ctx context.Context, text string, owner ImportOwner) ([]string, error) {
+    switch owner.Color {
+    case "", "white", "black":
+    default:
+        return nil, fmt.Errorf("%w: unknown color %q", ErrInvalidQuery, owner.Color)
+    }
+    if (owner.UserID == 0) != (owner.Color == "") {
+        return nil, fmt.Errorf("%w: userId and color go together", ErrInvalidQuery)
+    }
+
+    parsed, err := pgn.Parse(text)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidPGN, err)
+    }
+    if len(parsed) > MaxImportGames {
+        return nil, fmt.Errorf("%w: at most %d games per upload", ErrInvalidPGN, MaxImportGames)
+    }
+
+    games := make([]entity.Game, 0, len(parsed))
+    gameIDs := make([]string, 0, len(parsed))
+    for i, game := range parsed {
+        imported, err := replayImportedGame(game)
+        if err != nil {
+            return nil, fmt.Errorf("%w: game %d: %v", ErrInvalidPGN, i+1, err)
+        }
+        switch owner.Color {
+        case "white":
+            imported.Players.White.UserID = owner.UserID
+        case "black":
+            imported.Players.Black.UserID = owner.UserID
+        }
+        imported.WinnerID = importedWinnerID(imported)
+        games = append(games, imported)
+        gameIDs = append(gameIDs, imported.GameID)
+    }
+
+    if err := uc.gameRepo.SaveGamesBatch(ctx, games); err != nil {
+        return nil, err
+    }
+    return gameIDs, nil
+}
+
+// Play the moves through the engine from the game's start position
+func replayImportedGame(game pgn.Game) (entity.Game, error) {
+    variant := pgn.VariantFromTag(game.Tags["Variant"], game.Tags["FEN"] != "")
+    if variant == "" {
+        return entity.Game{}, fmt.Errorf("unsupported variant %q", game.Tags["Variant"])
+    }
+
+    chessEngine := &engine.ChessEngine{}
+    var state *engine.ServerGameState
+    var err error
+    switch fen := game.Tags["FEN"]; {
+    case fen != "":
+        state, err = chessEngine.CreateServerGameStateFromFEN(fen, variant)
+    case variant == engine.VariantChess960:
+        err = fmt.Errorf("a Chess960 game needs a FEN tag")
+    default:
+        state, err = chessEngine.CreateVariantGameState(variant, 0)
+    }
+    if err != nil {
+        return entity.Game{}, err
+    }
+    initialFen := state.InitialFen
+
+    moves := make([]string, 0, len(game.Moves))
+    records := make([]entity.MoveRecord, 0, len(game.Moves))
+    for i, san := range game.Moves {
+        move := moveLabel(state, san)
+        if over, reason := chessEngine.IsGameOver(state.Bitboards, state.GameState(), state.PositionCounts, state.VariantState); over {
+            return entity.Game{}, fmt.Errorf("%s comes after the game ended by %s", move, reason)
+        }
+
+        from, to, promotion, err := chessEngine.ParseSAN(state.Bitboards, state.GameState(), san)
+        if err != nil {
+            return entity.Game{}, fmt.Errorf("%s: %v", move, err)
+        }
+        notation := chessEngine.BuildNotation(state.Bitboards, state.GameState(), from, to, promotion)
+        uci := chessEngine.NewMove(state.Bitboards, state.GameState(), from, to, promotion).UCI()
+        if !chessEngine.ExecuteServerMove(state, from, to, promotion) {
+            return entity.Game{}, fmt.Errorf("%s is illegal", move)
+        }
+        moves = append(moves, notation)
+
+        record := entity.MoveRecord{
+            Ply:      i + 1,
+            SAN:      notation,
+            UCI:      uci,
+            FENAfter: state.CurrentFen,
+        }
+        if game.Clocks != nil {
+            clock := game.Clocks[i]
+            record.Clock = &clock
+        }
+        records = append(records, record)
+    }
+
+    reason := ""
+    if over, endReason := chessEngine.IsGameOver(state.Bitboards, state.GameState(), state.PositionCounts, state.VariantState); over {
+        expected := "1/2-1/2"
+        if !entity.IsDrawReason(endReason) {
+            expected = "1-0"
+            if state.ActiveColor == "white" {
+                expected = "0-1"
+            }
+        }
+        if game.Result != expected {
+            return entity.Game{}, fmt.Errorf("result %s does not match the final position (%s)", game.Result, endReason)
+        }
+        reason = endReason
+    } else if strings.EqualFold(game.Tags["Termination"], "time forfeit") {
+        reason = entity.ReasonTimeout
+    }
+
+    imported := entity.Game{
+        GameID:      generateImportID(),
+        Moves:       moves,
+        Result:      game.Result,
+        CreatedAt:   importDate(game.Tags),
+        TimeControl: pgn.TimeControlFromTag(game.Tags["TimeControl"]),
+        Reason:      reason,
+        LastFen:     state.CurrentFen,
+        Variant:     variant,
+        InitialFen:  initialFen,
+        Rated:       false,
+        MoveRecords: records,
+        Imported:    true,
+    }
+    imported.Players.White = importedPlayer(game.Tags["White"], game.Tags["WhiteElo"])
+    imported.Players.Black = importedPlayer(game.Tags["Black"], game.Tags["BlackElo"])
+    return imported, nil
+}
+
+// WinnerID as finishGame stores it: the winning side's user ID, "none" without a winner
+// or when the winner isn't the uploader
+func importedWinnerID(game entity.Game) string {
+    winner := 0
+    switch game.Result {
+    case "1-0":
+        winner = game.Players.White.UserID
+    case "0-1":
+        winner = game.Players.Black.UserID
+    }
+    if winner == 0 {
+        return "none"
+    }
+    return strconv.Itoa(winner)
+}
+
+// "12. Nf3" or "12... Nf6", for errors
+func moveLabel(state *engine.ServerGameState, san string) string {
+    if state.ActiveColor == "white" {
+        return fmt.Sprintf("%d. %s", state.FullMoveNumber, san)
+    }
+    return fmt.Sprintf("%d... %s", state.FullMoveNumber, san)
+}
+
+func importedPlayer(name string, elo string) entity.PlayerInfo {
+    player := entity.PlayerInfo{Username: name}
+    if name == "?" {
+        player.Username = ""
+    }
+    player.Elo, _ = strconv.Atoi(elo)
+    return player
+}
+
+// When the game was played, from the Date (and UTCDate/UTCTime) tags; now if unknown
+func importDate(tags map[string]string) time.Time {
+    date := tags["UTCDate"]
+    if date == "" {
+        date = tags["Date"]
+    }
+    if t, err := time.Parse("2006.01.02 15:04:05", date+" "+tags["UTCTime"]); err == nil {
+        return t
+    }
+    if t, err := time.Parse("2006.01.02", date); err == nil {
+        return t
+    }
+    return time.Now().UTC()
+}
+
+func generateImportID() string {
+    return fmt.Sprintf("imp%d%04d", time.Now().UnixNano(), rand.Intn(10000))
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/locne/game-service/internal/entity"
+    "github.com/locne/game-service/internal/usecase/pgn"
+)
+
+// A game written as PGN and imported again keeps its moves, clocks, start position and
+// time control
+func TestPGNRoundTrip(t *testing.T) {
+    clocks := []int{5391200, 5398000, 5344500, 5270000, 5290100}
+    game := entity.Game{
+        GameID:      "g1",
+        Moves:       []string{"Kd7", "Rh7+", "Kd6", "e4", "Ra1+"},
+        Result:      "0-1",
+        CreatedAt:   time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC),
+        TimeControl: "40/90+30:30+30",
+        Reason:      entity.ReasonTimeout,
+        Variant:     "standard",
+        InitialFen:  "r3k3/8/8/8/8/8/4P3/4K2R b Kq - 4 20",
+    }
+    game.Players.White = entity.PlayerInfo{Username: "alice", Elo: 1850}
+    game.Players.Black = entity.PlayerInfo{Username: "bob", Elo: 1910}
+    for i, san := range game.Moves {
+        clock := clocks[i]
+        game.MoveRecords = append(game.MoveRecords, entity.MoveRecord{Ply: i + 1, SAN: san, Clock: &clock})
+    }
+
+    var b strings.Builder
+    if err := pgn.Write(&b, game, "example.org"); err != nil {
+        t.Fatal(err)
+    }
+    text := b.String()
+    for _, want := range []string{`[SetUp "1"]`, `[TimeControl "40/5400+30:1800+30"]`, "20... Kd7", "{[%clk 1:29:51.2]}"} {
+        if !strings.Contains(text, want) {
+            t.Fatalf("exported PGN lacks %s:\n%s", want, text)
+        }
+    }
+
+    parsed, err := pgn.Parse(text)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(parsed) != 1 {
+        t.Fatalf("got %d games back", len(parsed))
+    }
+    imported, err := replayImportedGame(parsed[0])
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if strings.Join(imported.Moves, " ") != strings.Join(game.Moves, " ") {
+        t.Fatalf("moves: got %v, want %v", imported.Moves, game.Moves)
+    }
+    for i, record := range imported.MoveRecords {
+        if record.Clock == nil || *record.Clock != clocks[i] {
+            t.Fatalf("ply %d: clock %v, want %d", i+1, record.Clock, clocks[i])
+        }
+    }
+    if imported.InitialFen != game.InitialFen {
+        t.Fatalf("initial FEN: got %s, want %s", imported.InitialFen, game.InitialFen)
+    }
+    if imported.TimeControl != game.TimeControl {
+        t.Fatalf("time control: got %s, want %s", imported.TimeControl, game.TimeControl)
+    }
+    if imported.Result != game.Result || imported.Reason != game.Reason || !imported.CreatedAt.Equal(game.CreatedAt) {
+        t.Fatalf("got %s by %s on %v", imported.Result, imported.Reason, imported.CreatedAt)
+    }
+    if imported.Players.White.Username != "alice" || imported.Players.Black.Elo != 1910 {
+        t.Fatalf("players: got %+v", imported.Players)
+    }
+}
+
+func TestImportedWinnerID(t *testing.T) {
+    tests := []struct {
+        result string
+        want   string
+    }{
+        {"1-0", "7"},
+        {"0-1", "none"}, // Black isn't a user here
+        {"1/2-1/2", "none"},
+        {"*", "none"},
+    }
+
+    for _, test := range tests {
+        game := entity.Game{Result: test.result}
+        game.Players.White.UserID = 7
+        if got := importedWinnerID(game); got != test.want {
+            t.Fatalf("%s: got %s, want %s", test.result, got, test.want)
+        }
+    }
+}
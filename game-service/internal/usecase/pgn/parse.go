@@ -0,0 +1,233 @@
+package pgn
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// A game read from PGN, not yet checked against the rules
+type Game struct {
+    Tags   map[string]string
+    Moves  []string // SAN as written
+    Clocks []int    // Clock after each move (ms) from %clk comments; nil unless every move has one
+    Result string
+}
+
+var clockComment = regexp.MustCompile(`\[%clk\s+([0-9:.]+)\]`)
+
+var moveNumber = regexp.MustCompile(`^\d+\.+`)
+
+// Read every game in text
+func Parse(text string) ([]Game, error) {
+    p := &parser{input: text}
+
+    var games []Game
+    for {
+        p.skipSpace()
+        if p.eof() {
+            break
+        }
+        game, err := p.parseGame()
+        if err != nil {
+            return nil, fmt.Errorf("game %d: %w", len(games)+1, err)
+        }
+        games = append(games, game)
+    }
+    if len(games) == 0 {
+        return nil, fmt.Errorf("no games found")
+    }
+    return games, nil
+}
+
+type parser struct {
+    input string
+    pos   int
+}
+
+func (p *parser) eof() bool {
+    return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+    return p.input[p.pos]
+}
+
+func (p *parser) skipSpace() {
+    for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+        p.pos++
+    }
+}
+
+func (p *parser) parseGame() (Game, error) {
+    game := Game{Tags: make(map[string]string)}
+
+    for p.skipSpace(); !p.eof() && p.peek() == '['; p.skipSpace() {
+        name, value, err := p.parseTag()
+        if err != nil {
+            return game, err
+        }
+        game.Tags[name] = value
+    }
+
+    var clocks []int
+    for {
+        p.skipSpace()
+        if p.eof() {
+            return game, fmt.Errorf("missing result at end of movetext")
+        }
+
+        switch p.peek() {
+        case '{':
+            comment, err := p.until('}')
+            if err != nil {
+                return game, err
+            }
+            if match := clockComment.FindStringSubmatch(comment); match != nil && len(clocks) > 0 {
+                clock, err := ParseClock(match[1])
+                if err != nil {
+                    return game, err
+                }
+                clocks[len(clocks)-1] = clock
+            }
+        case ';':
+            p.until('\n')
+        case '(':
+            if err := p.skipVariation(); err != nil {
+                return game, err
+            }
+        case '$':
+            p.pos++
+            p.word()
+        case '[':
+            return game, fmt.Errorf("missing result before the next game's tags")
+        case ')':
+            return game, fmt.Errorf("unbalanced ')' near %q", p.context(p.pos))
+        default:
+            token := p.word()
+            switch token {
+            case "1-0", "0-1", "1/2-1/2", "*":
+                game.Result = token
+                game.Clocks = completeClocks(clocks)
+                return game, nil
+            case "--", "Z0":
+                return game, fmt.Errorf("null moves are not supported")
+            }
+
+            san := moveNumber.ReplaceAllString(token, "")
+            if san == "" {
+                continue
+            }
+            game.Moves = append(game.Moves, san)
+            clocks = append(clocks, -1)
+        }
+    }
+}
+
+func (p *parser) parseTag() (string, string, error) {
+    p.pos++ // '['
+    p.skipSpace()
+    start := p.pos
+    for !p.eof() && strings.IndexByte(" \t\"]", p.peek()) < 0 {
+        p.pos++
+    }
+    name := p.input[start:p.pos]
+
+    p.skipSpace()
+    if name == "" || p.eof() || p.peek() != '"' {
+        return "", "", fmt.Errorf("malformed tag near %q", p.context(start))
+    }
+    p.pos++
+
+    var value strings.Builder
+    for {
+        if p.eof() {
+            return "", "", fmt.Errorf("unterminated tag %s", name)
+        }
+        c := p.peek()
+        p.pos++
+        if c == '"' {
+            break
+        }
+        if c == '\\' && !p.eof() {
+            c = p.peek()
+            p.pos++
+        }
+        value.WriteByte(c)
+    }
+
+    p.skipSpace()
+    if p.eof() || p.peek() != ']' {
+        return "", "", fmt.Errorf("unterminated tag %s", name)
+    }
+    p.pos++
+    return name, value.String(), nil
+}
+
+// Consume up to and including end; returns what was in between
+func (p *parser) until(end byte) (string, error) {
+    start := p.pos
+    i := strings.IndexByte(p.input[p.pos+1:], end)
+    if i < 0 {
+        p.pos = len(p.input)
+        if end == '\n' {
+            return p.input[start:], nil
+        }
+        return "", fmt.Errorf("unterminated %q near %q", p.input[start], p.context(start))
+    }
+    p.pos += i + 2
+    return p.input[start+1 : p.pos-1], nil
+}
+
+// Skip a recursive annotation variation, which may nest and contain comments
+func (p *parser) skipVariation() error {
+    start := p.pos
+    depth := 0
+    for !p.eof() {
+        switch p.peek() {
+        case '(':
+            depth++
+        case ')':
+            depth--
+            if depth == 0 {
+                p.pos++
+                return nil
+            }
+        case '{':
+            if _, err := p.until('}'); err != nil {
+                return err
+            }
+            continue
+        }
+        p.pos++
+    }
+    return fmt.Errorf("unterminated variation near %q", p.context(start))
+}
+
+func (p *parser) word() string {
+    start := p.pos
+    for !p.eof() && strings.IndexByte(" \t\r\n{}();[", p.peek()) < 0 {
+        p.pos++
+    }
+    if p.pos == start {
+        p.pos++ // Stray ')' and the like
+    }
+    return p.input[start:p.pos]
+}
+
+func (p *parser) context(start int) string {
+    end := min(start+20, len(p.input))
+    return p.input[start:end]
+}
+
+func completeClocks(clocks []int) []int {
+    if len(clocks) == 0 {
+        return nil
+    }
+    for _, clock := range clocks {
+        if clock < 0 {
+            return nil
+        }
+    }
+    return clocks
+}
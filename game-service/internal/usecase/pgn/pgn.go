@@ -0,0 +1,290 @@
+// Package pgn reads and writes games in Portable Game Notation.
+package pgn
+
+import (
+    "fmt"
+    "io"
+    "regexp"
+    "strconv"
+    "strings"
+    "github.com/locne/game-service/internal/entity"
+)
+
+const (
+    lineWidth = 80
+    startFEN  = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+)
+
+// PGN Variant tag for each engine variant
+var variantTags = map[string]string{
+    "standard":      "Standard",
+    "chess960":      "Chess960",
+    "kingOfTheHill": "King of the Hill",
+    "threeCheck":    "Three-check",
+    "atomic":        "Atomic",
+}
+
+// Engine variant named by a PGN Variant tag ("" if unknown). "From Position" is what
+// some tools write for a standard game from a FEN, so it needs the FEN tag to go with it.
+func VariantFromTag(tag string, hasFEN bool) string {
+    if tag == "" {
+        return "standard"
+    }
+    if strings.EqualFold(tag, "From Position") {
+        if hasFEN {
+            return "standard"
+        }
+        return ""
+    }
+    for variant, name := range variantTags {
+        if strings.EqualFold(name, tag) {
+            return variant
+        }
+    }
+    if strings.EqualFold(tag, "Fischerandom") {
+        return "chess960"
+    }
+    return ""
+}
+
+// Write one game as PGN, followed by a blank line so games can be concatenated
+func Write(w io.Writer, game entity.Game, site string) error {
+    var b strings.Builder
+
+    for _, tag := range tags(game, site) {
+        fmt.Fprintf(&b, "[%s \"%s\"]\n", tag[0], escapeTag(tag[1]))
+    }
+    b.WriteString("\n")
+    writeMovetext(&b, game)
+    b.WriteString("\n\n")
+
+    _, err := io.WriteString(w, b.String())
+    return err
+}
+
+func tags(game entity.Game, site string) [][2]string {
+    if site == "" {
+        site = "?"
+    }
+    date := "????.??.??"
+    if !game.CreatedAt.IsZero() {
+        date = game.CreatedAt.UTC().Format("2006.01.02")
+    }
+    result := game.Result
+    if result == "" {
+        result = "*"
+    }
+
+    list := [][2]string{
+        {"Event", event(game)},
+        {"Site", site},
+        {"Date", date},
+        {"Round", "-"},
+        {"White", playerName(game.Players.White)},
+        {"Black", playerName(game.Players.Black)},
+        {"Result", result},
+        {"WhiteElo", elo(game.Players.White)},
+        {"BlackElo", elo(game.Players.Black)},
+        {"TimeControl", TimeControlTag(game.TimeControl)},
+        {"Termination", termination(game.Reason, result)},
+    }
+    if game.Variant != "" && game.Variant != "standard" {
+        list = append(list, [2]string{"Variant", variantTags[game.Variant]})
+    }
+    if game.InitialFen != "" && game.InitialFen != startFEN {
+        list = append(list, [2]string{"SetUp", "1"}, [2]string{"FEN", game.InitialFen})
+    }
+    return list
+}
+
+func event(game entity.Game) string {
+    kind := "Casual"
+    switch {
+    case game.Imported:
+        return "Imported game"
+    case game.Rated:
+        kind = "Rated"
+    }
+    return strings.Join(strings.Fields(kind+" "+game.GameType+" game"), " ")
+}
+
+func playerName(player entity.PlayerInfo) string {
+    if player.Username == "" {
+        return "?"
+    }
+    return player.Username
+}
+
+func elo(player entity.PlayerInfo) string {
+    if player.Elo <= 0 {
+        return "?"
+    }
+    return strconv.Itoa(player.Elo)
+}
+
+// Termination tag for a stored game end reason
+func termination(reason string, result string) string {
+    switch {
+    case reason == entity.ReasonTimeout:
+        return "time forfeit"
+    case reason == entity.ReasonAbandonment, reason == entity.ReasonAbandonmentDraw, entity.IsAbortReason(reason):
+        return "abandoned"
+    case result == "*":
+        return "unterminated"
+    }
+    return "normal"
+}
+
+func escapeTag(value string) string {
+    return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+func writeMovetext(b *strings.Builder, game entity.Game) {
+    color, moveNumber := "w", 1
+    if fields := strings.Fields(game.InitialFen); len(fields) >= 6 {
+        color = fields[1]
+        if n, err := strconv.Atoi(fields[5]); err == nil && n > 0 {
+            moveNumber = n
+        }
+    }
+    withClocks := hasClocks(game)
+
+    var tokens []string
+    needNumber := true
+    for i, move := range game.Moves {
+        if color == "w" {
+            tokens = append(tokens, strconv.Itoa(moveNumber)+".")
+        } else if needNumber {
+            tokens = append(tokens, strconv.Itoa(moveNumber)+"...")
+        }
+        tokens = append(tokens, move)
+        needNumber = false
+
+        if withClocks {
+            tokens = append(tokens, "{[%clk "+FormatClock(*game.MoveRecords[i].Clock)+"]}")
+            needNumber = true
+        }
+        if color == "b" {
+            moveNumber++
+            color = "w"
+        } else {
+            color = "b"
+        }
+    }
+    result := game.Result
+    if result == "" {
+        result = "*"
+    }
+    tokens = append(tokens, result)
+
+    lineLength := 0
+    for _, token := range tokens {
+        if lineLength > 0 && lineLength+1+len(token) > lineWidth {
+            b.WriteString("\n")
+            lineLength = 0
+        } else if lineLength > 0 {
+            b.WriteString(" ")
+            lineLength++
+        }
+        b.WriteString(token)
+        lineLength += len(token)
+    }
+}
+
+// Whether every move has a known clock to annotate
+func hasClocks(game entity.Game) bool {
+    if len(game.Moves) == 0 || len(game.MoveRecords) != len(game.Moves) {
+        return false
+    }
+    for _, record := range game.MoveRecords {
+        if record.Clock == nil {
+            return false
+        }
+    }
+    return true
+}
+
+// Milliseconds as a %clk value, H:MM:SS with tenths when there are any
+func FormatClock(ms int) string {
+    if ms < 0 {
+        ms = 0
+    }
+    tenths := ms / 100
+    clock := fmt.Sprintf("%d:%02d:%02d", tenths/36000, tenths/600%60, tenths/10%60)
+    if tenths%10 != 0 {
+        clock += "." + strconv.Itoa(tenths%10)
+    }
+    return clock
+}
+
+// A %clk value in milliseconds
+func ParseClock(clock string) (int, error) {
+    parts := strings.Split(clock, ":")
+    if len(parts) != 3 {
+        return 0, fmt.Errorf("invalid clock: %q", clock)
+    }
+    hours, errH := strconv.Atoi(parts[0])
+    minutes, errM := strconv.Atoi(parts[1])
+    seconds, errS := strconv.ParseFloat(parts[2], 64)
+    if errH != nil || errM != nil || errS != nil || hours < 0 || minutes < 0 || seconds < 0 {
+        return 0, fmt.Errorf("invalid clock: %q", clock)
+    }
+    return (hours*3600+minutes*60)*1000 + int(seconds*1000+0.5), nil
+}
+
+// A stored period: "[moves/]minutes", optional "d<delay>" or "b<delay>", optional "+<increment>"
+var storedPeriod = regexp.MustCompile(`^(?:(\d+)/)?(\d+(?:\.\d+)?)(?:[db]\d+)?(?:\+(\d+))?$`)
+
+// A PGN period: "[moves/]seconds[+increment]"
+var tagPeriod = regexp.MustCompile(`^(?:(\d+)/)?(\d+)(?:\+(\d+))?$`)
+
+// PGN TimeControl tag (seconds) for the stored notation (minutes); delays have no PGN form
+func TimeControlTag(stored string) string {
+    if stored == "" {
+        return "-"
+    }
+
+    var periods []string
+    for _, part := range strings.Split(stored, ":") {
+        match := storedPeriod.FindStringSubmatch(part)
+        if match == nil {
+            return "?"
+        }
+        minutes, _ := strconv.ParseFloat(match[2], 64)
+        period := strconv.Itoa(int(minutes*60 + 0.5))
+        if match[1] != "" {
+            period = match[1] + "/" + period
+        }
+        if match[3] != "" && match[3] != "0" {
+            period += "+" + match[3]
+        }
+        periods = append(periods, period)
+    }
+    return strings.Join(periods, ":")
+}
+
+// Stored notation for a PGN TimeControl tag ("" for none or unknown)
+func TimeControlFromTag(tag string) string {
+    if tag == "" || tag == "-" || tag == "?" {
+        return ""
+    }
+
+    var periods []string
+    for _, part := range strings.Split(tag, ":") {
+        match := tagPeriod.FindStringSubmatch(part)
+        if match == nil {
+            return ""
+        }
+        seconds, _ := strconv.Atoi(match[2])
+        period := strconv.FormatFloat(float64(seconds)/60, 'f', -1, 64)
+        if match[1] != "" {
+            period = match[1] + "/" + period
+        }
+        increment := match[3]
+        if increment == "" {
+            increment = "0"
+        }
+        periods = append(periods, period+"+"+increment)
+    }
+    return strings.Join(periods, ":")
+}
@@ -0,0 +1,49 @@
+package pgn
+
+import (
+    "testing"
+)
+
+func TestVariantFromTag(t *testing.T) {
+    tests := []struct {
+        tag    string
+        hasFEN bool
+        want   string
+    }{
+        {"", false, "standard"},
+        {"Standard", false, "standard"},
+        {"chess960", true, "chess960"},
+        {"Fischerandom", true, "chess960"},
+        {"Three-check", false, "threeCheck"},
+        {"From Position", true, "standard"},
+        {"From Position", false, ""},
+        {"Crazyhouse", false, ""},
+    }
+
+    for _, test := range tests {
+        if got := VariantFromTag(test.tag, test.hasFEN); got != test.want {
+            t.Fatalf("%q (FEN %v): got %q, want %q", test.tag, test.hasFEN, got, test.want)
+        }
+    }
+}
+
+// Stored time controls survive the trip through the PGN tag, multi-period ones included
+func TestTimeControlTag(t *testing.T) {
+    tests := []struct {
+        stored string
+        tag    string
+    }{
+        {"5+3", "300+3"},
+        {"40/90+30:30+30", "40/5400+30:1800+30"},
+        {"40/120+0:20/60+0:15+30", "40/7200:20/3600:900+30"},
+    }
+
+    for _, test := range tests {
+        if got := TimeControlTag(test.stored); got != test.tag {
+            t.Fatalf("TimeControlTag(%s): got %s, want %s", test.stored, got, test.tag)
+        }
+        if got := TimeControlFromTag(test.tag); got != test.stored {
+            t.Fatalf("TimeControlFromTag(%s): got %s, want %s", test.tag, got, test.stored)
+        }
+    }
+}
@@ -28,6 +28,7 @@ type CreateGameMsg struct {
     Player2     PlayerGameInfo   `json:"player2"`
     TimeControl TimeControl `json:"timeControl"`
     Colors      Colors          `json:"colors"`
+    Variant     string          `json:"variant,omitempty"`
 }
 
 func PublishGameCreate(ch *amqp091.Channel, msg CreateGameMsg) error {
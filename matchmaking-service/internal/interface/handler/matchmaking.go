@@ -17,6 +17,7 @@ type APIResponse struct {
 type FindMatchDto struct {
     TimeControl usecase.TimeControl `json:"timeControl"`
     Player      usecase.Player      `json:"player"`
+    Variant     string              `json:"variant"` // "standard" (default), "chess960", "kingOfTheHill", "threeCheck", "atomic"
 }
 
 
@@ -27,11 +28,16 @@ func JoinMatchmakingPool(workerPool *usecase.WorkerPool) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
-        key := fmt.Sprintf("%d_%d", req.TimeControl.InitialTime, req.TimeControl.Increment)
+        if !usecase.IsValidVariant(req.Variant) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported variant: " + req.Variant})
+            return
+        }
+        key := usecase.PoolKey(req.Variant, req.TimeControl)
         workerPool.Jobs <- usecase.MatchmakingJob{
             PoolKey: key,
             Player:  req.Player,
             TimeControl: req.TimeControl,
+            Variant: req.Variant,
         }
         c.JSON(http.StatusOK, APIResponse{
             Status:  "waiting",
@@ -50,7 +56,7 @@ func LeaveMatchmakingPool(poolManager *usecase.PoolManager) gin.HandlerFunc {
             c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
             return
         }
-        key := fmt.Sprintf("%d_%d", req.TimeControl.InitialTime, req.TimeControl.Increment)
+        key := usecase.PoolKey(req.Variant, req.TimeControl)
         poolManager.Leave(key, req.Player.UserId)
         c.JSON(http.StatusOK, APIResponse{
             Status:  "success",
@@ -1,16 +1,44 @@
 package usecase
 
 import (
+    "fmt"
     "github.com/petar/GoLLRB/llrb"
     "sync"
 )
 
+// Game variants
+const (
+    VariantStandard      = "standard"
+    VariantChess960      = "chess960"
+    VariantKingOfTheHill = "kingOfTheHill"
+    VariantThreeCheck    = "threeCheck"
+    VariantAtomic        = "atomic"
+)
+
 type TimeControl struct {
     Type        string `json:"type"`
     InitialTime int    `json:"initialTime"`
     Increment   int    `json:"increment"`
 }
 
+// Pool key for a time control, e.g. "10_0"; other variants wait in their own pools ("chess960_10_0")
+func PoolKey(variant string, timeControl TimeControl) string {
+    key := fmt.Sprintf("%d_%d", timeControl.InitialTime, timeControl.Increment)
+    if variant != "" && variant != VariantStandard {
+        return variant + "_" + key
+    }
+    return key
+}
+
+// Check if variant is supported ("" means standard)
+func IsValidVariant(variant string) bool {
+    switch variant {
+    case "", VariantStandard, VariantChess960, VariantKingOfTheHill, VariantThreeCheck, VariantAtomic:
+        return true
+    }
+    return false
+}
+
 type Player struct {
     UserId   int    `json:"userId"`
     UserName string `json:"userName"`
@@ -32,6 +32,7 @@ type MatchmakingJob struct {
     PoolKey string
     Player  Player
     TimeControl TimeControl
+    Variant     string
 }
 
 type WorkerPool struct {
@@ -240,6 +241,7 @@ func (wp *WorkerPool) worker() {
                     Player1: p1Color,
                     Player2: p2Color,
                 },
+                Variant: job.Variant,
             }
 
             err = messagebroker.PublishGameCreate(wp.MQChannel, gameMsg)
@@ -33,7 +33,7 @@ func main() {
         panic(err)
     }
 
-    dbConn.AutoMigrate(&entity.Player{})
+    dbConn.AutoMigrate(&entity.Player{}, &entity.ProcessedGame{})
     playerRepository := repository.NewPlayerRepository(dbConn)
 
 	conn, ch, err := messagebroker.ConnectRabbit()
@@ -43,6 +43,7 @@ func main() {
     defer conn.Close()
     defer ch.Close()
     messagebroker.ConsumePlayerRegister(ch, playerRepository)
+    messagebroker.ConsumeGameFinished(playerRepository)
 
     handler.RegisterPlayerRoutes(router, playerRepository)
 
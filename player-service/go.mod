@@ -2,6 +2,8 @@ module github.com/locne/player-service
 
 go 1.24.4
 
+require github.com/locne/protocol v0.0.0
+
 require (
 	github.com/bytedance/sonic v1.13.3 // indirect
 	github.com/bytedance/sonic/loader v0.2.4 // indirect
@@ -43,3 +45,5 @@ require (
 	gorm.io/driver/postgres v1.6.0 // indirect
 	gorm.io/gorm v1.30.1 // indirect
 )
+
+replace github.com/locne/protocol => ../protocol
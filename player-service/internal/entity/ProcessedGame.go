@@ -0,0 +1,11 @@
+package entity
+
+import (
+    "time"
+)
+
+// A game.finished event already applied to player stats (events arrive at least once)
+type ProcessedGame struct {
+    GameID      string    `gorm:"primaryKey;type:varchar(64)"`
+    ProcessedAt time.Time
+}
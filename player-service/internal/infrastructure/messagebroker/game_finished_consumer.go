@@ -0,0 +1,138 @@
+package messagebroker
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/locne/player-service/internal/usecase"
+    "github.com/locne/player-service/internal/interface/repository"
+    "github.com/locne/protocol"
+    "github.com/rabbitmq/amqp091-go"
+    "gorm.io/gorm"
+)
+
+const gameFinishedQueue = "player-service.game.finished"
+
+// Backoff between attempts to get the consumer back after the broker went away
+const (
+    reconnectBase = time.Second
+    reconnectMax  = time.Minute
+)
+
+// Apply game.finished events to player stats. Deliveries are acked only once applied
+// (or found unusable), so a crash or a database outage just means a redelivery.
+// The consumer has its own connection and reconnects whenever the broker drops it.
+func ConsumeGameFinished(repo repository.PlayerRepository) {
+    go func() {
+        delay := reconnectBase
+        for {
+            consumed, err := consumeGameFinished(repo)
+            if consumed {
+                delay = reconnectBase
+            }
+            log.Printf("game.finished consumer stopped: %v; reconnecting in %v", err, delay)
+            time.Sleep(delay)
+            if delay *= 2; delay > reconnectMax {
+                delay = reconnectMax
+            }
+        }
+    }()
+}
+
+// One consumer session, until the connection or channel closes. consumed reports whether
+// it got as far as receiving deliveries.
+func consumeGameFinished(repo repository.PlayerRepository) (consumed bool, err error) {
+    conn, ch, err := ConnectRabbit()
+    if err != nil {
+        return false, err
+    }
+    defer conn.Close()
+
+    if err := ch.ExchangeDeclare(
+        protocol.EventsExchange, // name
+        "topic",                 // kind
+        true,                    // durable
+        false,                   // autoDelete
+        false,                   // internal
+        false,                   // noWait
+        nil,                     // arguments
+    ); err != nil {
+        return false, fmt.Errorf("declare exchange: %v", err)
+    }
+    if _, err := ch.QueueDeclare(
+        gameFinishedQueue, // name
+        true,              // durable
+        false,             // autoDelete
+        false,             // exclusive
+        false,             // noWait
+        nil,               // arguments
+    ); err != nil {
+        return false, fmt.Errorf("declare queue: %v", err)
+    }
+    if err := ch.QueueBind(gameFinishedQueue, protocol.GameFinishedRoutingKey, protocol.EventsExchange, false, nil); err != nil {
+        return false, fmt.Errorf("bind queue: %v", err)
+    }
+
+    msgs, err := ch.Consume(
+        gameFinishedQueue, // queue
+        "",                // consumer
+        false,             // auto-ack
+        false,             // exclusive
+        false,             // no-local
+        false,             // no-wait
+        nil,               // args
+    )
+    if err != nil {
+        return false, fmt.Errorf("register consumer: %v", err)
+    }
+
+    connClosed := conn.NotifyClose(make(chan *amqp091.Error, 1))
+    chClosed := ch.NotifyClose(make(chan *amqp091.Error, 1))
+    for {
+        select {
+        case d, ok := <-msgs:
+            if !ok {
+                return true, fmt.Errorf("delivery channel closed")
+            }
+            applyGameFinished(d, repo)
+        case err := <-connClosed:
+            return true, fmt.Errorf("connection closed: %v", err)
+        case err := <-chClosed:
+            return true, fmt.Errorf("channel closed: %v", err)
+        }
+    }
+}
+
+func applyGameFinished(d amqp091.Delivery, repo repository.PlayerRepository) {
+    var event protocol.GameFinishedEvent
+    if err := json.Unmarshal(d.Body, &event); err != nil {
+        log.Printf("Invalid game.finished message: %v", err)
+        d.Nack(false, false)
+        return
+    }
+    if err := event.Validate(); err != nil {
+        log.Printf("Invalid game.finished event: %v", err)
+        d.Nack(false, false)
+        return
+    }
+
+    err := usecase.ApplyGameFinished(repo, event)
+    switch {
+    case err == nil:
+        d.Ack(false)
+    case errors.Is(err, usecase.ErrInvalidGameEvent):
+        log.Printf("Dropping game.finished event: %v", err)
+        d.Nack(false, false)
+    case errors.Is(err, gorm.ErrRecordNotFound):
+        log.Printf("Game %s: player not found, skipping", event.GameID)
+        d.Ack(false)
+    default:
+        // Nothing was written: try again after a pause
+        log.Printf("Apply game %s error: %v", event.GameID, err)
+        time.Sleep(time.Second)
+        d.Nack(false, true)
+    }
+}
@@ -1,13 +1,16 @@
 package repository
 
 import (
+    "time"
     "github.com/locne/player-service/internal/entity"
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
 )
 
 type PlayerRepository interface {
     Create(player entity.Player) error
     GetByUserIDAndGameType(userID int, gameType entity.GameType) (entity.Player, error)
+    ApplyGame(gameID string, whiteID, blackID int, gameType entity.GameType, apply func(white, black *entity.Player)) (bool, error)
 }
 
 type playerRepository struct {
@@ -26,4 +29,40 @@ func (r *playerRepository) GetByUserIDAndGameType(userID int, gameType entity.Ga
     var player entity.Player
     err := r.db.Where("user_id = ? AND game_type = ?", userID, gameType).First(&player).Error
     return player, err
-}
\ No newline at end of file
+}
+
+// Update both players of a finished game in one transaction, at most once per game.
+// Returns false if the game was already applied.
+func (r *playerRepository) ApplyGame(gameID string, whiteID, blackID int, gameType entity.GameType, apply func(white, black *entity.Player)) (bool, error) {
+    applied := false
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+            Create(&entity.ProcessedGame{GameID: gameID, ProcessedAt: time.Now()})
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected == 0 {
+            return nil
+        }
+
+        var white, black entity.Player
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+            Where("user_id = ? AND game_type = ?", whiteID, gameType).First(&white).Error; err != nil {
+            return err
+        }
+        if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND game_type = ?", blackID, gameType).First(&black).Error; err != nil {
+            return err
+        }
+
+        apply(&white, &black)
+        if err := tx.Save(&white).Error; err != nil {
+            return err
+        }
+        if err := tx.Save(&black).Error; err != nil {
+            return err
+        }
+        applied = true
+        return nil
+    })
+    return applied, err
+}
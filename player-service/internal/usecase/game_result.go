@@ -0,0 +1,103 @@
+package usecase
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "github.com/locne/player-service/internal/entity"
+    "github.com/locne/player-service/internal/interface/repository"
+    "github.com/locne/protocol"
+)
+
+// The event can never be applied, however often it is redelivered
+var ErrInvalidGameEvent = errors.New("invalid game event")
+
+// Elo K-factor: provisional players move fast, strong players slowly
+func kFactor(player entity.Player) float64 {
+    switch {
+    case player.GamesPlayed < 30:
+        return 40
+    case player.Rating >= 2400:
+        return 10
+    }
+    return 20
+}
+
+// Rating change for a player scoring score (1, 0.5, 0) against opponentRating
+func ratingDelta(player entity.Player, opponentRating int, score float64) int {
+    expected := 1 / (1 + math.Pow(10, float64(opponentRating-player.Rating)/400))
+    return int(math.Round(kFactor(player) * (score - expected)))
+}
+
+// White's score for a decisive or drawn result
+func whiteScore(result string) (float64, error) {
+    switch result {
+    case "1-0":
+        return 1, nil
+    case "0-1":
+        return 0, nil
+    case "1/2-1/2":
+        return 0.5, nil
+    }
+    return 0, fmt.Errorf("unexpected result %q", result)
+}
+
+func recordResult(player *entity.Player, score float64) {
+    player.GamesPlayed++
+    switch score {
+    case 1:
+        player.Wins++
+    case 0:
+        player.Losses++
+    default:
+        player.Draws++
+    }
+}
+
+// Update stats (and ratings for rated games) of both players from a finished game.
+// Ratings are for standard chess only, so variants and custom start positions just add
+// to the stats. Aborted games don't count; replays of an applied event are ignored.
+func ApplyGameFinished(repo repository.PlayerRepository, event protocol.GameFinishedEvent) error {
+    if event.Aborted {
+        return nil
+    }
+    if event.White.UserID == event.Black.UserID {
+        return fmt.Errorf("%w: game %s has player %d on both sides", ErrInvalidGameEvent, event.GameID, event.White.UserID)
+    }
+
+    gameType := entity.GameType(event.GameType)
+    switch gameType {
+    case entity.GameTypeBullet, entity.GameTypeBlitz, entity.GameTypeRapid, entity.GameTypeClassical:
+    default:
+        return fmt.Errorf("%w: game %s has unknown game type %q", ErrInvalidGameEvent, event.GameID, event.GameType)
+    }
+
+    score, err := whiteScore(event.Result)
+    if err != nil {
+        return fmt.Errorf("%w: game %s: %v", ErrInvalidGameEvent, event.GameID, err)
+    }
+
+    rated := event.Rated && event.IsStandardChess()
+    _, err = repo.ApplyGame(event.GameID, event.White.UserID, event.Black.UserID, gameType,
+        func(white, black *entity.Player) {
+            if rated {
+                // Both deltas from the ratings before the game
+                whiteDelta := ratingDelta(*white, black.Rating, score)
+                blackDelta := ratingDelta(*black, white.Rating, 1-score)
+                white.Rating += whiteDelta
+                black.Rating += blackDelta
+                if white.Rating > white.PeakRating {
+                    white.PeakRating = white.Rating
+                }
+                if black.Rating > black.PeakRating {
+                    black.PeakRating = black.Rating
+                }
+            }
+
+            white.WhiteGames++
+            black.BlackGames++
+            recordResult(white, score)
+            recordResult(black, 1-score)
+        })
+    return err
+}
@@ -0,0 +1,172 @@
+// Compatibility suite for the wire protocol: the Go types and schema/v1.json describe the
+// same fields, and every recorded message under testdata still decodes, validates and
+// encodes back to the same JSON.
+//
+// Fixtures are named <Type>.<case>.json; cases containing "invalid" must fail Validate.
+package protocol_test
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strings"
+    "testing"
+    "github.com/locne/protocol"
+)
+
+var types = map[string]reflect.Type{
+    "MoveMessage":        reflect.TypeOf(protocol.MoveMessage{}),
+    "GameActionMessage":  reflect.TypeOf(protocol.GameActionMessage{}),
+    "PresenceMessage":    reflect.TypeOf(protocol.PresenceMessage{}),
+    "StateUpdateMessage": reflect.TypeOf(protocol.StateUpdateMessage{}),
+    "GameFinishedEvent":  reflect.TypeOf(protocol.GameFinishedEvent{}),
+    "FinishedPlayer":     reflect.TypeOf(protocol.FinishedPlayer{}),
+    "Player":             reflect.TypeOf(protocol.Player{}),
+    "Position":           reflect.TypeOf(protocol.Position{}),
+    "Piece":              reflect.TypeOf(protocol.Piece{}),
+    "Move":               reflect.TypeOf(protocol.Move{}),
+    "MoveNotation":       reflect.TypeOf(protocol.MoveNotation{}),
+    "CastlingRights":     reflect.TypeOf(protocol.CastlingRights{}),
+    "BitboardGame":       reflect.TypeOf(protocol.BitboardGame{}),
+    "VariantState":       reflect.TypeOf(protocol.VariantState{}),
+    "ClientGameState":    reflect.TypeOf(protocol.ClientGameState{}),
+    "TimeStage":          reflect.TypeOf(protocol.TimeStage{}),
+    "TimeControl":        reflect.TypeOf(protocol.TimeControl{}),
+}
+
+type validator interface {
+    Validate() error
+}
+
+// Every schema definition has a Go type with exactly the same JSON fields
+func TestSchemaMatchesTypes(t *testing.T) {
+    data, err := os.ReadFile(filepath.Join("schema", "v1.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    var schema struct {
+        Defs map[string]struct {
+            Properties map[string]json.RawMessage `json:"properties"`
+        } `json:"$defs"`
+    }
+    if err := json.Unmarshal(data, &schema); err != nil {
+        t.Fatal(err)
+    }
+
+    var problems []string
+    for name, typ := range types {
+        def, ok := schema.Defs[name]
+        if !ok {
+            problems = append(problems, fmt.Sprintf("%s has no schema definition", name))
+            continue
+        }
+        fields := jsonFields(typ)
+        for field := range fields {
+            if _, ok := def.Properties[field]; !ok {
+                problems = append(problems, fmt.Sprintf("%s.%s is missing from the schema", name, field))
+            }
+        }
+        for field := range def.Properties {
+            if !fields[field] {
+                problems = append(problems, fmt.Sprintf("%s.%s is in the schema but not in the Go type", name, field))
+            }
+        }
+    }
+    for name := range schema.Defs {
+        if _, ok := types[name]; !ok {
+            problems = append(problems, fmt.Sprintf("schema definition %s has no Go type", name))
+        }
+    }
+    sort.Strings(problems)
+    for _, problem := range problems {
+        t.Error(problem)
+    }
+}
+
+func TestFixtures(t *testing.T) {
+    fixtures, err := filepath.Glob(filepath.Join("testdata", "*", "*.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(fixtures) == 0 {
+        t.Fatal("no fixtures under testdata")
+    }
+    for _, path := range fixtures {
+        name := filepath.ToSlash(strings.TrimPrefix(path, "testdata"+string(filepath.Separator)))
+        t.Run(name, func(t *testing.T) {
+            if err := checkFixture(path); err != nil {
+                t.Fatal(err)
+            }
+        })
+    }
+}
+
+func jsonFields(t reflect.Type) map[string]bool {
+    fields := make(map[string]bool)
+    for i := 0; i < t.NumField(); i++ {
+        tag := t.Field(i).Tag.Get("json")
+        name := strings.Split(tag, ",")[0]
+        if name == "-" {
+            continue
+        }
+        if name == "" {
+            name = t.Field(i).Name
+        }
+        fields[name] = true
+    }
+    return fields
+}
+
+func checkFixture(path string) error {
+    base := filepath.Base(path)
+    typeName := strings.SplitN(base, ".", 2)[0]
+    t, ok := types[typeName]
+    if !ok {
+        return fmt.Errorf("unknown message type %s", typeName)
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+
+    // Unknown fields mean the fixture relies on something the type no longer has
+    msg := reflect.New(t).Interface()
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(msg); err != nil {
+        return fmt.Errorf("decode: %v", err)
+    }
+
+    wantInvalid := strings.Contains(base, "invalid")
+    if v, ok := msg.(validator); ok {
+        err := v.Validate()
+        if wantInvalid && err == nil {
+            return fmt.Errorf("validated but should have been rejected")
+        }
+        if !wantInvalid && err != nil {
+            return fmt.Errorf("validate: %v", err)
+        }
+    }
+    if wantInvalid {
+        return nil
+    }
+
+    encoded, err := json.Marshal(msg)
+    if err != nil {
+        return fmt.Errorf("encode: %v", err)
+    }
+    var before, after map[string]interface{}
+    json.Unmarshal(data, &before)
+    json.Unmarshal(encoded, &after)
+    for key, value := range before {
+        if !reflect.DeepEqual(value, after[key]) {
+            return fmt.Errorf("field %s changed on round trip: %v -> %v", key, value, after[key])
+        }
+    }
+    return nil
+}
@@ -0,0 +1,45 @@
+package protocol
+
+// Domain events: game-service -> other services, over RabbitMQ
+
+// Topic exchange carrying game events; each consuming service binds its own queue
+const (
+    EventsExchange         = "game.events"
+    GameFinishedRoutingKey = "game.finished"
+)
+
+// Published once a game is over. Delivery is at least once: consumers dedupe on GameID.
+type GameFinishedEvent struct {
+    Version     int            `json:"version,omitempty"`
+    GameID      string         `json:"gameId"`
+    White       FinishedPlayer `json:"white"`
+    Black       FinishedPlayer `json:"black"`
+    Result      string         `json:"result"` // "1-0", "0-1", "1/2-1/2", "*" (aborted)
+    Reason      string         `json:"reason"`
+    Aborted     bool           `json:"aborted"` // Ended before it got going: no ratings, no stats
+    Rated       bool           `json:"rated"`
+    GameType    string         `json:"gameType"` // "bullet", "blitz", "rapid", "classical"
+    TimeControl string         `json:"timeControl"` // Stored notation, e.g. "3+2", "40/90+30:30+30"
+    Variant     string         `json:"variant"`
+    InitialFen  string         `json:"initialFen,omitempty"`
+    Moves       []string       `json:"moves"` // SAN
+    FinishedAt  int64          `json:"finishedAt"` // Unix ms
+}
+
+// Initial position of standard chess, as game-service writes it into InitialFen
+const StandardStartFen = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// Whether the game was standard chess from the initial position. InitialFen is set for
+// every game, so only a different start position counts as custom.
+func (e GameFinishedEvent) IsStandardChess() bool {
+    if e.Variant != "" && e.Variant != "standard" {
+        return false
+    }
+    return e.InitialFen == "" || e.InitialFen == StandardStartFen
+}
+
+type FinishedPlayer struct {
+    UserID   int    `json:"userId"`
+    Username string `json:"username"`
+    Rating   int    `json:"rating"` // Before the game
+}
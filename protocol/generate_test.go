@@ -0,0 +1,36 @@
+package protocol_test
+
+import (
+    "bytes"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "testing"
+)
+
+// validate_gen.go is what protogen makes of the committed schema, i.e. nobody edited
+// one without running go generate
+func TestValidateGenUpToDate(t *testing.T) {
+    goTool, err := exec.LookPath("go")
+    if err != nil {
+        t.Skip("go tool not found")
+    }
+
+    out := filepath.Join(t.TempDir(), "validate_gen.go")
+    cmd := exec.Command(goTool, "run", "./internal/protogen", "-schema", "schema/v1.json", "-out", out)
+    if output, err := cmd.CombinedOutput(); err != nil {
+        t.Fatalf("protogen: %v\n%s", err, output)
+    }
+
+    want, err := os.ReadFile(out)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got, err := os.ReadFile("validate_gen.go")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.Equal(got, want) {
+        t.Fatal("validate_gen.go is out of date with schema/v1.json; run go generate")
+    }
+}
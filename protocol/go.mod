@@ -0,0 +1,3 @@
+module github.com/locne/protocol
+
+go 1.24.4
@@ -0,0 +1,176 @@
+// protogen writes Validate methods for the messages in the protocol schema.
+//
+//    go run ./internal/protogen -schema schema/v1.json -out validate_gen.go
+//
+// Every object marked "x-message" gets a method checking the version, required strings,
+// string enums and integer bounds of its top-level properties.
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "go/format"
+    "log"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+type property struct {
+    Name    string
+    Type    interface{}   `json:"type"`
+    Enum    []string      `json:"enum"`
+    Minimum *int64        `json:"minimum"`
+    Maximum *int64        `json:"maximum"`
+    GoName  string        `json:"x-goName"`
+}
+
+type definition struct {
+    Message    bool            `json:"x-message"`
+    Properties json.RawMessage `json:"properties"`
+    Required   []string        `json:"required"`
+}
+
+type schema struct {
+    Defs map[string]definition `json:"$defs"`
+}
+
+func main() {
+    schemaPath := flag.String("schema", "schema/v1.json", "protocol schema")
+    outPath := flag.String("out", "validate_gen.go", "generated file")
+    flag.Parse()
+
+    data, err := os.ReadFile(*schemaPath)
+    if err != nil {
+        log.Fatal(err)
+    }
+    var s schema
+    if err := json.Unmarshal(data, &s); err != nil {
+        log.Fatalf("%s: %v", *schemaPath, err)
+    }
+
+    names := make([]string, 0, len(s.Defs))
+    for name, def := range s.Defs {
+        if def.Message {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+
+    var buf bytes.Buffer
+    fmt.Fprintf(&buf, "// Code generated by protogen from %s. DO NOT EDIT.\n\npackage protocol\n", filepath.ToSlash(*schemaPath))
+    for _, name := range names {
+        props, err := orderedProperties(s.Defs[name].Properties)
+        if err != nil {
+            log.Fatalf("%s: %v", name, err)
+        }
+        writeValidate(&buf, name, props, s.Defs[name].Required)
+    }
+
+    src, err := format.Source(buf.Bytes())
+    if err != nil {
+        log.Fatalf("format generated code: %v\n%s", err, buf.Bytes())
+    }
+    if err := os.WriteFile(*outPath, src, 0644); err != nil {
+        log.Fatal(err)
+    }
+}
+
+// Properties in schema order, so the checks read like the schema
+func orderedProperties(raw json.RawMessage) ([]property, error) {
+    dec := json.NewDecoder(bytes.NewReader(raw))
+    if _, err := dec.Token(); err != nil {
+        return nil, err
+    }
+
+    var props []property
+    for dec.More() {
+        key, err := dec.Token()
+        if err != nil {
+            return nil, err
+        }
+        var p property
+        if err := dec.Decode(&p); err != nil {
+            return nil, err
+        }
+        p.Name = key.(string)
+        props = append(props, p)
+    }
+    return props, nil
+}
+
+func writeValidate(buf *bytes.Buffer, message string, props []property, required []string) {
+    isRequired := make(map[string]bool)
+    for _, name := range required {
+        isRequired[name] = true
+    }
+
+    fmt.Fprintf(buf, "\nfunc (m *%s) Validate() error {\n", message)
+    for _, p := range props {
+        field := "m." + goName(p)
+        fail := fmt.Sprintf("return &ValidationError{Message: %q, Field: %q, Value: %s}\n", message, p.Name, field)
+
+        if p.Name == "version" {
+            fmt.Fprintf(buf, "if err := CheckVersion(%s); err != nil {\nreturn err\n}\n", field)
+            continue
+        }
+
+        switch p.Type {
+        case "string":
+            if len(p.Enum) > 0 {
+                quoted := make([]string, len(p.Enum))
+                for i, value := range p.Enum {
+                    quoted[i] = strconv.Quote(value)
+                }
+                // Optional fields may be left empty unless the enum already allows it
+                guard := !isRequired[p.Name] && !contains(p.Enum, "")
+                if guard {
+                    fmt.Fprintf(buf, "if %s != \"\" {\n", field)
+                }
+                fmt.Fprintf(buf, "switch %s {\ncase %s:\ndefault:\n%s}\n", field, strings.Join(quoted, ", "), fail)
+                if guard {
+                    buf.WriteString("}\n")
+                }
+            } else if isRequired[p.Name] {
+                fmt.Fprintf(buf, "if %s == \"\" {\n%s}\n", field, fail)
+            }
+        case "integer":
+            var bounds []string
+            if p.Minimum != nil {
+                bounds = append(bounds, fmt.Sprintf("%s < %d", field, *p.Minimum))
+            }
+            if p.Maximum != nil {
+                bounds = append(bounds, fmt.Sprintf("%s > %d", field, *p.Maximum))
+            }
+            if len(bounds) > 0 {
+                fmt.Fprintf(buf, "if %s {\n%s}\n", strings.Join(bounds, " || "), fail)
+            }
+        }
+    }
+    buf.WriteString("return nil\n}\n")
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+// roomId -> RoomID, unless the schema says otherwise
+func goName(p property) string {
+    if p.GoName != "" {
+        return p.GoName
+    }
+    name := strings.ToUpper(p.Name[:1]) + p.Name[1:]
+    if strings.HasSuffix(name, "Id") {
+        name = strings.TrimSuffix(name, "Id") + "ID"
+    }
+    return name
+}
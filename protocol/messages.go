@@ -0,0 +1,77 @@
+package protocol
+
+// Inbound: ws-service -> game-service
+
+// "resync" comes from ws-service itself after it missed trimmed updates; PlayerID is unused.
+type MoveMessage struct {
+    Version   int    `json:"version,omitempty"`
+    Type      string `json:"type"` // "move", "premove", "getGameState", "getLegalMoves", "resync"
+    RoomID    string `json:"roomId"`
+    PlayerID  int    `json:"playerId"`
+    MoveID    string `json:"moveId,omitempty"` // Client-generated, echoed in moveAck/moveRejected
+    FromRow   int    `json:"fromRow"`
+    FromCol   int    `json:"fromCol"`
+    ToRow     int    `json:"toRow"`
+    ToCol     int    `json:"toCol"`
+    Promotion string `json:"promotion,omitempty"`
+    SAN       string `json:"san,omitempty"` // Alternative to row/col, e.g. "Nbd7"
+}
+
+type GameActionMessage struct {
+    Version  int    `json:"version,omitempty"`
+    Type     string `json:"type"` // "gameAction"
+    RoomID   string `json:"roomId"`
+    PlayerID int    `json:"playerId"`
+    Action   string `json:"action"` // "resign", "drawOffer", "drawAccept", "drawDecline", "abort", "claimVictory", "claimDraw", "takebackRequest", "takebackAccept", "takebackDecline", "cancelPremove"
+    OfferID  string `json:"offerId,omitempty"` // For draw and takeback offers
+}
+
+// Sent when a player's socket closes or rejoins a room
+type PresenceMessage struct {
+    Version  int    `json:"version,omitempty"`
+    Type     string `json:"type"` // "presence"
+    RoomID   string `json:"roomId"`
+    PlayerID int    `json:"playerId"`
+    Status   string `json:"status"` // "connected", "disconnected"
+    Role     string `json:"role,omitempty"` // "player" (default) or "spectator"
+}
+
+// Outbound: game-service -> ws-service -> clients
+
+// Who in the room gets an untargeted update. Games with a spectator delay send each
+// update to the players at once and to the spectators later.
+const (
+    AudiencePlayers    = "players"
+    AudienceSpectators = "spectators"
+)
+
+type StateUpdateMessage struct {
+    Version       int             `json:"version,omitempty"`
+    Type          string          `json:"type"`
+    RoomID        string          `json:"roomId"`
+    MoveID        string          `json:"moveId,omitempty"` // Move a moveAck or moveRejected answers
+    GameState     ClientGameState `json:"gameState,omitempty"`
+    Player1       Player          `json:"player1,omitempty"`
+    Player2       Player          `json:"player2,omitempty"`
+    WhiteTimeLeft int             `json:"whiteTimeLeft,omitempty"` // Milliseconds
+    BlackTimeLeft int             `json:"blackTimeLeft,omitempty"` // Milliseconds
+    ActiveColor   string          `json:"activeColor,omitempty"` // For clockSync
+    DelayLeft     int             `json:"delayLeft,omitempty"` // Milliseconds of simple delay left for the side to move
+    TimeControl   *TimeControl    `json:"timeControl,omitempty"`
+    ServerTime    int64           `json:"serverTime,omitempty"` // Unix ms when the clock snapshot was taken
+    MoveHistory   []MoveNotation  `json:"moveHistory,omitempty"`
+    LegalMoves    []Move          `json:"legalMoves,omitempty"`
+    Error         string          `json:"error,omitempty"`
+    Result        string          `json:"result,omitempty"`
+    Reason        string          `json:"reason,omitempty"`
+    Winner        string          `json:"winner,omitempty"`
+    OfferID       string          `json:"offerId,omitempty"` // For draw and takeback offers
+    OfferFrom     int             `json:"offerFrom,omitempty"` // Player ID who made the offer
+    OfferExpiresAt int64          `json:"offerExpiresAt,omitempty"` // Unix ms
+    PlayerID      int             `json:"playerId,omitempty"` // Player a presence update is about
+    ReconnectTimeLeft int         `json:"reconnectTimeLeft,omitempty"` // Milliseconds before the game is decided
+    TargetPlayerID *int           `json:"targetPlayerId,omitempty"` // For targeted messages
+    Audience      string          `json:"audience,omitempty"` // Untargeted messages only: "players", "spectators" or everyone
+    SpectatorCount int            `json:"spectatorCount,omitempty"`
+    Seq           int64           `json:"seq,omitempty"` // Per-game sequence number, set from the outbound stream
+}
@@ -0,0 +1,21 @@
+package protocol
+
+// Every game has an inbound stream of requests from ws-service and an outbound stream of
+// numbered state updates from game-service
+func InStreamKey(gameID string) string  { return "game:" + gameID + ":in" }
+func OutStreamKey(gameID string) string { return "game:" + gameID + ":out" }
+func OutSeqKey(gameID string) string    { return "game:" + gameID + ":seq" }
+
+// matchFound is not tied to a game room yet, so it has a stream of its own
+const MatchFoundStream = "games:matchFound"
+
+// Entry fields and the kinds of inbound entries
+const (
+    FieldKind = "kind"
+    FieldData = "data"
+    FieldSeq  = "seq"
+
+    KindMove       = "move"
+    KindGameAction = "gameAction"
+    KindPresence   = "presence"
+)
@@ -0,0 +1,97 @@
+package protocol
+
+type Player struct {
+    ID       int    `json:"userId"`
+    Username string `json:"username"`
+    Color    string `json:"color"`
+    Rating   int    `json:"rating,omitempty"`
+    IsOnline bool   `json:"isOnline"`
+}
+
+type Position struct {
+    Row int `json:"row"`
+    Col int `json:"col"`
+}
+
+// Field names stay capitalized: clients have always received them that way
+type Piece struct {
+    Type  string `json:"Type"`  // "pawn", "knight", "bishop", "rook", "queen", "king"
+    Color string `json:"Color"` // "white", "black"
+}
+
+type Move struct {
+    From      Position `json:"from"`
+    To        Position `json:"to"`
+    Piece     Piece    `json:"piece"`
+    Captured  *Piece   `json:"captured,omitempty"`
+    Promotion string   `json:"promotion,omitempty"`
+    Flags     int      `json:"flags"`
+}
+
+type MoveNotation struct {
+    MoveNumber int    `json:"moveNumber"`
+    White      string `json:"white"`
+    Black      string `json:"black"`
+}
+
+type CastlingRights struct {
+    WhiteKingSide  bool `json:"whiteKingSide"`
+    WhiteQueenSide bool `json:"whiteQueenSide"`
+    BlackKingSide  bool `json:"blackKingSide"`
+    BlackQueenSide bool `json:"blackQueenSide"`
+
+    Chess960               bool `json:"chess960,omitempty"`
+    WhiteKingSideRookFile  int  `json:"whiteKingSideRookFile,omitempty"`
+    WhiteQueenSideRookFile int  `json:"whiteQueenSideRookFile,omitempty"`
+    BlackKingSideRookFile  int  `json:"blackKingSideRookFile,omitempty"`
+    BlackQueenSideRookFile int  `json:"blackQueenSideRookFile,omitempty"`
+}
+
+// Bitboards travel as strings: JavaScript numbers can't hold 64 bits
+type BitboardGame struct {
+    WhitePawns   uint64 `json:"WhitePawns,string"`
+    WhiteRooks   uint64 `json:"WhiteRooks,string"`
+    WhiteKnights uint64 `json:"WhiteKnights,string"`
+    WhiteBishops uint64 `json:"WhiteBishops,string"`
+    WhiteQueens  uint64 `json:"WhiteQueens,string"`
+    WhiteKing    uint64 `json:"WhiteKing,string"`
+
+    BlackPawns   uint64 `json:"BlackPawns,string"`
+    BlackRooks   uint64 `json:"BlackRooks,string"`
+    BlackKnights uint64 `json:"BlackKnights,string"`
+    BlackBishops uint64 `json:"BlackBishops,string"`
+    BlackQueens  uint64 `json:"BlackQueens,string"`
+    BlackKing    uint64 `json:"BlackKing,string"`
+}
+
+type VariantState struct {
+    Checks map[string]int `json:"checks,omitempty"` // Three-check: checks given by each color
+}
+
+type ClientGameState struct {
+    Variant         string         `json:"variant,omitempty"`
+    CurrentFen      string         `json:"currentFen"`
+    Bitboards       BitboardGame   `json:"bitboards"`
+    ActiveColor     string         `json:"activeColor"`
+    CastlingRights  CastlingRights `json:"castlingRights"`
+    EnPassantSquare *Position      `json:"enPassantSquare"`
+    VariantState    VariantState   `json:"variantState"`
+}
+
+type TimeStage struct {
+    Moves     int    `json:"moves,omitempty"` // Moves in this period, 0 for the rest of the game
+    Time      int    `json:"time"`            // Seconds added when the period starts
+    Increment int    `json:"increment,omitempty"`
+    Mode      string `json:"mode,omitempty"`
+    Delay     int    `json:"delay,omitempty"`
+}
+
+type TimeControl struct {
+    Type        string      `json:"type"`
+    InitialTime int         `json:"initialTime"` // Seconds
+    Increment   int         `json:"increment"`   // Seconds added after each move
+    Mode        string      `json:"mode,omitempty"`   // "fischer" (default), "delay" or "bronstein"
+    Delay       int         `json:"delay,omitempty"`  // Seconds, for delay and bronstein
+    Moves       int         `json:"moves,omitempty"`  // Moves in the first period, 0 for the whole game
+    Stages      []TimeStage `json:"stages,omitempty"` // Later periods, e.g. 30 minutes after move 40
+}
@@ -0,0 +1,137 @@
+// Code generated by protogen from schema/v1.json. DO NOT EDIT.
+
+package protocol
+
+func (m *GameActionMessage) Validate() error {
+	if err := CheckVersion(m.Version); err != nil {
+		return err
+	}
+	switch m.Type {
+	case "gameAction":
+	default:
+		return &ValidationError{Message: "GameActionMessage", Field: "type", Value: m.Type}
+	}
+	if m.RoomID == "" {
+		return &ValidationError{Message: "GameActionMessage", Field: "roomId", Value: m.RoomID}
+	}
+	if m.PlayerID < 1 {
+		return &ValidationError{Message: "GameActionMessage", Field: "playerId", Value: m.PlayerID}
+	}
+	switch m.Action {
+	case "resign", "drawOffer", "drawAccept", "drawDecline", "abort", "claimVictory", "claimDraw", "takebackRequest", "takebackAccept", "takebackDecline", "cancelPremove":
+	default:
+		return &ValidationError{Message: "GameActionMessage", Field: "action", Value: m.Action}
+	}
+	return nil
+}
+
+func (m *GameFinishedEvent) Validate() error {
+	if err := CheckVersion(m.Version); err != nil {
+		return err
+	}
+	if m.GameID == "" {
+		return &ValidationError{Message: "GameFinishedEvent", Field: "gameId", Value: m.GameID}
+	}
+	switch m.Result {
+	case "1-0", "0-1", "1/2-1/2", "*":
+	default:
+		return &ValidationError{Message: "GameFinishedEvent", Field: "result", Value: m.Result}
+	}
+	if m.Reason == "" {
+		return &ValidationError{Message: "GameFinishedEvent", Field: "reason", Value: m.Reason}
+	}
+	return nil
+}
+
+func (m *MoveMessage) Validate() error {
+	if err := CheckVersion(m.Version); err != nil {
+		return err
+	}
+	switch m.Type {
+	case "move", "getGameState", "getLegalMoves", "premove", "resync":
+	default:
+		return &ValidationError{Message: "MoveMessage", Field: "type", Value: m.Type}
+	}
+	if m.RoomID == "" {
+		return &ValidationError{Message: "MoveMessage", Field: "roomId", Value: m.RoomID}
+	}
+	if m.PlayerID < 0 {
+		return &ValidationError{Message: "MoveMessage", Field: "playerId", Value: m.PlayerID}
+	}
+	if m.FromRow < 0 || m.FromRow > 7 {
+		return &ValidationError{Message: "MoveMessage", Field: "fromRow", Value: m.FromRow}
+	}
+	if m.FromCol < 0 || m.FromCol > 7 {
+		return &ValidationError{Message: "MoveMessage", Field: "fromCol", Value: m.FromCol}
+	}
+	if m.ToRow < 0 || m.ToRow > 7 {
+		return &ValidationError{Message: "MoveMessage", Field: "toRow", Value: m.ToRow}
+	}
+	if m.ToCol < 0 || m.ToCol > 7 {
+		return &ValidationError{Message: "MoveMessage", Field: "toCol", Value: m.ToCol}
+	}
+	return nil
+}
+
+func (m *PresenceMessage) Validate() error {
+	if err := CheckVersion(m.Version); err != nil {
+		return err
+	}
+	switch m.Type {
+	case "presence":
+	default:
+		return &ValidationError{Message: "PresenceMessage", Field: "type", Value: m.Type}
+	}
+	if m.RoomID == "" {
+		return &ValidationError{Message: "PresenceMessage", Field: "roomId", Value: m.RoomID}
+	}
+	if m.PlayerID < 1 {
+		return &ValidationError{Message: "PresenceMessage", Field: "playerId", Value: m.PlayerID}
+	}
+	switch m.Status {
+	case "connected", "disconnected":
+	default:
+		return &ValidationError{Message: "PresenceMessage", Field: "status", Value: m.Status}
+	}
+	switch m.Role {
+	case "", "player", "spectator":
+	default:
+		return &ValidationError{Message: "PresenceMessage", Field: "role", Value: m.Role}
+	}
+	return nil
+}
+
+func (m *StateUpdateMessage) Validate() error {
+	if err := CheckVersion(m.Version); err != nil {
+		return err
+	}
+	if m.Type == "" {
+		return &ValidationError{Message: "StateUpdateMessage", Field: "type", Value: m.Type}
+	}
+	if m.RoomID == "" {
+		return &ValidationError{Message: "StateUpdateMessage", Field: "roomId", Value: m.RoomID}
+	}
+	switch m.ActiveColor {
+	case "", "white", "black":
+	default:
+		return &ValidationError{Message: "StateUpdateMessage", Field: "activeColor", Value: m.ActiveColor}
+	}
+	if m.DelayLeft < 0 {
+		return &ValidationError{Message: "StateUpdateMessage", Field: "delayLeft", Value: m.DelayLeft}
+	}
+	if m.ReconnectTimeLeft < 0 {
+		return &ValidationError{Message: "StateUpdateMessage", Field: "reconnectTimeLeft", Value: m.ReconnectTimeLeft}
+	}
+	switch m.Audience {
+	case "", "players", "spectators":
+	default:
+		return &ValidationError{Message: "StateUpdateMessage", Field: "audience", Value: m.Audience}
+	}
+	if m.SpectatorCount < 0 {
+		return &ValidationError{Message: "StateUpdateMessage", Field: "spectatorCount", Value: m.SpectatorCount}
+	}
+	if m.Seq < 0 {
+		return &ValidationError{Message: "StateUpdateMessage", Field: "seq", Value: m.Seq}
+	}
+	return nil
+}
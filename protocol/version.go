@@ -0,0 +1,36 @@
+// Package protocol holds the messages game-service and ws-service exchange over Redis,
+// and the events game-service publishes to other services over RabbitMQ.
+// schema/v1.json is the source of truth; the validators are generated from it.
+package protocol
+
+//go:generate go run ./internal/protogen -schema schema/v1.json -out validate_gen.go
+
+import "fmt"
+
+// Version is stamped on every message we send. Peers accept anything from MinVersion up;
+// a message without a version comes from a sender older than the field and speaks v1.
+const (
+    Version    = 1
+    MinVersion = 1
+)
+
+func CheckVersion(v int) error {
+    if v == 0 {
+        return nil
+    }
+    if v < MinVersion || v > Version {
+        return fmt.Errorf("unsupported protocol version %d (want %d-%d)", v, MinVersion, Version)
+    }
+    return nil
+}
+
+// ValidationError reports the first field of a message that breaks the schema
+type ValidationError struct {
+    Message string
+    Field   string
+    Value   interface{}
+}
+
+func (e *ValidationError) Error() string {
+    return fmt.Sprintf("%s: invalid %s %v", e.Message, e.Field, e.Value)
+}
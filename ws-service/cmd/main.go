@@ -26,6 +26,7 @@ func main() {
 
     roomManager := usecase.NewRoomManager(redisClient)
     go roomManager.ListenStateUpdates()
+    go roomManager.ListenMatchFound()
     
     router := gin.Default()
     frontendEnv := os.Getenv("FRONTEND_ORIGIN")
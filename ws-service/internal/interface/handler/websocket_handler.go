@@ -18,11 +18,13 @@ var upgrader = websocket.Upgrader{
     },
 }
 
+// Sent as "joinRoom" by players and "spectate" by spectators
 type JoinRoomMessage struct {
     Type     string `json:"type"`
     RoomID   string `json:"roomId"`
     UserID   int    `json:"userId"`
     Username string `json:"username"`
+    LastSeq  int64  `json:"lastSeq,omitempty"` // Last update seen before reconnecting
 }
 
 type JoinMatchmakingMessage struct {
@@ -35,7 +37,7 @@ type GameActionMessage struct {
     Type     string `json:"type"`     // "gameAction" 
     RoomID   string `json:"roomId"`
     PlayerID int    `json:"playerId"`
-    Action   string `json:"action"`   // "resign", "drawOffer", "drawAccept", "drawDecline"
+    Action   string `json:"action"`   // "resign", "drawOffer", "drawAccept", "drawDecline", "abort", "claimVictory", "claimDraw", "takebackRequest", "takebackAccept", "takebackDecline", "cancelPremove"
     OfferID  string `json:"offerId,omitempty"` // For draw offers
 }
 
@@ -133,22 +135,38 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request, rm *usecase.RoomMan
             
             log.Printf("User %d joined matchmaking", joinMsg.UserID)
 
-        case "joinRoom":
+        case "joinRoom", "spectate":
             var joinMsg JoinRoomMessage
             if err := json.Unmarshal(messageData, &joinMsg); err != nil {
-                log.Printf("Invalid joinRoom message: %v", err)
+                log.Printf("Invalid %s message: %v", msgType, err)
                 continue
             }
 
             client = &usecase.Client{
-                Conn:     conn,
-                UserID:   joinMsg.UserID,
-                Username: joinMsg.Username,
-                Send:     send,
+                Conn:      conn,
+                UserID:    joinMsg.UserID,
+                Username:  joinMsg.Username,
+                Spectator: msgType == "spectate",
+                Send:      send,
             }
 
+            // Catch up from the updates missed while away, or fall back to a full snapshot
             currentRoomID = joinMsg.RoomID
-            rm.JoinRoom(currentRoomID, client)
+            replayed := false
+            if joinMsg.LastSeq > 0 {
+                replayed = rm.RejoinRoom(currentRoomID, client, joinMsg.LastSeq)
+            } else {
+                rm.JoinRoom(currentRoomID, client)
+            }
+
+            if err := rm.PublishPresence(currentRoomID, client, "connected"); err != nil {
+                log.Printf("Failed to publish presence: %v", err)
+            }
+
+            if replayed {
+                log.Printf("User %d rejoined room %s from seq %d", joinMsg.UserID, currentRoomID, joinMsg.LastSeq)
+                continue
+            }
 
             getStateMsg := usecase.MoveMessage{
                 Type:     "getGameState",
@@ -163,11 +181,15 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request, rm *usecase.RoomMan
         
             log.Printf("User %d joined room %s and requested game state", joinMsg.UserID, currentRoomID)
 
-        case "move":
+        case "move", "premove":
             if client == nil {
                 log.Printf("Move without joining room")
                 continue
             }
+            if client.Spectator {
+                rm.SendErrorToClient(currentRoomID, client.UserID, "Spectators cannot make moves")
+                continue
+            }
 
             var moveMsg usecase.MoveMessage
             if err := json.Unmarshal(messageData, &moveMsg); err != nil {
@@ -175,13 +197,39 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request, rm *usecase.RoomMan
                 continue
             }
 
-            moveMsg.Type = "move"
+            moveMsg.Type = msgType
             moveMsg.RoomID = currentRoomID
             moveMsg.PlayerID = client.UserID
 
             if err := rm.PublishMove(moveMsg); err != nil {
                 log.Printf("Failed to publish move: %v", err)
-                rm.SendErrorToClient(currentRoomID, client.UserID, "Failed to process move")
+                rm.SendToUser(currentRoomID, client.UserID, usecase.StateUpdateMessage{
+                    Type:   "moveRejected",
+                    RoomID: currentRoomID,
+                    MoveID: moveMsg.MoveID,
+                    Reason: "Failed to process move",
+                })
+            }
+
+        case "getLegalMoves":
+            if client == nil {
+                log.Printf("getLegalMoves without joining room")
+                continue
+            }
+            if client.Spectator {
+                rm.SendErrorToClient(currentRoomID, client.UserID, "Spectators cannot request legal moves")
+                continue
+            }
+
+            legalMovesMsg := usecase.MoveMessage{
+                Type:     "getLegalMoves",
+                RoomID:   currentRoomID,
+                PlayerID: client.UserID,
+            }
+
+            if err := rm.PublishMove(legalMovesMsg); err != nil {
+                log.Printf("Failed to request legal moves: %v", err)
+                rm.SendErrorToClient(currentRoomID, client.UserID, "Failed to get legal moves")
             }
 
         case "gameAction":
@@ -189,6 +237,10 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request, rm *usecase.RoomMan
                 log.Printf("Game action without joining room")
                 continue
             }
+            if client.Spectator {
+                rm.SendErrorToClient(currentRoomID, client.UserID, "Spectators cannot take game actions")
+                continue
+            }
 
             var actionMsg GameActionMessage
             if err := json.Unmarshal(messageData, &actionMsg); err != nil {
@@ -214,7 +266,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request, rm *usecase.RoomMan
 
         case "leaveRoom":
             if client != nil && currentRoomID != "" {
-                rm.LeaveRoom(currentRoomID, client.UserID)
+                rm.LeaveRoom(currentRoomID, client)
             }
             return
 
@@ -224,6 +276,6 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request, rm *usecase.RoomMan
     }
 
     if client != nil && currentRoomID != "" {
-        rm.LeaveRoom(currentRoomID, client.UserID)
+        rm.LeaveRoom(currentRoomID, client)
     }
 }
\ No newline at end of file
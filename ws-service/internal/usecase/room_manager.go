@@ -3,25 +3,55 @@ package usecase
 import (
     "context"
     "encoding/json"
+    "errors"
     "log"
+    "strconv"
     "sync"
+    "time"
     "github.com/go-redis/redis/v8"
     "github.com/gorilla/websocket"
+    "github.com/locne/protocol"
 )
 
 type Client struct {
-    Conn     *websocket.Conn
-    UserID   int
-    Username string
-    Send     chan []byte
+    Conn      *websocket.Conn
+    UserID    int
+    Username  string
+    Spectator bool // Watching, not playing: gets the spectator feed
+    Send      chan []byte
+}
+
+// Whether an untargeted update with this audience is meant for the client
+func (c *Client) inAudience(audience string) bool {
+    switch audience {
+    case protocol.AudiencePlayers:
+        return !c.Spectator
+    case protocol.AudienceSpectators:
+        return c.Spectator
+    }
+    return true
 }
 
 type Room struct {
     ID      string
     Clients map[int]*Client 
+    lastID  string // Last entry of the game's outbound stream delivered to this room
+    lastSeq int64
     mutex   sync.RWMutex
+    deliver sync.Mutex // Held while an update goes out, so a replay can't interleave with it
 }
 
+// Requests go to each game's inbound stream; game-service appends numbered updates to
+// its outbound stream
+const (
+    streamBlock     = time.Second
+    streamReadCount = 100
+)
+
+// The game has no inbound stream: it never existed or is already over
+var ErrGameNotFound = errors.New("game not found")
+
+
 type RoomManager struct {
     redis *redis.Client
     rooms map[string]*Room
@@ -29,118 +59,242 @@ type RoomManager struct {
     ctx   context.Context
 }
 
-type MoveMessage struct {
-    Type      string `json:"type"`
-    RoomID    string `json:"roomId"`
-    PlayerID  int    `json:"playerId"`
-    FromRow   int    `json:"fromRow"`
-    FromCol   int    `json:"fromCol"`
-    ToRow     int    `json:"toRow"`
-    ToCol     int    `json:"toCol"`
-    Promotion string `json:"promotion,omitempty"`
-}
+// Wire types are shared with game-service through the protocol module
+type (
+    MoveMessage        = protocol.MoveMessage
+    GameActionMessage  = protocol.GameActionMessage
+    PresenceMessage    = protocol.PresenceMessage
+    StateUpdateMessage = protocol.StateUpdateMessage
+)
 
-type GameActionMessage struct {
-    Type     string `json:"type"`     // "gameAction"
-    RoomID   string `json:"roomId"`
-    PlayerID int    `json:"playerId"`
-    Action   string `json:"action"`   // "resign", "drawOffer", "drawAccept", "drawDecline"
-    OfferID  string `json:"offerId,omitempty"` // For draw offers
+func NewRoomManager(redisClient *redis.Client) *RoomManager {
+    return &RoomManager{
+        redis: redisClient,
+        rooms: make(map[string]*Room),
+        ctx:   context.Background(),
+    }
 }
 
-type Player struct {
-    ID       int    `json:"userId"`       
-    Username string `json:"username"`
-    Color    string `json:"color"`       
-    Rating   int    `json:"rating,omitempty"`   
-    IsOnline bool   `json:"isOnline"`   
-}
+// Follow the outbound streams of every game room with clients on this instance
+func (rm *RoomManager) ListenStateUpdates() {
+    for {
+        rooms := rm.gameRooms()
+        if len(rooms) == 0 {
+            time.Sleep(streamBlock)
+            continue
+        }
 
-type Position struct {
-    Row int `json:"row"`
-    Col int `json:"col"`
-}
+        streams := make([]string, 0, 2*len(rooms))
+        streamRooms := make(map[string]string, len(rooms))
+        for _, room := range rooms {
+            streams = append(streams, protocol.OutStreamKey(room.ID))
+            streamRooms[protocol.OutStreamKey(room.ID)] = room.ID
+        }
+        for _, room := range rooms {
+            room.mutex.RLock()
+            streams = append(streams, room.lastID)
+            room.mutex.RUnlock()
+        }
+
+        results, err := rm.redis.XRead(rm.ctx, &redis.XReadArgs{
+            Streams: streams,
+            Count:   streamReadCount,
+            Block:   streamBlock,
+        }).Result()
+        if err == redis.Nil {
+            continue
+        }
+        if err != nil {
+            log.Printf("Error reading state updates: %v", err)
+            time.Sleep(streamBlock)
+            continue
+        }
 
-type CastlingRights struct {
-    WhiteKingSide  bool `json:"whiteKingSide"`
-    WhiteQueenSide bool `json:"whiteQueenSide"`
-    BlackKingSide  bool `json:"blackKingSide"`
-    BlackQueenSide bool `json:"blackQueenSide"`
+        for _, result := range results {
+            for _, entry := range result.Messages {
+                rm.deliverEntry(streamRooms[result.Stream], entry)
+            }
+        }
+    }
 }
 
-type BitboardGame struct {
-    WhitePawns   uint64 `json:"WhitePawns,string"`
-    WhiteRooks   uint64 `json:"WhiteRooks,string"`
-    WhiteKnights uint64 `json:"WhiteKnights,string"`
-    WhiteBishops uint64 `json:"WhiteBishops,string"`
-    WhiteQueens  uint64 `json:"WhiteQueens,string"`
-    WhiteKing    uint64 `json:"WhiteKing,string"`
-
-    BlackPawns   uint64 `json:"BlackPawns,string"`
-    BlackRooks   uint64 `json:"BlackRooks,string"`
-    BlackKnights uint64 `json:"BlackKnights,string"`
-    BlackBishops uint64 `json:"BlackBishops,string"`
-    BlackQueens  uint64 `json:"BlackQueens,string"`
-    BlackKing    uint64 `json:"BlackKing,string"`
+func (rm *RoomManager) gameRooms() []*Room {
+    rm.mutex.RLock()
+    defer rm.mutex.RUnlock()
+
+    rooms := make([]*Room, 0, len(rm.rooms))
+    for id, room := range rm.rooms {
+        if id != "matchmaking" {
+            rooms = append(rooms, room)
+        }
+    }
+    return rooms
 }
 
-type ClientGameState struct {
-    CurrentFen      string          `json:"currentFen"`
-    Bitboards       BitboardGame    `json:"bitboards"`
-    ActiveColor     string          `json:"activeColor"`
-    CastlingRights  CastlingRights  `json:"castlingRights"`
-    EnPassantSquare *Position       `json:"enPassantSquare"`
+func (rm *RoomManager) deliverEntry(roomID string, entry redis.XMessage) {
+    stateUpdate, seq, ok := decodeEntry(entry)
+
+    rm.mutex.RLock()
+    room, exists := rm.rooms[roomID]
+    rm.mutex.RUnlock()
+    if !exists {
+        return
+    }
+
+    room.deliver.Lock()
+    defer room.deliver.Unlock()
+
+    room.mutex.Lock()
+    gap := room.lastSeq > 0 && seq > room.lastSeq+1
+    room.lastID = entry.ID
+    if seq > room.lastSeq {
+        room.lastSeq = seq
+    }
+    room.mutex.Unlock()
+
+    if gap {
+        // Updates were trimmed before we read them: ask game-service to resend the full state to the room
+        log.Printf("Room %s missed updates before seq %d, requesting resync", roomID, seq)
+        rm.PublishMove(MoveMessage{Type: "resync", RoomID: roomID})
+    }
+    if !ok {
+        return
+    }
+
+    if stateUpdate.TargetPlayerID != nil {
+        rm.SendToUser(stateUpdate.RoomID, *stateUpdate.TargetPlayerID, stateUpdate)
+    } else {
+        rm.broadcastToAudience(stateUpdate.RoomID, stateUpdate.Audience, stateUpdate)
+    }
 }
 
-type MoveNotation struct {
-    MoveNumber int    `json:"moveNumber"`
-    White      string `json:"white"`
-    Black      string `json:"black"`
+func decodeEntry(entry redis.XMessage) (StateUpdateMessage, int64, bool) {
+    var stateUpdate StateUpdateMessage
+    seqField, _ := entry.Values[protocol.FieldSeq].(string)
+    seq, _ := strconv.ParseInt(seqField, 10, 64)
+
+    data, _ := entry.Values[protocol.FieldData].(string)
+    if err := json.Unmarshal([]byte(data), &stateUpdate); err != nil {
+        log.Printf("Error unmarshaling state update: %v", err)
+        return stateUpdate, seq, false
+    }
+    if err := stateUpdate.Validate(); err != nil {
+        log.Printf("Dropping state update %s: %v", entry.ID, err)
+        return stateUpdate, seq, false
+    }
+    stateUpdate.Seq = seq
+    return stateUpdate, seq, true
 }
 
-type StateUpdateMessage struct {
-    Type          string          `json:"type"`
-    RoomID        string          `json:"roomId"`
-    GameState     ClientGameState `json:"gameState,omitempty"`
-    Player1       Player          `json:"player1,omitempty"`
-    Player2       Player          `json:"player2,omitempty"`
-    WhiteTimeLeft int             `json:"whiteTimeLeft,omitempty"`
-    BlackTimeLeft int             `json:"blackTimeLeft,omitempty"`
-    MoveHistory   []MoveNotation  `json:"moveHistory,omitempty"`
-    Error         string          `json:"error,omitempty"`
-    Result        string          `json:"result,omitempty"`
-    Reason        string          `json:"reason,omitempty"`
-    Winner        string          `json:"winner,omitempty"`
-    OfferID       string          `json:"offerId,omitempty"` // For draw offers
-    OfferFrom     int             `json:"offerFrom,omitempty"` // Player ID who made the offer
-    TargetPlayerID *int           `json:"targetPlayerId,omitempty"` // For targeted messages
+// Join a client that last saw lastSeq and resend the updates it missed. No live update
+// reaches the client until the replay is done, so it gets them all in order. Returns false
+// when they are no longer in the stream and the client needs a full game state instead.
+func (rm *RoomManager) RejoinRoom(roomID string, client *Client, lastSeq int64) bool {
+    room := rm.room(roomID)
+
+    room.deliver.Lock()
+    defer room.deliver.Unlock()
+    rm.addClient(room, client)
+    return rm.replayUpdates(room, client, lastSeq)
 }
 
-func NewRoomManager(redisClient *redis.Client) *RoomManager {
-    return &RoomManager{
-        redis: redisClient,
-        rooms: make(map[string]*Room),
-        ctx:   context.Background(),
+// Caller must hold room.deliver
+func (rm *RoomManager) replayUpdates(room *Room, client *Client, lastSeq int64) bool {
+    roomID := room.ID
+
+    // Anything after lastID reaches the client through ListenStateUpdates
+    room.mutex.RLock()
+    lastID, roomSeq := room.lastID, room.lastSeq
+    room.mutex.RUnlock()
+    if roomSeq == 0 {
+        return false
     }
+    if lastSeq >= roomSeq {
+        return true
+    }
+
+    entries, err := rm.redis.XRange(rm.ctx, protocol.OutStreamKey(roomID), "-", lastID).Result()
+    if err != nil {
+        return false
+    }
+
+    replayed := 0
+    for _, entry := range entries {
+        stateUpdate, seq, ok := decodeEntry(entry)
+        if seq <= lastSeq {
+            continue
+        }
+        if replayed == 0 && seq > lastSeq+1 {
+            // The first update the client is missing has been trimmed already
+            return false
+        }
+        replayed++
+        if !ok {
+            continue
+        }
+        if stateUpdate.TargetPlayerID != nil && *stateUpdate.TargetPlayerID != client.UserID ||
+            stateUpdate.TargetPlayerID == nil && !client.inAudience(stateUpdate.Audience) {
+            continue
+        }
+
+        data, err := json.Marshal(stateUpdate)
+        if err != nil {
+            continue
+        }
+        select {
+        case client.Send <- data:
+        default:
+            log.Printf("Client %d send channel full, replay of room %s cut short", client.UserID, roomID)
+            return false
+        }
+    }
+    if replayed == 0 {
+        return false
+    }
+
+    log.Printf("Replayed %d updates of room %s to user %d", replayed, roomID, client.UserID)
+    return true
 }
 
-func (rm *RoomManager) ListenStateUpdates() {
-    pubsub := rm.redis.Subscribe(rm.ctx, "move_out")
-    defer pubsub.Close()
+// ID and sequence number of the newest entry of a stream, "0-0" if it is empty
+func (rm *RoomManager) latestEntry(stream string) (string, int64) {
+    entries, err := rm.redis.XRevRangeN(rm.ctx, stream, "+", "-", 1).Result()
+    if err != nil || len(entries) == 0 {
+        return "0-0", 0
+    }
+    _, seq, _ := decodeEntry(entries[0])
+    return entries[0].ID, seq
+}
 
-    for msg := range pubsub.Channel() {
-        var stateUpdate StateUpdateMessage
-        if err := json.Unmarshal([]byte(msg.Payload), &stateUpdate); err != nil {
-            log.Printf("Error unmarshaling state update: %v", err)
+// Forward matchFound notifications to players waiting in the matchmaking room
+func (rm *RoomManager) ListenMatchFound() {
+    lastID, _ := rm.latestEntry(protocol.MatchFoundStream)
+    for {
+        results, err := rm.redis.XRead(rm.ctx, &redis.XReadArgs{
+            Streams: []string{protocol.MatchFoundStream, lastID},
+            Count:   streamReadCount,
+            Block:   streamBlock,
+        }).Result()
+        if err == redis.Nil {
+            continue
+        }
+        if err != nil {
+            log.Printf("Error reading matchFound stream: %v", err)
+            time.Sleep(streamBlock)
             continue
         }
-        
-        if stateUpdate.Type == "matchFound" {
-            rm.handleMatchFound(stateUpdate)
-        } else if stateUpdate.TargetPlayerID != nil {
-            rm.SendToUser(stateUpdate.RoomID, *stateUpdate.TargetPlayerID, stateUpdate)
-        } else {
-            rm.BroadcastToRoom(stateUpdate.RoomID, stateUpdate)
+
+        for _, result := range results {
+            for _, entry := range result.Messages {
+                lastID = entry.ID
+                data, _ := entry.Values[protocol.FieldData].(string)
+                var matchFound StateUpdateMessage
+                if err := json.Unmarshal([]byte(data), &matchFound); err != nil {
+                    log.Printf("Error unmarshaling matchFound: %v", err)
+                    continue
+                }
+                rm.handleMatchFound(matchFound)
+            }
         }
     }
 }
@@ -181,24 +335,77 @@ func (rm *RoomManager) handleMatchFound(matchFound StateUpdateMessage) {
 }
 
 func (rm *RoomManager) PublishMove(moveMsg MoveMessage) error {
+    moveMsg.Version = protocol.Version
+    if err := moveMsg.Validate(); err != nil {
+        return err
+    }
     data, err := json.Marshal(moveMsg)
     if err != nil {
         return err
     }
 
-    return rm.redis.Publish(rm.ctx, "move_in", data).Err()
+    return rm.appendRequest(moveMsg.RoomID, protocol.KindMove, data)
 }
 
 func (rm *RoomManager) PublishGameAction(actionMsg GameActionMessage) error {
+    actionMsg.Version = protocol.Version
+    if err := actionMsg.Validate(); err != nil {
+        return err
+    }
     data, err := json.Marshal(actionMsg)
     if err != nil {
         return err
     }
 
-    return rm.redis.Publish(rm.ctx, "game_action", data).Err()
+    return rm.appendRequest(actionMsg.RoomID, protocol.KindGameAction, data)
+}
+
+// Tell game-service a player's or spectator's socket joined or left a game room
+func (rm *RoomManager) PublishPresence(roomID string, client *Client, status string) error {
+    if roomID == "matchmaking" {
+        return nil
+    }
+
+    presenceMsg := PresenceMessage{
+        Version:  protocol.Version,
+        Type:     "presence",
+        RoomID:   roomID,
+        PlayerID: client.UserID,
+        Status:   status,
+    }
+    if client.Spectator {
+        presenceMsg.Role = "spectator"
+    }
+    if err := presenceMsg.Validate(); err != nil {
+        return err
+    }
+    data, err := json.Marshal(presenceMsg)
+    if err != nil {
+        return err
+    }
+
+    return rm.appendRequest(roomID, protocol.KindPresence, data)
+}
+
+// Queue a request on the game's inbound stream. game-service creates the stream with the
+// game and deletes it when the game ends, so a missing stream means there is no such game.
+func (rm *RoomManager) appendRequest(roomID, kind string, data []byte) error {
+    err := rm.redis.XAdd(rm.ctx, &redis.XAddArgs{
+        Stream:     protocol.InStreamKey(roomID),
+        NoMkStream: true,
+        Values:     map[string]interface{}{protocol.FieldKind: kind, protocol.FieldData: data},
+    }).Err()
+    if err == redis.Nil {
+        return ErrGameNotFound
+    }
+    return err
 }
 
 func (rm *RoomManager) BroadcastToRoom(roomID string, message interface{}) {
+    rm.broadcastToAudience(roomID, "", message)
+}
+
+func (rm *RoomManager) broadcastToAudience(roomID, audience string, message interface{}) {
     rm.mutex.RLock()
     room, exists := rm.rooms[roomID]
     rm.mutex.RUnlock()
@@ -216,6 +423,9 @@ func (rm *RoomManager) BroadcastToRoom(roomID string, message interface{}) {
 
     room.mutex.RLock()
     for _, client := range room.Clients {
+        if !client.inAudience(audience) {
+            continue
+        }
         select {
         case client.Send <- data:
         default:
@@ -227,25 +437,47 @@ func (rm *RoomManager) BroadcastToRoom(roomID string, message interface{}) {
 
 
 func (rm *RoomManager) JoinRoom(roomID string, client *Client) {
+    rm.addClient(rm.room(roomID), client)
+}
+
+// The room, created if this is its first client
+func (rm *RoomManager) room(roomID string) *Room {
+    rm.mutex.RLock()
+    _, exists := rm.rooms[roomID]
+    rm.mutex.RUnlock()
+
+    // A new game room follows its stream from the current end
+    lastID, lastSeq := "0-0", int64(0)
+    if !exists && roomID != "matchmaking" {
+        lastID, lastSeq = rm.latestEntry(protocol.OutStreamKey(roomID))
+    }
+
     rm.mutex.Lock()
     room, exists := rm.rooms[roomID]
     if !exists {
         room = &Room{
             ID:      roomID,
             Clients: make(map[int]*Client),
+            lastID:  lastID,
+            lastSeq: lastSeq,
         }
         rm.rooms[roomID] = room
     }
     rm.mutex.Unlock()
+    return room
+}
 
+func (rm *RoomManager) addClient(room *Room, client *Client) {
     room.mutex.Lock()
     room.Clients[client.UserID] = client
     room.mutex.Unlock()
 
-    log.Printf("User %d (%s) joined room %s", client.UserID, client.Username, roomID)
+    log.Printf("User %d (%s) joined room %s", client.UserID, client.Username, room.ID)
 }
 
-func (rm *RoomManager) LeaveRoom(roomID string, userID int) {
+// Remove client from the room. A stale socket closing after the user already
+// rejoined on a new one leaves the new connection alone.
+func (rm *RoomManager) LeaveRoom(roomID string, client *Client) {
     rm.mutex.RLock()
     room, exists := rm.rooms[roomID]
     rm.mutex.RUnlock()
@@ -254,14 +486,23 @@ func (rm *RoomManager) LeaveRoom(roomID string, userID int) {
         return
     }
 
+    userID := client.UserID
+    left := false
     room.mutex.Lock()
-    if client, exists := room.Clients[userID]; exists {
+    if current, exists := room.Clients[userID]; exists && current == client {
         close(client.Send)
         delete(room.Clients, userID)
+        left = true
         log.Printf("User %d left room %s", userID, roomID)
     }
     room.mutex.Unlock()
 
+    if left {
+        if err := rm.PublishPresence(roomID, client, "disconnected"); err != nil && err != ErrGameNotFound {
+            log.Printf("Failed to publish disconnect of user %d: %v", userID, err)
+        }
+    }
+
     room.mutex.RLock()
     isEmpty := len(room.Clients) == 0
     room.mutex.RUnlock()